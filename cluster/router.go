@@ -15,6 +15,9 @@ func makeRouter() map[string]CmdFunc {
 	routerMap["type"] = defaultFunc
 	routerMap["rename"] = Rename
 	routerMap["renamenx"] = Rename
+	routerMap["dump"] = defaultFunc
+	routerMap["restore"] = defaultFunc
+	routerMap["restore-asking"] = defaultFunc
 
 	routerMap["set"] = defaultFunc
 	routerMap["setnx"] = defaultFunc
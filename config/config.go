@@ -19,6 +19,17 @@ type ServerProperties struct {
 	MaxClients     int    `cfg:"maxclients"`
 	RequirePass    string `cfg:"requirepass"`
 	Databases      int    `cfg:"databases"`
+	// NotifyKeyspaceEvents classes of keyspace events published over pub/sub, e.g. "KEA", empty means disabled
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"`
+	// MaxMemory limit of the estimated size of keys and values in bytes, units like 100mb are accepted, 0 means no limit
+	MaxMemory        int64  `cfg:"maxmemory"`
+	MaxMemoryPolicy  string `cfg:"maxmemory-policy"`
+	MaxMemorySamples int    `cfg:"maxmemory-samples"`
+	// LazyFree* 在后台释放被删除的大value，分别用于淘汰、过期、DEL和不带SYNC的FLUSHDB/FLUSHALL
+	LazyFreeLazyEviction  bool `cfg:"lazyfree-lazy-eviction"`
+	LazyFreeLazyExpire    bool `cfg:"lazyfree-lazy-expire"`
+	LazyFreeLazyUserDel   bool `cfg:"lazyfree-lazy-user-del"`
+	LazyFreeLazyUserFlush bool `cfg:"lazyfree-lazy-user-flush"`
 
 	Peers []string `cfg:"peers"`
 	Self  string   `cfg:"self"`
@@ -80,6 +91,11 @@ func parse(src io.Reader) *ServerProperties {
 				if err == nil {
 					fieldVal.SetInt(intValue)
 				}
+			case reflect.Int64:
+				memValue, err := parseMemory(value)
+				if err == nil {
+					fieldVal.SetInt(memValue)
+				}
 			case reflect.Bool:
 				boolValue := "yes" == value
 				fieldVal.SetBool(boolValue)
@@ -94,6 +110,34 @@ func parse(src io.Reader) *ServerProperties {
 	return config
 }
 
+// memoryUnits 内存大小的单位，与redis一致，k/m/g是1000的倍数，kb/mb/gb是1024的倍数
+var memoryUnits = []struct {
+	suffix string
+	unit   int64
+}{
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory 解析带单位的内存大小，例如 100mb
+func parseMemory(value string) (int64, error) {
+	value = strings.ToLower(value)
+	unit := int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSuffix(value, u.suffix)
+			unit = u.unit
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * unit, nil
+}
+
 // SetupConfig read config file and store properties into Properties
 func SetupConfig(configFilename string) {
 	file, err := os.Open(configFilename)
@@ -0,0 +1,592 @@
+package database
+
+import (
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// maxBitOffset is the greatest bit offset, a bitmap is limited to 512MB like redis
+const maxBitOffset = 1<<32 - 1
+
+const (
+	overflowWrap = iota
+	overflowSat
+	overflowFail
+)
+
+// growBitmap appends zero bytes so that bytes can hold bits up to the given offset
+func growBitmap(bytes []byte, bitOffset int64) []byte {
+	size := bitOffset/8 + 1
+	bytesLen := int64(len(bytes))
+	if bytesLen < size {
+		diffArray := make([]byte, size-bytesLen)
+		bytes = append(bytes, diffArray...)
+	}
+	return bytes
+}
+
+// getBit returns the bit at offset, bits beyond the end are 0
+func getBit(bytes []byte, offset int64) byte {
+	idx := offset / 8
+	if idx >= int64(len(bytes)) {
+		return 0
+	}
+	return (bytes[idx] >> (7 - uint(offset%8))) & 1
+}
+
+// setBit sets the bit at offset, bytes must be large enough
+func setBit(bytes []byte, offset int64, bit byte) {
+	idx := offset / 8
+	mask := byte(1) << (7 - uint(offset%8))
+	if bit == 0 {
+		bytes[idx] &^= mask
+	} else {
+		bytes[idx] |= mask
+	}
+}
+
+// getBits reads width bits starting at offset as an unsigned integer, the first bit is the most significant one
+func getBits(bytes []byte, offset int64, width uint) uint64 {
+	var value uint64
+	for i := uint(0); i < width; i++ {
+		value = value<<1 | uint64(getBit(bytes, offset+int64(i)))
+	}
+	return value
+}
+
+// setBits writes the lowest width bits of value starting at offset
+func setBits(bytes []byte, offset int64, width uint, value uint64) {
+	for i := uint(0); i < width; i++ {
+		bit := byte(value>>(width-1-i)) & 1
+		setBit(bytes, offset+int64(i), bit)
+	}
+}
+
+// countBits returns the number of set bits within [startBit, endBit]
+func countBits(bytes []byte, startBit, endBit int64) int64 {
+	first, last := startBit/8, endBit/8
+	var count int64
+	for i := first; i <= last; i++ {
+		count += int64(bits.OnesCount8(bytes[i]))
+	}
+	// exclude bits out of range in the first and the last byte
+	count -= int64(bits.OnesCount8(bytes[first] >> (8 - uint(startBit%8))))
+	count -= int64(bits.OnesCount8(bytes[last] << (uint(endBit%8) + 1)))
+	return count
+}
+
+func parseBitOffset(arg []byte) (int64, reply.ErrorReply) {
+	offset, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || offset < 0 || offset > maxBitOffset {
+		return 0, reply.MakeErrReply("ERR bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// parseBitRangeUnit parses the optional BYTE|BIT argument
+func parseBitRangeUnit(args [][]byte) (isBit bool, errReply reply.ErrorReply) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	if len(args) > 1 {
+		return false, &reply.SyntaxErrReply{}
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "BYTE":
+		return false, nil
+	case "BIT":
+		return true, nil
+	}
+	return false, &reply.SyntaxErrReply{}
+}
+
+// normalizeBitRange converts start and end in bytes or bits to inclusive bit offsets, returns false if the range is empty
+func normalizeBitRange(start, end int64, bytesLen int64, isBit bool) (int64, int64, bool) {
+	total := bytesLen
+	if isBit {
+		total = bytesLen * 8
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	if total == 0 || start > end {
+		return 0, 0, false
+	}
+	if isBit {
+		return start, end, true
+	}
+	return start * 8, end*8 + 7, true
+}
+
+// execSetBit sets or clears the bit at offset
+func execSetBit(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	value := string(args[2])
+	if value != "0" && value != "1" {
+		return reply.MakeErrReply("ERR bit is not an integer or out of range")
+	}
+
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	bytes = growBitmap(bytes, offset)
+	old := getBit(bytes, offset)
+	setBit(bytes, offset, value[0]-'0')
+	db.putStringKeepTTL(key, bytes)
+	db.addAof(utils.ToCmdLine2("setbit", args...))
+	db.notifyKeyspaceEvent(notifyString, "setbit", key)
+	return reply.MakeIntReply(int64(old))
+}
+
+// execGetBit returns the bit at offset
+func execGetBit(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeIntReply(int64(getBit(bytes, offset)))
+}
+
+// execBitCount counts set bits in string
+func execBitCount(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	var start, end int64 = 0, -1
+	isBit := false
+	if len(args) == 2 {
+		return &reply.SyntaxErrReply{}
+	}
+	if len(args) > 2 {
+		var err error
+		start, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		end, err = strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		var errReply reply.ErrorReply
+		isBit, errReply = parseBitRangeUnit(args[3:])
+		if errReply != nil {
+			return errReply
+		}
+	}
+
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	startBit, endBit, ok := normalizeBitRange(start, end, int64(len(bytes)), isBit)
+	if !ok {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(countBits(bytes, startBit, endBit))
+}
+
+// execBitPos returns the position of the first bit set to 1 or 0
+func execBitPos(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	bitArg := string(args[1])
+	if bitArg != "0" && bitArg != "1" {
+		return reply.MakeErrReply("ERR The bit argument must be 1 or 0.")
+	}
+	target := bitArg[0] - '0'
+	var start, end int64 = 0, -1
+	endGiven := false
+	isBit := false
+	if len(args) > 2 {
+		var err error
+		start, err = strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if len(args) > 3 {
+		var err error
+		end, err = strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		endGiven = true
+		var errReply reply.ErrorReply
+		isBit, errReply = parseBitRangeUnit(args[4:])
+		if errReply != nil {
+			return errReply
+		}
+	}
+
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		if target == 0 {
+			return reply.MakeIntReply(0)
+		}
+		return reply.MakeIntReply(-1)
+	}
+	startBit, endBit, ok := normalizeBitRange(start, end, int64(len(bytes)), isBit)
+	if !ok {
+		return reply.MakeIntReply(-1)
+	}
+	// skip the whole byte if it does not contain target bit
+	var skipped byte = 0xff
+	if target == 1 {
+		skipped = 0
+	}
+	for offset := startBit; offset <= endBit; {
+		if offset%8 == 0 && offset+7 <= endBit && bytes[offset/8] == skipped {
+			offset += 8
+			continue
+		}
+		if getBit(bytes, offset) == target {
+			return reply.MakeIntReply(offset)
+		}
+		offset++
+	}
+	if target == 0 && !endGiven {
+		// the string is padded with zero bits on the right
+		return reply.MakeIntReply(int64(len(bytes)) * 8)
+	}
+	return reply.MakeIntReply(-1)
+}
+
+// execBitOp performs bitwise operation between strings and stores the result in destkey
+func execBitOp(db *DB, args [][]byte) resp.Reply {
+	op := strings.ToUpper(string(args[0]))
+	destKey := string(args[1])
+	srcKeys := bytesToKeys(args[2:])
+	if op != "AND" && op != "OR" && op != "XOR" && op != "NOT" {
+		return &reply.SyntaxErrReply{}
+	}
+	if op == "NOT" && len(srcKeys) != 1 {
+		return reply.MakeErrReply("ERR BITOP NOT must be called with a single source key.")
+	}
+
+	values := make([][]byte, len(srcKeys))
+	maxLen := 0
+	for i, key := range srcKeys {
+		bytes, errReply := db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+		values[i] = bytes
+		if len(bytes) > maxLen {
+			maxLen = len(bytes)
+		}
+	}
+
+	result := make([]byte, maxLen)
+	for i := 0; i < maxLen; i++ {
+		// missing bytes of shorter strings are treated as zero
+		byteAt := func(j int) byte {
+			if i < len(values[j]) {
+				return values[j][i]
+			}
+			return 0
+		}
+		b := byteAt(0)
+		for j := 1; j < len(values); j++ {
+			switch op {
+			case "AND":
+				b &= byteAt(j)
+			case "OR":
+				b |= byteAt(j)
+			case "XOR":
+				b ^= byteAt(j)
+			}
+		}
+		if op == "NOT" {
+			b = ^b
+		}
+		result[i] = b
+	}
+
+	if maxLen == 0 {
+		db.Remove(destKey)
+	} else {
+		db.PutEntity(destKey, &database.DataEntity{
+			Data: result,
+		})
+	}
+	db.addAof(utils.ToCmdLine2("bitop", args...))
+	if maxLen == 0 {
+		db.notifyKeyspaceEvent(notifyGeneric, "del", destKey)
+	} else {
+		db.notifyKeyspaceEvent(notifyString, "set", destKey)
+	}
+	return reply.MakeIntReply(int64(maxLen))
+}
+
+// bitFieldType is the integer encoding of BITFIELD, like i8 or u16
+type bitFieldType struct {
+	signed bool
+	width  uint
+}
+
+// bitFieldOp is a GET, SET or INCRBY operation of BITFIELD
+type bitFieldOp struct {
+	name     string
+	typ      bitFieldType
+	offset   int64
+	value    int64
+	overflow int
+}
+
+func parseBitFieldType(arg []byte) (bitFieldType, reply.ErrorReply) {
+	s := strings.ToLower(string(arg))
+	errReply := reply.MakeErrReply("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'u') {
+		return bitFieldType{}, errReply
+	}
+	width, err := strconv.ParseUint(s[1:], 10, 8)
+	if err != nil || width < 1 || (s[0] == 'i' && width > 64) || (s[0] == 'u' && width > 63) {
+		return bitFieldType{}, errReply
+	}
+	return bitFieldType{
+		signed: s[0] == 'i',
+		width:  uint(width),
+	}, nil
+}
+
+// parseBitFieldOffset parses offset in bits, `#N` means N times the width of type
+func parseBitFieldOffset(arg []byte, typ bitFieldType) (int64, reply.ErrorReply) {
+	s := string(arg)
+	multiply := strings.HasPrefix(s, "#")
+	if multiply {
+		s = s[1:]
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err == nil && multiply {
+		if offset > maxBitOffset/int64(typ.width) {
+			err = strconv.ErrRange
+		}
+		offset *= int64(typ.width)
+	}
+	if err != nil || offset < 0 || offset+int64(typ.width)-1 > maxBitOffset {
+		return 0, reply.MakeErrReply("ERR bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// parseBitFieldOps parses sub commands of BITFIELD
+func parseBitFieldOps(args [][]byte, readOnly bool) ([]*bitFieldOp, reply.ErrorReply) {
+	ops := make([]*bitFieldOp, 0)
+	overflow := overflowWrap
+	for i := 0; i < len(args); {
+		name := strings.ToUpper(string(args[i]))
+		if readOnly && name != "GET" {
+			return nil, reply.MakeErrReply("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+		switch name {
+		case "OVERFLOW":
+			if i+1 >= len(args) {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return nil, reply.MakeErrReply("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+		case "GET", "SET", "INCRBY":
+			argNum := 3
+			if name == "GET" {
+				argNum = 2
+			}
+			if i+argNum >= len(args) {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			typ, errReply := parseBitFieldType(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			offset, errReply := parseBitFieldOffset(args[i+2], typ)
+			if errReply != nil {
+				return nil, errReply
+			}
+			op := &bitFieldOp{
+				name:     name,
+				typ:      typ,
+				offset:   offset,
+				overflow: overflow,
+			}
+			if name != "GET" {
+				value, err := strconv.ParseInt(string(args[i+3]), 10, 64)
+				if err != nil {
+					return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+				}
+				op.value = value
+			}
+			ops = append(ops, op)
+			i += argNum + 1
+		default:
+			return nil, &reply.SyntaxErrReply{}
+		}
+	}
+	return ops, nil
+}
+
+// signExtend converts the lowest width bits of value to a signed integer
+func signExtend(value uint64, width uint) int64 {
+	shift := 64 - width
+	return int64(value<<shift) >> shift
+}
+
+// applyOverflow adds incr to the field value which is old, returns the new value and whether it is acceptable.
+// For SET, old is the value to set and incr is 0.
+func (typ bitFieldType) applyOverflow(old int64, incr int64, overflow int) (int64, bool) {
+	if typ.signed {
+		max := int64(math.MaxInt64)
+		if typ.width < 64 {
+			max = 1<<(typ.width-1) - 1
+		}
+		min := -max - 1
+		if (incr > 0 && old > max-incr) || (incr == 0 && old > max) {
+			if overflow == overflowSat {
+				return max, true
+			}
+		} else if (incr < 0 && old < min-incr) || (incr == 0 && old < min) {
+			if overflow == overflowSat {
+				return min, true
+			}
+		} else {
+			return old + incr, true
+		}
+		if overflow == overflowFail {
+			return 0, false
+		}
+		return signExtend(uint64(old)+uint64(incr), typ.width), true
+	}
+
+	max := int64(1)<<typ.width - 1
+	mask := uint64(max)
+	// for SET the value to set is checked as an unsigned integer
+	if incr == 0 && (old < 0 || old > max) {
+		if overflow == overflowSat {
+			return max, true
+		}
+	} else if incr > 0 && old > max-incr {
+		if overflow == overflowSat {
+			return max, true
+		}
+	} else if incr < 0 && (incr == math.MinInt64 || old < -incr) {
+		if overflow == overflowSat {
+			return 0, true
+		}
+	} else {
+		return old + incr, true
+	}
+	if overflow == overflowFail {
+		return 0, false
+	}
+	return int64((uint64(old) + uint64(incr)) & mask), true
+}
+
+// execBitField treats string as an array of integers with arbitrary width
+func execBitField(db *DB, args [][]byte) resp.Reply {
+	return bitField(db, args, false)
+}
+
+// execBitFieldRO is the read-only variant of BITFIELD
+func execBitFieldRO(db *DB, args [][]byte) resp.Reply {
+	return bitField(db, args, true)
+}
+
+func bitField(db *DB, args [][]byte, readOnly bool) resp.Reply {
+	key := string(args[0])
+	ops, errReply := parseBitFieldOps(args[1:], readOnly)
+	if errReply != nil {
+		return errReply
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	modified := false
+	result := make([]resp.Reply, len(ops))
+	for i, op := range ops {
+		width := op.typ.width
+		raw := getBits(bytes, op.offset, width)
+		var old int64
+		if op.typ.signed {
+			old = signExtend(raw, width)
+		} else {
+			old = int64(raw)
+		}
+		if op.name == "GET" {
+			result[i] = reply.MakeIntReply(old)
+			continue
+		}
+
+		var value int64
+		var ok bool
+		if op.name == "SET" {
+			value, ok = op.typ.applyOverflow(op.value, 0, op.overflow)
+		} else {
+			value, ok = op.typ.applyOverflow(old, op.value, op.overflow)
+		}
+		if !ok {
+			result[i] = &reply.NullBulkReply{}
+			continue
+		}
+		bytes = growBitmap(bytes, op.offset+int64(width)-1)
+		setBits(bytes, op.offset, width, uint64(value))
+		modified = true
+		if op.name == "SET" {
+			result[i] = reply.MakeIntReply(old)
+		} else {
+			result[i] = reply.MakeIntReply(value)
+		}
+	}
+	if modified {
+		db.putStringKeepTTL(key, bytes)
+		db.addAof(utils.ToCmdLine2("bitfield", args...))
+		db.notifyKeyspaceEvent(notifyString, "setbit", key)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+func init() {
+	RegisterCommand("SetBit", execSetBit, 4).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("GetBit", execGetBit, 3)
+	RegisterCommand("BitCount", execBitCount, -2)
+	RegisterCommand("BitPos", execBitPos, -3)
+	RegisterCommand("BitOp", execBitOp, -4).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("BitField", execBitField, -2).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("BitField_RO", execBitFieldRO, -2)
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"container/list"
+	"goRedis/interface/resp"
+	"goRedis/resp/reply"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// serveFunc tries to serve a blocked client with the given key.
+// It returns nil if the key cannot satisfy the client yet.
+// serveFunc is always called with the registry locked.
+type serveFunc func(key string) resp.Reply
+
+// tryServe calls serve with the key. Like redis, a key recreated with another type can not serve
+// the client, so it keeps blocked instead of getting WRONGTYPE.
+func tryServe(serve serveFunc, key string) resp.Reply {
+	result := serve(key)
+	if _, ok := result.(*reply.WrongTypeErrReply); ok {
+		return nil
+	}
+	return result
+}
+
+// blockingReply is returned by blocking commands to ask DB.Exec to park the client
+// until one of the keys gets ready or timeout. It is never sent to the client.
+type blockingReply struct {
+	keys         []string
+	timeout      time.Duration // 0 means blocking forever
+	serve        serveFunc
+	timeoutReply resp.Reply
+}
+
+func (r *blockingReply) ToBytes() []byte {
+	return r.timeoutReply.ToBytes()
+}
+
+// waiter is a client blocked on some keys
+type waiter struct {
+	conn     resp.Connection
+	serve    serveFunc
+	elements map[string]*list.Element // position in the waiting queue of each key
+	result   chan resp.Reply
+	done     bool // served or canceled
+}
+
+// blockingRegistry keeps clients waiting for keys of a DB.
+// Clients blocked on the same key are served in FIFO order.
+type blockingRegistry struct {
+	mu      sync.Mutex
+	waiters map[string]*list.List // key -> queue of *waiter
+	byConn  map[resp.Connection]map[*waiter]struct{}
+
+	ready []string // keys got ready but blocked clients are not served yet
+}
+
+func makeBlockingRegistry() *blockingRegistry {
+	return &blockingRegistry{
+		waiters: make(map[string]*list.List),
+		byConn:  make(map[resp.Connection]map[*waiter]struct{}),
+	}
+}
+
+// block serves the client immediately if possible, otherwise parks it until served, timeout or disconnected
+func (r *blockingRegistry) block(c resp.Connection, br *blockingReply) resp.Reply {
+	r.mu.Lock()
+	for _, key := range br.keys {
+		if result := tryServe(br.serve, key); result != nil {
+			r.drainLocked()
+			r.mu.Unlock()
+			return result
+		}
+	}
+	if c.IsClosed() {
+		r.mu.Unlock()
+		return &reply.NoReply{}
+	}
+	w := &waiter{
+		conn:     c,
+		serve:    br.serve,
+		elements: make(map[string]*list.Element, len(br.keys)),
+		result:   make(chan resp.Reply, 1),
+	}
+	for _, key := range br.keys {
+		if _, ok := w.elements[key]; ok {
+			continue // duplicated key
+		}
+		queue, ok := r.waiters[key]
+		if !ok {
+			queue = list.New()
+			r.waiters[key] = queue
+		}
+		w.elements[key] = queue.PushBack(w)
+	}
+	waitersOfConn, ok := r.byConn[c]
+	if !ok {
+		waitersOfConn = make(map[*waiter]struct{})
+		r.byConn[c] = waitersOfConn
+	}
+	waitersOfConn[w] = struct{}{}
+	r.mu.Unlock()
+
+	var timeoutChan <-chan time.Time
+	if br.timeout > 0 {
+		timer := time.NewTimer(br.timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+	select {
+	case result := <-w.result:
+		return result
+	case <-timeoutChan:
+		r.mu.Lock()
+		if w.done {
+			// served right before timeout
+			r.mu.Unlock()
+			return <-w.result
+		}
+		r.removeLocked(w)
+		r.mu.Unlock()
+		return br.timeoutReply
+	}
+}
+
+// removeLocked takes the waiter out of all queues
+func (r *blockingRegistry) removeLocked(w *waiter) {
+	w.done = true
+	for key, elem := range w.elements {
+		queue := r.waiters[key]
+		queue.Remove(elem)
+		if queue.Len() == 0 {
+			delete(r.waiters, key)
+		}
+	}
+	if waitersOfConn, ok := r.byConn[w.conn]; ok {
+		delete(waitersOfConn, w)
+		if len(waitersOfConn) == 0 {
+			delete(r.byConn, w.conn)
+		}
+	}
+}
+
+// markReadyLocked records a key which may satisfy blocked clients, must be called with registry locked
+func (r *blockingRegistry) markReadyLocked(key string) {
+	if _, ok := r.waiters[key]; ok {
+		r.ready = append(r.ready, key)
+	}
+}
+
+// keyReady wakes up clients blocked on the given key, the oldest waiter is served first
+func (r *blockingRegistry) keyReady(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.markReadyLocked(key)
+	r.drainLocked()
+}
+
+// allKeysReady wakes up clients blocked on any key, it is used when the whole keyspace is replaced
+func (r *blockingRegistry) allKeysReady() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.waiters {
+		r.ready = append(r.ready, key)
+	}
+	r.drainLocked()
+}
+
+// drainLocked serves blocked clients with ready keys until no more key gets ready
+func (r *blockingRegistry) drainLocked() {
+	for len(r.ready) > 0 {
+		key := r.ready[0]
+		r.ready = r.ready[1:]
+		queue, ok := r.waiters[key]
+		if !ok {
+			continue
+		}
+		for elem := queue.Front(); elem != nil; {
+			next := elem.Next()
+			w := elem.Value.(*waiter)
+			result := tryServe(w.serve, key)
+			if result != nil {
+				r.removeLocked(w)
+				w.result <- result
+			}
+			elem = next
+		}
+	}
+}
+
+// cancel wakes up all clients blocked by the given connection without serving them
+func (r *blockingRegistry) cancel(c resp.Connection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for w := range r.byConn[c] {
+		r.removeLocked(w)
+		w.result <- &reply.NoReply{}
+	}
+}
+
+// signalKeyReady notifies clients blocked on key that it may be able to serve them
+func (db *DB) signalKeyReady(key string) {
+	db.blocking.keyReady(key)
+}
+
+// parseBlockingTimeout parses timeout in seconds
+func parseBlockingTimeout(arg []byte) (time.Duration, reply.ErrorReply) {
+	seconds, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, reply.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, reply.MakeErrReply("ERR timeout is negative")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -6,17 +6,65 @@ import (
 
 var cmdTable = make(map[string]*command)
 
+const (
+	// flagWrite 命令会修改数据，执行前需要检查maxmemory
+	flagWrite = 1 << iota
+	// flagDenyOOM 命令可能占用更多内存，内存不足时拒绝执行
+	flagDenyOOM
+)
+
 type command struct {
 	executor ExecFunc
 	arity    int // 参数数量
+	flags    int
+	// 原地修改value的key的位置，同redis的firstkey/lastkey/step，命令名的下标为0，lastKey为负数时从末尾倒数。
+	// 执行后重新估算这些key的内存，通过PutEntity写入的key已经估算过，不需要记录
+	firstKey int
+	lastKey  int
+	keyStep  int
 }
 
 // RegisterCommand
 // arity允许命令参数数量,如果arity < 0 就意味着len()args >= -arity
-func RegisterCommand(name string, executor ExecFunc, arity int) {
+func RegisterCommand(name string, executor ExecFunc, arity int) *command {
 	name = strings.ToLower(name)
-	cmdTable[name] = &command{
+	cmd := &command{
 		executor: executor,
 		arity:    arity,
 	}
+	cmdTable[name] = cmd
+	return cmd
+}
+
+// withFlags 设置命令的flag
+func (cmd *command) withFlags(flags int) *command {
+	cmd.flags |= flags
+	return cmd
+}
+
+// withKeys 设置命令原地修改的key的位置
+func (cmd *command) withKeys(first, last, step int) *command {
+	cmd.firstKey = first
+	cmd.lastKey = last
+	cmd.keyStep = step
+	return cmd
+}
+
+// modifiedKeys 返回命令原地修改的key
+func (cmd *command) modifiedKeys(cmdLine [][]byte) []string {
+	if cmd.firstKey <= 0 {
+		return nil
+	}
+	last := cmd.lastKey
+	if last < 0 {
+		last += len(cmdLine)
+	}
+	if last >= len(cmdLine) {
+		last = len(cmdLine) - 1
+	}
+	var keys []string
+	for i := cmd.firstKey; i <= last; i += cmd.keyStep {
+		keys = append(keys, string(cmdLine[i]))
+	}
+	return keys
 }
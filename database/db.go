@@ -5,19 +5,33 @@ import (
 	"goRedis/datastruct/dict"
 	"goRedis/interface/database"
 	"goRedis/interface/resp"
+	"goRedis/pubsub"
 	"goRedis/resp/reply"
 	"strings"
-	"time"
+	"sync"
+	"sync/atomic"
 )
 
 // DB stores data and execute user's commands
 type DB struct {
-	index  int
-	data   dict.Dict
-	addAof func(CmdLine)
-
-	// used for checking expiration
-	ttlKeys dict.Dict // key -> expireTime
+	index int
+	// SWAPDB and FLUSHDB ASYNC replace the keyspace as a whole while other clients are reading it,
+	// so it is loaded and stored atomically, spaceMu serializes the replacements
+	space   atomic.Pointer[keyspace]
+	spaceMu sync.Mutex
+	addAof  func(CmdLine)
+
+	// clients blocked by commands like blpop
+	blocking *blockingRegistry
+
+	// keyspace events are published to hub if the class is in notifyFlags
+	hub         *pubsub.Hub
+	notifyFlags int
+
+	// evicts keys before write commands when maxmemory is reached, nil if maxmemory is not set
+	evictor *evictor
+	// frees large values removed from db in background, values are dropped at once if it is nil
+	freer *lazyFreer
 }
 
 // ExecFunc command执行器的接口
@@ -26,16 +40,37 @@ type ExecFunc func(db *DB, args [][]byte) resp.Reply
 
 type CmdLine = [][]byte
 
+// keyspace holds keys of a DB
+type keyspace struct {
+	data dict.Dict
+	// used for checking expiration
+	ttlKeys dict.Dict // key -> expireTime
+	// estimated bytes used by keys and values, accessed atomically
+	memory int64
+}
+
+func makeKeyspace() *keyspace {
+	return &keyspace{
+		data:    dict.MakeSyncDict(),
+		ttlKeys: dict.MakeSyncDict(),
+	}
+}
+
 // makeDB 创建DB实例
 func makeDB() *DB {
 	db := &DB{
-		data:    dict.MakeSyncDict(),
-		addAof:  func(line CmdLine) {},
-		ttlKeys: dict.MakeSyncDict(),
+		addAof:   func(line CmdLine) {},
+		blocking: makeBlockingRegistry(),
 	}
+	db.space.Store(makeKeyspace())
 	return db
 }
 
+// keyspace returns the current keyspace, a command touching several keys should load it once
+func (db *DB) keyspace() *keyspace {
+	return db.space.Load()
+}
+
 // Exec 在一个DB中执行命令
 func (db *DB) Exec(c resp.Connection, cmdLine [][]byte) resp.Reply {
 
@@ -47,8 +82,21 @@ func (db *DB) Exec(c resp.Connection, cmdLine [][]byte) resp.Reply {
 	if !validateArity(cmd.arity, cmdLine) {
 		return reply.MakeArgNumErrReply(cmdName)
 	}
+	if cmd.flags&flagWrite != 0 && db.evictor != nil {
+		if !db.evictor.freeMemoryIfNeeded() && cmd.flags&flagDenyOOM != 0 {
+			return oomErrReply
+		}
+	}
 	fun := cmd.executor
-	return fun(db, cmdLine[1:])
+	result := fun(db, cmdLine[1:])
+	if cmd.flags&flagWrite != 0 {
+		db.refreshMemory(cmd.modifiedKeys(cmdLine)...)
+	}
+	if blocking, ok := result.(*blockingReply); ok {
+		// park the client until the keys get ready
+		return db.blocking.block(c, blocking)
+	}
+	return result
 }
 
 func validateArity(arity int, cmdArgs [][]byte) bool {
@@ -61,8 +109,18 @@ func validateArity(arity int, cmdArgs [][]byte) bool {
 
 /* ---- 连接数据库 ----- */
 
+// GetEntity returns the entity of key and records the access
 func (db *DB) GetEntity(key string) (*database.DataEntity, bool) {
-	raw, ok := db.data.Get(key)
+	entity, ok := db.peekEntity(key)
+	if ok {
+		touchEntity(entity, nowMillis())
+	}
+	return entity, ok
+}
+
+// peekEntity returns the entity of key without recording the access, like OBJECT and TTL in redis
+func (db *DB) peekEntity(key string) (*database.DataEntity, bool) {
+	raw, ok := db.keyspace().data.Get(key)
 	if !ok {
 		return nil, false
 	}
@@ -70,40 +128,95 @@ func (db *DB) GetEntity(key string) (*database.DataEntity, bool) {
 	entity, _ := raw.(*database.DataEntity)
 
 	// Check if the key is expired
-	if entity.ExpireTime > 0 {
-		now := time.Now().UnixNano() / 1e6 // current time in milliseconds
-		if entity.ExpireTime <= now {
-			// Key is expired, remove it
-			db.Remove(key)
-			return nil, false
-		}
+	if now := nowMillis(); isExpired(entity, now) {
+		db.expireKey(key, now)
+		return nil, false
 	}
 
 	return entity, true
 }
 
+// rawEntity returns the entity of key even if it has expired, nil if the key does not exist
+func (db *DB) rawEntity(key string) *database.DataEntity {
+	return db.keyspace().rawEntity(key)
+}
+
+func (ks *keyspace) rawEntity(key string) *database.DataEntity {
+	raw, ok := ks.data.Get(key)
+	if !ok {
+		return nil
+	}
+	entity, _ := raw.(*database.DataEntity)
+	return entity
+}
+
 func (db *DB) PutEntity(key string, entity *database.DataEntity) int {
-	return db.data.Put(key, entity)
+	ks := db.keyspace()
+	old := ks.rawEntity(key)
+	initAccess(entity, old)
+	result := ks.data.Put(key, entity)
+	ks.trackExpire(key, entity)
+	ks.accountMemory(key, entity, old)
+	return result
 }
 
 func (db *DB) PutIfExists(key string, entity *database.DataEntity) int {
-	return db.data.PutIfExists(key, entity)
+	ks := db.keyspace()
+	old := ks.rawEntity(key)
+	initAccess(entity, old)
+	result := ks.data.PutIfExists(key, entity)
+	if result > 0 {
+		ks.trackExpire(key, entity)
+		ks.accountMemory(key, entity, old)
+	}
+	return result
 }
 
 func (db *DB) PutIfAbsent(key string, entity *database.DataEntity) int {
-	return db.data.PutIfAbsent(key, entity)
+	ks := db.keyspace()
+	initAccess(entity, nil)
+	result := ks.data.PutIfAbsent(key, entity)
+	if result > 0 {
+		ks.trackExpire(key, entity)
+		ks.accountMemory(key, entity, nil)
+	}
+	return result
 }
 
 // Remove 指定的key清除
 func (db *DB) Remove(key string) {
-	db.data.Remove(key)
+	ks := db.keyspace()
+	if entity := ks.rawEntity(key); entity != nil && ks.data.Remove(key) > 0 {
+		atomic.AddInt64(&ks.memory, -atomic.LoadInt64(&entity.MemorySize))
+	}
+	ks.ttlKeys.Remove(key)
+}
+
+// removeEntity removes key only if it still holds entity, so that a key written again after
+// entity was read, or a keyspace moved to another DB by SWAPDB, is left untouched
+func (ks *keyspace) removeEntity(key string, entity *database.DataEntity) bool {
+	if ks.data.RemoveIfEqual(key, entity) == 0 {
+		return false
+	}
+	atomic.AddInt64(&ks.memory, -atomic.LoadInt64(&entity.MemorySize))
+	ks.ttlKeys.RemoveIfEqual(key, entity.ExpireTime)
+	return true
+}
+
+// removeIfSame removes key from ks only if ks is still the keyspace of db and key still holds entity.
+// It is used by expiration and eviction which read the entity before deciding to remove it.
+func (db *DB) removeIfSame(ks *keyspace, key string, entity *database.DataEntity) bool {
+	// SWAPDB and FLUSHDB replace the keyspace under spaceMu
+	db.spaceMu.Lock()
+	defer db.spaceMu.Unlock()
+	return db.keyspace() == ks && ks.removeEntity(key, entity)
 }
 
 // Removes 根据key，清除数据库
 func (db *DB) Removes(keys ...string) (deleted int) {
 	deleted = 0
 	for _, key := range keys {
-		_, exists := db.data.Get(key)
+		_, exists := db.keyspace().data.Get(key)
 		if exists {
 			db.Remove(key)
 			deleted++
@@ -112,6 +225,7 @@ func (db *DB) Removes(keys ...string) (deleted int) {
 	return deleted
 }
 
+// Flush detaches the keyspace at once and clears it on the calling goroutine
 func (db *DB) Flush() {
-	db.data.Clear()
+	freeValue(db.detachKeyspace())
 }
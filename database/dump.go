@@ -0,0 +1,122 @@
+package database
+
+import (
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// execDump returns the serialized value of key, which can be restored by RESTORE
+func execDump(db *DB, args [][]byte) resp.Reply {
+	entity, exists := db.GetEntity(string(args[0]))
+	if !exists {
+		return &reply.NullBulkReply{}
+	}
+	payload, err := serializeValue(entity.Data)
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	return reply.MakeBulkReply(payload)
+}
+
+// execRestore creates a key from a DUMP payload:
+// RESTORE key ttl serialized-value [REPLACE] [ABSTTL] [IDLETIME seconds] [FREQ frequency]
+func execRestore(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	ttl, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if ttl < 0 {
+		return reply.MakeErrReply("ERR Invalid TTL value, must be >= 0")
+	}
+	payload := args[2]
+
+	// parse options
+	var replace, absTTL bool
+	idleTime, freq := int64(-1), int64(-1)
+	for i := 3; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "REPLACE":
+			replace = true
+		case arg == "ABSTTL":
+			absTTL = true
+		case arg == "IDLETIME" && i+1 < len(args) && freq < 0:
+			idleTime, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if idleTime < 0 {
+				return reply.MakeErrReply("ERR Invalid IDLETIME value, must be >= 0")
+			}
+			i++
+		case arg == "FREQ" && i+1 < len(args) && idleTime < 0:
+			freq, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if freq < 0 || freq > 255 {
+				return reply.MakeErrReply("ERR Invalid FREQ value, must be >= 0 and <= 255")
+			}
+			i++
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+	}
+
+	_, exists := db.GetEntity(key)
+	if exists && !replace {
+		return reply.MakeErrReply("BUSYKEY Target key name already exists.")
+	}
+	data, err := deserializeValue(payload)
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	now := nowMillis()
+	expireAt := ttl
+	if ttl > 0 && !absTTL {
+		if ttl > math.MaxInt64-now {
+			return reply.MakeErrReply("ERR invalid expire time in 'restore' command")
+		}
+		expireAt += now
+	}
+	if expireAt > 0 && expireAt <= now {
+		// the key would expire at once, only the replaced key is removed like redis
+		if exists {
+			db.Remove(key)
+			db.addAof(utils.ToCmdLine("del", key))
+			db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+		}
+		return &reply.OkReply{}
+	}
+
+	entity := &database.DataEntity{
+		Data:       data,
+		ExpireTime: expireAt,
+	}
+	if idleTime >= 0 {
+		storeAccess(entity, now-idleTime*1000, lfuInitVal)
+	} else if freq >= 0 {
+		storeAccess(entity, now, uint8(freq))
+	}
+	db.PutEntity(key, entity)
+	// record absolute timestamp so that replaying gets the same expiration
+	db.addAof(utils.ToCmdLine2("restore", []byte(key), []byte(strconv.FormatInt(expireAt, 10)), payload,
+		[]byte("REPLACE"), []byte("ABSTTL")))
+	db.notifyKeyspaceEvent(notifyGeneric, "restore", key)
+	db.signalKeyReady(key)
+	return &reply.OkReply{}
+}
+
+func init() {
+	RegisterCommand("Dump", execDump, 2)
+	RegisterCommand("Restore", execRestore, -4).withFlags(flagWrite | flagDenyOOM)
+	// RESTORE-ASKING is sent by cluster migration to the importing node, it behaves the same in a single node
+	RegisterCommand("Restore-Asking", execRestore, -4).withFlags(flagWrite | flagDenyOOM)
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"goRedis/config"
+	"goRedis/interface/database"
+	"goRedis/lib/logger"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"strings"
+	"sync"
+)
+
+// eviction policies, the same as maxmemory-policy of redis
+const (
+	noEviction = iota
+	allKeysLRU
+	allKeysLFU
+	allKeysRandom
+	volatileLRU
+	volatileLFU
+	volatileRandom
+	volatileTTL
+)
+
+var evictionPolicies = map[string]int{
+	"noeviction":      noEviction,
+	"allkeys-lru":     allKeysLRU,
+	"allkeys-lfu":     allKeysLFU,
+	"allkeys-random":  allKeysRandom,
+	"volatile-lru":    volatileLRU,
+	"volatile-lfu":    volatileLFU,
+	"volatile-random": volatileRandom,
+	"volatile-ttl":    volatileTTL,
+}
+
+const defaultEvictionSamples = 5
+
+var oomErrReply = reply.MakeErrReply("OOM command not allowed when used memory > 'maxmemory'.")
+
+// evictor frees memory by evicting keys of all databases when maxmemory is reached
+type evictor struct {
+	dbSet     []*DB
+	maxMemory int64
+	policy    int
+	samples   int
+
+	// evictions are serialized, so that concurrent commands do not evict more than needed
+	mu sync.Mutex
+	// the database to start with for random policies, so that every database gets evicted
+	nextDB int
+}
+
+// makeEvictor creates an evictor from config, returns nil if maxmemory is not set
+func makeEvictor(dbSet []*DB) *evictor {
+	if config.Properties.MaxMemory <= 0 {
+		return nil
+	}
+	policyName := strings.ToLower(config.Properties.MaxMemoryPolicy)
+	if policyName == "" {
+		policyName = "noeviction"
+	}
+	policy, ok := evictionPolicies[policyName]
+	if !ok {
+		logger.Warn("unknown maxmemory-policy " + config.Properties.MaxMemoryPolicy + ", use noeviction")
+	}
+	samples := config.Properties.MaxMemorySamples
+	if samples <= 0 {
+		samples = defaultEvictionSamples
+	}
+	return &evictor{
+		dbSet:     dbSet,
+		maxMemory: config.Properties.MaxMemory,
+		policy:    policy,
+		samples:   samples,
+	}
+}
+
+// usedMemory returns the estimated bytes of keys and values in all databases
+func (e *evictor) usedMemory() int64 {
+	var used int64
+	for _, db := range e.dbSet {
+		used += db.usedMemory()
+	}
+	return used
+}
+
+// freeMemoryIfNeeded evicts keys until memory usage is under maxmemory, returns false if no memory can be freed
+func (e *evictor) freeMemoryIfNeeded() bool {
+	if e.usedMemory() <= e.maxMemory {
+		return true
+	}
+	if e.policy == noEviction {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for e.usedMemory() > e.maxMemory {
+		if !e.evictOne() {
+			return false
+		}
+	}
+	return true
+}
+
+// isVolatilePolicy returns whether only keys with ttl can be evicted
+func (e *evictor) isVolatilePolicy() bool {
+	return e.policy >= volatileLRU
+}
+
+// sampleKeys returns random keys of ks which may be evicted
+func (e *evictor) sampleKeys(ks *keyspace, count int) []string {
+	candidates := ks.data
+	if e.isVolatilePolicy() {
+		candidates = ks.ttlKeys
+	}
+	if candidates.Len() == 0 {
+		// skip empty databases, sampling them would look into every shard for nothing
+		return nil
+	}
+	return candidates.RandomKeys(count)
+}
+
+// evictionScore returns the priority of entity to be evicted, the higher the earlier
+func (e *evictor) evictionScore(entity *database.DataEntity, now int64) int64 {
+	switch e.policy {
+	case allKeysLRU, volatileLRU:
+		// idle time
+		lastAccess, _ := loadAccess(entity)
+		return now - lastAccess
+	case allKeysLFU, volatileLFU:
+		return 255 - int64(lfuDecr(entity, now))
+	default:
+		// volatile-ttl, the nearest to expire the first
+		return -entity.ExpireTime
+	}
+}
+
+// evictOne evicts a key chosen by the policy, returns false if there is no key to evict
+func (e *evictor) evictOne() bool {
+	if e.policy == allKeysRandom || e.policy == volatileRandom {
+		for i := 0; i < len(e.dbSet); i++ {
+			db := e.dbSet[(e.nextDB+i)%len(e.dbSet)]
+			ks := db.keyspace()
+			for _, key := range e.sampleKeys(ks, e.samples) {
+				// skip stale keys of ttlKeys and keys changed since sampled
+				if entity := ks.rawEntity(key); entity != nil && db.evictKey(ks, key, entity) {
+					e.nextDB = (e.nextDB + i + 1) % len(e.dbSet)
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// approximated like redis: sample a few keys of every database and evict the best candidate
+	var bestDB *DB
+	var bestSpace *keyspace
+	var bestKey string
+	var bestEntity *database.DataEntity
+	var bestScore int64
+	now := nowMillis()
+	for _, db := range e.dbSet {
+		ks := db.keyspace()
+		for _, key := range e.sampleKeys(ks, e.samples) {
+			entity := ks.rawEntity(key)
+			if entity == nil {
+				continue
+			}
+			if e.isVolatilePolicy() && entity.ExpireTime == 0 {
+				continue
+			}
+			score := e.evictionScore(entity, now)
+			if bestDB == nil || score > bestScore {
+				bestDB, bestSpace, bestKey, bestEntity, bestScore = db, ks, key, entity, score
+			}
+		}
+	}
+	if bestDB == nil {
+		return false
+	}
+	// if the candidate has been changed since sampled, the caller samples again
+	bestDB.evictKey(bestSpace, bestKey, bestEntity)
+	return true
+}
+
+// evictKey removes the sampled key from ks to free memory, the deletion is recorded in aof.
+// It returns false if the key no longer holds entity or ks is no longer the keyspace of db.
+func (db *DB) evictKey(ks *keyspace, key string, entity *database.DataEntity) bool {
+	if !db.removeIfSame(ks, key, entity) {
+		return false
+	}
+	db.freeRemoved(entity, lazyfreeEviction)
+	db.addAof(utils.ToCmdLine("del", key))
+	db.notifyKeyspaceEvent(notifyEvicted, "evicted", key)
+	return true
+}
+
+// denyOOM returns the OOM error if memory is over maxmemory and no key can be evicted.
+// Commands working on several databases, such as COPY, MOVE and SWAPDB, check it by themselves.
+func (mdb *StandaloneDatabase) denyOOM() reply.ErrorReply {
+	if evictor := mdb.dbSet[0].evictor; evictor != nil && !evictor.freeMemoryIfNeeded() {
+		return oomErrReply
+	}
+	return nil
+}
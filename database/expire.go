@@ -0,0 +1,110 @@
+package database
+
+import (
+	"goRedis/interface/database"
+	"goRedis/lib/utils"
+	"time"
+)
+
+const (
+	// activeExpireInterval is the interval between two active expire cycles
+	activeExpireInterval = 100 * time.Millisecond
+	// activeExpireSlice is the max time spent on a DB in one cycle, so that commands are not delayed for long
+	activeExpireSlice = 2 * time.Millisecond
+	// activeExpireSamples is the number of keys with ttl checked in each round
+	activeExpireSamples = 20
+)
+
+// trackExpire records the expire time of entity in ttlKeys
+func (ks *keyspace) trackExpire(key string, entity *database.DataEntity) {
+	if entity.ExpireTime > 0 {
+		ks.ttlKeys.Put(key, entity.ExpireTime)
+	} else {
+		ks.ttlKeys.Remove(key)
+	}
+}
+
+// Expire sets the expire time of an existing key, expireAt is unix timestamp in milliseconds
+func (db *DB) Expire(key string, expireAt int64) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return
+	}
+	entity.ExpireTime = expireAt
+	ks := db.keyspace()
+	ks.trackExpire(key, entity)
+	// the entry of ttlKeys is accounted in the size of entity
+	ks.accountMemory(key, entity, entity)
+}
+
+// Persist removes the expire time of an existing key
+func (db *DB) Persist(key string) {
+	db.Expire(key, 0)
+}
+
+// isExpired returns whether the entity should be removed
+func isExpired(entity *database.DataEntity, now int64) bool {
+	return entity.ExpireTime > 0 && entity.ExpireTime <= now
+}
+
+// expireKey removes the key if it has expired, the deletion is recorded in aof
+func (db *DB) expireKey(key string, now int64) bool {
+	ks := db.keyspace()
+	entity := ks.rawEntity(key)
+	if entity == nil {
+		ks.ttlKeys.Remove(key)
+		return false
+	}
+	if !isExpired(entity, now) {
+		if entity.ExpireTime == 0 {
+			ks.ttlKeys.Remove(key)
+		}
+		return false
+	}
+	if !db.removeIfSame(ks, key, entity) {
+		// the key has been written again, or ks has been swapped or flushed away
+		return false
+	}
+	db.freeRemoved(entity, lazyfreeExpire)
+	db.addAof(utils.ToCmdLine("del", key))
+	db.notifyKeyspaceEvent(notifyExpired, "expired", key)
+	return true
+}
+
+// activeExpire samples keys with ttl and removes expired ones until the deadline.
+// Like redis, another round is started only if more than 1/4 of the samples were expired,
+// so that the cost is proportional to the number of expired keys.
+func (db *DB) activeExpire(deadline time.Time) {
+	for {
+		keys := db.keyspace().ttlKeys.RandomDistinctKeys(activeExpireSamples)
+		if len(keys) == 0 {
+			return
+		}
+		now := nowMillis()
+		expired := 0
+		for _, key := range keys {
+			if db.expireKey(key, now) {
+				expired++
+			}
+		}
+		if expired*4 <= len(keys) || time.Now().After(deadline) {
+			return
+		}
+	}
+}
+
+// activeExpireLoop runs active expire cycle on every DB periodically until stopped
+func (mdb *StandaloneDatabase) activeExpireLoop() {
+	ticker := time.NewTicker(activeExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, db := range mdb.dbSet {
+				db.activeExpire(time.Now().Add(activeExpireSlice))
+			}
+		case <-mdb.stopExpire:
+			return
+		}
+	}
+}
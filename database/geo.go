@@ -0,0 +1,527 @@
+package database
+
+import (
+	"fmt"
+	"goRedis/datastruct/sortedset"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/geohash"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	geoSortNone = iota
+	geoSortAsc
+	geoSortDesc
+)
+
+// geoSearchOption is the parsed arguments of GEOSEARCH and GEOSEARCHSTORE
+type geoSearchOption struct {
+	fromMember  string
+	fromLonLat  bool
+	lat, lng    float64
+	byRadius    bool
+	byBox       bool
+	radius      float64 // in meters
+	width       float64 // in meters
+	height      float64 // in meters
+	unit        float64 // meters of the unit used by reply
+	sort        int
+	count       int
+	any         bool
+	withCoord   bool
+	withDist    bool
+	withHash    bool
+	storeDist   bool
+	storeTarget bool // parsing GEOSEARCHSTORE
+}
+
+// geoPoint is a member found by GEOSEARCH
+type geoPoint struct {
+	member string
+	hash   uint64
+	lat    float64
+	lng    float64
+	dist   float64 // in meters
+}
+
+// parseGeoUnit returns meters of the unit
+func parseGeoUnit(arg []byte) (float64, reply.ErrorReply) {
+	switch strings.ToLower(string(arg)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, reply.MakeErrReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+// parseLonLat parses longitude and latitude and checks their range
+func parseLonLat(lngArg, latArg []byte) (lat, lng float64, errReply reply.ErrorReply) {
+	lng, err := strconv.ParseFloat(string(lngArg), 64)
+	if err != nil {
+		return 0, 0, reply.MakeErrReply("ERR value is not a valid float")
+	}
+	lat, err = strconv.ParseFloat(string(latArg), 64)
+	if err != nil {
+		return 0, 0, reply.MakeErrReply("ERR value is not a valid float")
+	}
+	if lng < geohash.MinLongitude || lng > geohash.MaxLongitude ||
+		lat < geohash.MinLatitude || lat > geohash.MaxLatitude {
+		return 0, 0, reply.MakeErrReply(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", lng, lat))
+	}
+	return lat, lng, nil
+}
+
+// formatCoordinate formats coordinate like redis, with at most 17 decimal places
+func formatCoordinate(f float64) []byte {
+	s := strconv.FormatFloat(f, 'f', 17, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return []byte(s)
+}
+
+// formatDistance formats distance in the given unit with 4 decimal places
+func formatDistance(meters float64, unit float64) []byte {
+	return []byte(strconv.FormatFloat(meters/unit, 'f', 4, 64))
+}
+
+func coordinateReply(lat, lng float64) resp.Reply {
+	return reply.MakeMultiBulkReply([][]byte{
+		formatCoordinate(lng),
+		formatCoordinate(lat),
+	})
+}
+
+// execGeoAdd adds members with coordinates into sorted set, the score is the 52 bit geohash
+func execGeoAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	nx, xx, ch := false, false, false
+	i := 1
+	for ; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		if arg == "NX" {
+			nx = true
+		} else if arg == "XX" {
+			xx = true
+		} else if arg == "CH" {
+			ch = true
+		} else {
+			break
+		}
+	}
+	if nx && xx {
+		return reply.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if len(args)-i == 0 || (len(args)-i)%3 != 0 {
+		return reply.MakeArgNumErrReply("geoadd")
+	}
+
+	elements := make([]sortedset.Element, 0, (len(args)-i)/3)
+	for ; i < len(args); i += 3 {
+		lat, lng, errReply := parseLonLat(args[i], args[i+1])
+		if errReply != nil {
+			return errReply
+		}
+		elements = append(elements, sortedset.Element{
+			Member: string(args[i+2]),
+			Score:  float64(geohash.Encode(lat, lng)),
+		})
+	}
+
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		if xx {
+			return reply.MakeIntReply(0)
+		}
+		zset, _, _ = db.getOrInitZSet(key)
+	}
+	var added, changed int64
+	for _, element := range elements {
+		score, exists := zset.GetScore(element.Member)
+		if (exists && nx) || (!exists && xx) {
+			continue
+		}
+		if !exists {
+			added++
+		} else if score != element.Score {
+			changed++
+		}
+		zset.Add(element.Member, element.Score)
+	}
+	if zset.Len() == 0 {
+		db.Remove(key)
+	}
+	if added > 0 || changed > 0 {
+		db.addAof(utils.ToCmdLine2("geoadd", args...))
+		db.notifyKeyspaceEvent(notifyZSet, "zadd", key)
+	}
+	if added > 0 {
+		db.signalKeyReady(key)
+	}
+	if ch {
+		return reply.MakeIntReply(added + changed)
+	}
+	return reply.MakeIntReply(added)
+}
+
+// execGeoPos returns coordinates of members
+func execGeoPos(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(args)-1)
+	for i, arg := range args[1:] {
+		if zset == nil {
+			result[i] = &reply.NullMultiBulkReply{}
+			continue
+		}
+		score, exists := zset.GetScore(string(arg))
+		if !exists {
+			result[i] = &reply.NullMultiBulkReply{}
+			continue
+		}
+		lat, lng := geohash.Decode(uint64(score))
+		result[i] = coordinateReply(lat, lng)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execGeoDist returns the distance between two members
+func execGeoDist(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	if len(args) > 4 {
+		return &reply.SyntaxErrReply{}
+	}
+	var unit float64 = 1
+	if len(args) == 4 {
+		var errReply reply.ErrorReply
+		unit, errReply = parseGeoUnit(args[3])
+		if errReply != nil {
+			return errReply
+		}
+	}
+
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return &reply.NullBulkReply{}
+	}
+	score1, exists1 := zset.GetScore(string(args[1]))
+	score2, exists2 := zset.GetScore(string(args[2]))
+	if !exists1 || !exists2 {
+		return &reply.NullBulkReply{}
+	}
+	lat1, lng1 := geohash.Decode(uint64(score1))
+	lat2, lng2 := geohash.Decode(uint64(score2))
+	return reply.MakeBulkReply(formatDistance(geohash.Distance(lat1, lng1, lat2, lng2), unit))
+}
+
+// execGeoHash returns the standard geohash strings of members
+func execGeoHash(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	for i, arg := range args[1:] {
+		if zset == nil {
+			continue
+		}
+		score, exists := zset.GetScore(string(arg))
+		if !exists {
+			continue
+		}
+		lat, lng := geohash.Decode(uint64(score))
+		result[i] = []byte(geohash.ToString(lat, lng))
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// parseGeoSearchOption parses arguments of GEOSEARCH following the key
+func parseGeoSearchOption(args [][]byte, storeTarget bool) (*geoSearchOption, reply.ErrorReply) {
+	option := &geoSearchOption{
+		unit:        1,
+		storeTarget: storeTarget,
+	}
+	fromMember := false
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		remain := len(args) - i - 1
+		switch {
+		case arg == "FROMMEMBER" && remain >= 1:
+			if fromMember || option.fromLonLat {
+				return nil, reply.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+			}
+			option.fromMember = string(args[i+1])
+			fromMember = true
+			i++
+		case arg == "FROMLONLAT" && remain >= 2:
+			if fromMember || option.fromLonLat {
+				return nil, reply.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+			}
+			lat, lng, errReply := parseLonLat(args[i+1], args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			option.lat, option.lng = lat, lng
+			option.fromLonLat = true
+			i += 2
+		case arg == "BYRADIUS" && remain >= 2:
+			if option.byRadius || option.byBox {
+				return nil, reply.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+			}
+			radius, err := strconv.ParseFloat(string(args[i+1]), 64)
+			if err != nil || radius < 0 {
+				return nil, reply.MakeErrReply("ERR radius cannot be negative")
+			}
+			unit, errReply := parseGeoUnit(args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			option.radius = radius * unit
+			option.unit = unit
+			option.byRadius = true
+			i += 2
+		case arg == "BYBOX" && remain >= 3:
+			if option.byRadius || option.byBox {
+				return nil, reply.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+			}
+			width, err := strconv.ParseFloat(string(args[i+1]), 64)
+			if err != nil || width < 0 {
+				return nil, reply.MakeErrReply("ERR width or height cannot be negative")
+			}
+			height, err := strconv.ParseFloat(string(args[i+2]), 64)
+			if err != nil || height < 0 {
+				return nil, reply.MakeErrReply("ERR width or height cannot be negative")
+			}
+			unit, errReply := parseGeoUnit(args[i+3])
+			if errReply != nil {
+				return nil, errReply
+			}
+			option.width = width * unit
+			option.height = height * unit
+			option.unit = unit
+			option.byBox = true
+			i += 3
+		case arg == "ASC":
+			option.sort = geoSortAsc
+		case arg == "DESC":
+			option.sort = geoSortDesc
+		case arg == "COUNT" && remain >= 1:
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || count <= 0 {
+				return nil, reply.MakeErrReply("ERR COUNT must be > 0")
+			}
+			option.count = int(count)
+			i++
+			if i+1 < len(args) && strings.ToUpper(string(args[i+1])) == "ANY" {
+				option.any = true
+				i++
+			}
+		case arg == "WITHCOORD" && !storeTarget:
+			option.withCoord = true
+		case arg == "WITHDIST" && !storeTarget:
+			option.withDist = true
+		case arg == "WITHHASH" && !storeTarget:
+			option.withHash = true
+		case arg == "STOREDIST" && storeTarget:
+			option.storeDist = true
+		default:
+			return nil, &reply.SyntaxErrReply{}
+		}
+	}
+	if !fromMember && !option.fromLonLat {
+		return nil, reply.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+	}
+	if !option.byRadius && !option.byBox {
+		return nil, reply.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+	}
+	if option.count > 0 && option.sort == geoSortNone && !option.any {
+		// the nearest members are returned if COUNT is given without ANY
+		option.sort = geoSortAsc
+	}
+	return option, nil
+}
+
+// geoSearch finds members within the area
+func geoSearch(zset *sortedset.SortedSet, option *geoSearchOption) ([]*geoPoint, reply.ErrorReply) {
+	lat, lng := option.lat, option.lng
+	if !option.fromLonLat {
+		score, exists := zset.GetScore(option.fromMember)
+		if !exists {
+			return nil, reply.MakeErrReply("ERR could not decode requested zset member")
+		}
+		lat, lng = geohash.Decode(uint64(score))
+	}
+
+	width, height := option.width, option.height
+	if option.byRadius {
+		width, height = option.radius*2, option.radius*2
+	}
+	points := make([]*geoPoint, 0)
+	for _, cell := range geohash.CoveringCells(lat, lng, width, height) {
+		min, max := cell.ScoreRange()
+		minBorder := &sortedset.ScoreBorder{Value: float64(min)}
+		maxBorder := &sortedset.ScoreBorder{Value: float64(max), Exclude: true}
+		for _, element := range zset.GetByScoreRange(minBorder, maxBorder, 0, -1, false) {
+			hash := uint64(element.Score)
+			pointLat, pointLng := geohash.Decode(hash)
+			var dist float64
+			if option.byRadius {
+				dist = geohash.Distance(lat, lng, pointLat, pointLng)
+				if dist > option.radius {
+					continue
+				}
+			} else {
+				if geohash.LatDistance(lat, pointLat) > option.height/2 ||
+					geohash.Distance(pointLat, lng, pointLat, pointLng) > option.width/2 {
+					continue
+				}
+				dist = geohash.Distance(lat, lng, pointLat, pointLng)
+			}
+			points = append(points, &geoPoint{
+				member: element.Member,
+				hash:   hash,
+				lat:    pointLat,
+				lng:    pointLng,
+				dist:   dist,
+			})
+			if option.any && len(points) >= option.count {
+				break
+			}
+		}
+		if option.any && len(points) >= option.count {
+			break
+		}
+	}
+
+	switch option.sort {
+	case geoSortAsc:
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].dist < points[j].dist
+		})
+	case geoSortDesc:
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].dist > points[j].dist
+		})
+	}
+	if option.count > 0 && len(points) > option.count {
+		points = points[:option.count]
+	}
+	return points, nil
+}
+
+// execGeoSearch returns members within the area of circle or box
+func execGeoSearch(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	option, errReply := parseGeoSearchOption(args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+	points, errReply := geoSearch(zset, option)
+	if errReply != nil {
+		return errReply
+	}
+
+	if !option.withCoord && !option.withDist && !option.withHash {
+		members := make([][]byte, len(points))
+		for i, point := range points {
+			members[i] = []byte(point.member)
+		}
+		return reply.MakeMultiBulkReply(members)
+	}
+	result := make([]resp.Reply, len(points))
+	for i, point := range points {
+		item := []resp.Reply{reply.MakeBulkReply([]byte(point.member))}
+		if option.withDist {
+			item = append(item, reply.MakeBulkReply(formatDistance(point.dist, option.unit)))
+		}
+		if option.withHash {
+			item = append(item, reply.MakeIntReply(int64(point.hash)))
+		}
+		if option.withCoord {
+			item = append(item, coordinateReply(point.lat, point.lng))
+		}
+		result[i] = reply.MakeMultiRawReply(item)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execGeoSearchStore stores the result of GEOSEARCH into destination as a sorted set
+func execGeoSearchStore(db *DB, args [][]byte) resp.Reply {
+	destKey := string(args[0])
+	srcKey := string(args[1])
+	option, errReply := parseGeoSearchOption(args[2:], true)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsZSet(srcKey)
+	if errReply != nil {
+		return errReply
+	}
+	var points []*geoPoint
+	if zset != nil {
+		points, errReply = geoSearch(zset, option)
+		if errReply != nil {
+			return errReply
+		}
+	}
+
+	if len(points) == 0 {
+		_, existed := db.GetEntity(destKey)
+		db.Remove(destKey)
+		db.addAof(utils.ToCmdLine("del", destKey))
+		if existed {
+			db.notifyKeyspaceEvent(notifyGeneric, "del", destKey)
+		}
+		return reply.MakeIntReply(0)
+	}
+	dest := sortedset.Make()
+	for _, point := range points {
+		score := float64(point.hash)
+		if option.storeDist {
+			score = point.dist / option.unit
+		}
+		dest.Add(point.member, score)
+	}
+	db.PutEntity(destKey, &database.DataEntity{
+		Data: dest,
+	})
+	db.addAof(utils.ToCmdLine2("geosearchstore", args...))
+	db.notifyKeyspaceEvent(notifyZSet, "geosearchstore", destKey)
+	db.signalKeyReady(destKey)
+	return reply.MakeIntReply(int64(len(points)))
+}
+
+func init() {
+	RegisterCommand("GeoAdd", execGeoAdd, -5).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("GeoPos", execGeoPos, -2)
+	RegisterCommand("GeoDist", execGeoDist, -4)
+	RegisterCommand("GeoHash", execGeoHash, -2)
+	RegisterCommand("GeoSearch", execGeoSearch, -7)
+	RegisterCommand("GeoSearchStore", execGeoSearchStore, -8).withFlags(flagWrite | flagDenyOOM)
+}
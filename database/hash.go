@@ -0,0 +1,406 @@
+package database
+
+import (
+	Dict "goRedis/datastruct/dict"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// getAsDict gets entity as hash
+func (db *DB) getAsDict(key string) (Dict.Dict, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	dict, ok := entity.Data.(Dict.Dict)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return dict, nil
+}
+
+func (db *DB) getOrInitDict(key string) (dict Dict.Dict, inited bool, errReply reply.ErrorReply) {
+	dict, errReply = db.getAsDict(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if dict == nil {
+		dict = Dict.MakeSimple()
+		db.PutEntity(key, &database.DataEntity{
+			Data: dict,
+		})
+		inited = true
+	}
+	return dict, inited, nil
+}
+
+// execHSet sets field in hash table
+func execHSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 1 {
+		return reply.MakeArgNumErrReply("hset")
+	}
+	key := string(args[0])
+
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	var added int64 = 0
+	for i := 1; i < len(args); i += 2 {
+		field := string(args[i])
+		value := args[i+1]
+		added += int64(dict.Put(field, value))
+	}
+	db.addAof(utils.ToCmdLine2("hset", args...))
+	return reply.MakeIntReply(added)
+}
+
+// execHSetNX sets field in hash table only if field not exists
+func execHSetNX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	value := args[2]
+
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	result := dict.PutIfAbsent(field, value)
+	if result > 0 {
+		db.addAof(utils.ToCmdLine2("hsetnx", args...))
+	}
+	return reply.MakeIntReply(int64(result))
+}
+
+// execHGet gets field value of hash table
+func execHGet(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.NullBulkReply{}
+	}
+
+	raw, exists := dict.Get(field)
+	if !exists {
+		return &reply.NullBulkReply{}
+	}
+	value, _ := raw.([]byte)
+	return reply.MakeBulkReply(value)
+}
+
+// execHMGet gets multi fields in hash table
+func execHMGet(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	size := len(args) - 1
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, size)
+	if dict == nil {
+		return reply.MakeMultiBulkReply(result)
+	}
+
+	for i := 0; i < size; i++ {
+		field := string(args[i+1])
+		value, ok := dict.Get(field)
+		if !ok {
+			result[i] = nil
+		} else {
+			result[i], _ = value.([]byte)
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execHExists checks if a hash field exists
+func execHExists(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	_, exists := dict.Get(field)
+	if exists {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// execHDel deletes a hash field
+func execHDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	deleted := 0
+	for _, field := range args[1:] {
+		deleted += dict.Remove(string(field))
+	}
+	if dict.Len() == 0 {
+		db.Remove(key)
+	}
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine2("hdel", args...))
+	}
+	return reply.MakeIntReply(int64(deleted))
+}
+
+// execHLen gets number of fields in hash table
+func execHLen(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(dict.Len()))
+}
+
+// execHStrLen gets string length of the value of a hash field
+func execHStrLen(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	raw, exists := dict.Get(field)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+	value, _ := raw.([]byte)
+	return reply.MakeIntReply(int64(len(value)))
+}
+
+// execHGetAll gets all key-value entries in hash table
+func execHGetAll(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	result := make([][]byte, 0, dict.Len()*2)
+	dict.ForEach(func(field string, val interface{}) bool {
+		result = append(result, []byte(field), val.([]byte))
+		return true
+	})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execHKeys gets all field names in hash table
+func execHKeys(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	fields := make([][]byte, 0, dict.Len())
+	dict.ForEach(func(field string, val interface{}) bool {
+		fields = append(fields, []byte(field))
+		return true
+	})
+	return reply.MakeMultiBulkReply(fields)
+}
+
+// execHVals gets all field value in hash table
+func execHVals(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	values := make([][]byte, 0, dict.Len())
+	dict.ForEach(func(field string, val interface{}) bool {
+		values = append(values, val.([]byte))
+		return true
+	})
+	return reply.MakeMultiBulkReply(values)
+}
+
+// execHIncrBy increments the integer value of a hash field by the given number
+func execHIncrBy(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	var val int64 = 0
+	raw, exists := dict.Get(field)
+	if exists {
+		val, err = strconv.ParseInt(string(raw.([]byte)), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR hash value is not an integer")
+		}
+	}
+	if (delta > 0 && val > math.MaxInt64-delta) || (delta < 0 && val < math.MinInt64-delta) {
+		return reply.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	val += delta
+	dict.Put(field, []byte(strconv.FormatInt(val, 10)))
+	db.addAof(utils.ToCmdLine2("hincrby", args...))
+	return reply.MakeIntReply(val)
+}
+
+// execHIncrByFloat increments the float value of a hash field by the given number
+func execHIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return reply.MakeErrReply("ERR value is not a valid float")
+	}
+
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	var val float64 = 0
+	raw, exists := dict.Get(field)
+	if exists {
+		val, err = strconv.ParseFloat(string(raw.([]byte)), 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR hash value is not a float")
+		}
+	}
+	val += delta
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result := []byte(strconv.FormatFloat(val, 'f', -1, 64))
+	dict.Put(field, result)
+	// log the final value, so that replaying the aof does not suffer from float rounding
+	db.addAof(utils.ToCmdLine2("hset", args[0], args[1], result))
+	return reply.MakeBulkReply(result)
+}
+
+// execHRandField returns random fields of hash table
+func execHRandField(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	withCount := len(args) >= 2
+	withValues := false
+	var count int64 = 1
+	if withCount {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if len(args) == 3 {
+			if strings.ToUpper(string(args[2])) != "WITHVALUES" {
+				return &reply.SyntaxErrReply{}
+			}
+			withValues = true
+		} else if len(args) > 3 {
+			return &reply.SyntaxErrReply{}
+		}
+	}
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		if withCount {
+			return &reply.EmptyMultiBulkReply{}
+		}
+		return &reply.NullBulkReply{}
+	}
+
+	var fields []string
+	if !withCount {
+		fields = dict.RandomKeys(1)
+		return reply.MakeBulkReply([]byte(fields[0]))
+	}
+	if count >= 0 {
+		// positive count returns distinct fields
+		fields = dict.RandomDistinctKeys(int(count))
+	} else {
+		// negative count allows the same field multiple times
+		fields = dict.RandomKeys(int(-count))
+	}
+
+	result := make([][]byte, 0, len(fields)*2)
+	for _, field := range fields {
+		result = append(result, []byte(field))
+		if withValues {
+			raw, _ := dict.Get(field)
+			result = append(result, raw.([]byte))
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+func init() {
+	RegisterCommand("HSet", execHSet, -4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("HSetNX", execHSetNX, 4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("HGet", execHGet, 3)
+	RegisterCommand("HMGet", execHMGet, -3)
+	RegisterCommand("HExists", execHExists, 3)
+	RegisterCommand("HDel", execHDel, -3).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("HLen", execHLen, 2)
+	RegisterCommand("HStrLen", execHStrLen, 3)
+	RegisterCommand("HGetAll", execHGetAll, 2)
+	RegisterCommand("HKeys", execHKeys, 2)
+	RegisterCommand("HVals", execHVals, 2)
+	RegisterCommand("HIncrBy", execHIncrBy, 4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("HIncrByFloat", execHIncrByFloat, 4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("HRandField", execHRandField, -2)
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"goRedis/datastruct/hyperloglog"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+)
+
+// getAsHyperLogLog gets string value and checks whether it is a HyperLogLog
+func (db *DB) getAsHyperLogLog(key string) ([]byte, reply.ErrorReply) {
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if bytes == nil {
+		return nil, nil
+	}
+	if err := hyperloglog.Validate(bytes); err != nil {
+		return nil, reply.MakeErrReply(err.Error())
+	}
+	return bytes, nil
+}
+
+// execPFAdd adds elements to HyperLogLog, returns 1 if the estimated cardinality may be changed
+func execPFAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	hll, errReply := db.getAsHyperLogLog(key)
+	if errReply != nil {
+		return errReply
+	}
+	created := false
+	if hll == nil {
+		hll = hyperloglog.Make()
+		created = true
+	}
+	hll, updated, err := hyperloglog.Add(hll, args[1:]...)
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	if !created && !updated {
+		return reply.MakeIntReply(0)
+	}
+	db.putStringKeepTTL(key, hll)
+	db.addAof(utils.ToCmdLine2("pfadd", args...))
+	db.notifyKeyspaceEvent(notifyString, "pfadd", key)
+	return reply.MakeIntReply(1)
+}
+
+// execPFCount returns the estimated cardinality of the union of HyperLogLogs
+func execPFCount(db *DB, args [][]byte) resp.Reply {
+	if len(args) == 1 {
+		hll, errReply := db.getAsHyperLogLog(string(args[0]))
+		if errReply != nil {
+			return errReply
+		}
+		if hll == nil {
+			return reply.MakeIntReply(0)
+		}
+		// the cached cardinality in header is refreshed, it does not change the value logically
+		count, err := hyperloglog.Count(hll)
+		if err != nil {
+			return reply.MakeErrReply(err.Error())
+		}
+		return reply.MakeIntReply(int64(count))
+	}
+
+	registers, errReply := db.mergeHyperLogLogs(bytesToKeys(args))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeIntReply(int64(hyperloglog.CountRegisters(registers)))
+}
+
+// mergeHyperLogLogs returns the registers of union of HyperLogLogs, missing keys are skipped
+func (db *DB) mergeHyperLogLogs(keys []string) ([]uint8, reply.ErrorReply) {
+	registers := make([]uint8, hyperloglog.RegisterCount)
+	for _, key := range keys {
+		hll, errReply := db.getAsHyperLogLog(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if hll == nil {
+			continue
+		}
+		if err := hyperloglog.MergeRegisters(registers, hll); err != nil {
+			return nil, reply.MakeErrReply(err.Error())
+		}
+	}
+	return registers, nil
+}
+
+// execPFMerge merges HyperLogLogs into destkey
+func execPFMerge(db *DB, args [][]byte) resp.Reply {
+	destKey := string(args[0])
+	keys := bytesToKeys(args)
+
+	// the result is dense if any of the inputs is dense
+	dense := false
+	for _, key := range keys {
+		hll, errReply := db.getAsHyperLogLog(key)
+		if errReply != nil {
+			return errReply
+		}
+		if hll != nil && hyperloglog.IsDense(hll) {
+			dense = true
+		}
+	}
+	registers, errReply := db.mergeHyperLogLogs(keys)
+	if errReply != nil {
+		return errReply
+	}
+	db.putStringKeepTTL(destKey, hyperloglog.FromRegisters(registers, dense))
+	db.addAof(utils.ToCmdLine2("pfmerge", args...))
+	db.notifyKeyspaceEvent(notifyString, "pfadd", destKey)
+	return &reply.OkReply{}
+}
+
+func init() {
+	RegisterCommand("PFAdd", execPFAdd, -2).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("PFCount", execPFCount, -2)
+	RegisterCommand("PFMerge", execPFMerge, -2).withFlags(flagWrite | flagDenyOOM)
+}
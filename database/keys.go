@@ -1,26 +1,53 @@
 package database
 
 import (
+	Dict "goRedis/datastruct/dict"
+	List "goRedis/datastruct/list"
+	HashSet "goRedis/datastruct/set"
 	"goRedis/datastruct/sortedset"
+	"goRedis/datastruct/stream"
+	"goRedis/interface/database"
 	"goRedis/interface/resp"
 	"goRedis/lib/utils"
 	"goRedis/lib/wildcard"
 	"goRedis/resp/reply"
+	"math"
 	"strconv"
-	"time"
+	"strings"
 )
 
 // execDel removes a key from db
 func execDel(db *DB, args [][]byte) resp.Reply {
-	keys := make([]string, len(args))
-	for i, v := range args {
-		keys[i] = string(v)
+	return delGeneric(db, args, "del", db.freer.isLazy(lazyfreeUserDel))
+}
+
+// execUnlink removes keys like DEL, but large values are freed in background
+func execUnlink(db *DB, args [][]byte) resp.Reply {
+	return delGeneric(db, args, "unlink", true)
+}
+
+// delGeneric is the implementation of DEL and UNLINK, values are freed lazily if lazy is set
+func delGeneric(db *DB, args [][]byte, cmdName string, lazy bool) resp.Reply {
+	deletedKeys := make([]string, 0, len(args))
+	for _, arg := range args {
+		key := string(arg)
+		if _, exists := db.keyspace().data.Get(key); !exists {
+			continue
+		}
+		if lazy {
+			db.Unlink(key)
+		} else {
+			db.Remove(key)
+		}
+		deletedKeys = append(deletedKeys, key)
 	}
-	deleted := db.Removes(keys...)
-	if deleted > 0 {
-		db.addAof(utils.ToCmdLine2("del", args...))
+	if len(deletedKeys) > 0 {
+		db.addAof(utils.ToCmdLine2(cmdName, args...))
 	}
-	return reply.MakeIntReply(int64(deleted))
+	for _, key := range deletedKeys {
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(int64(len(deletedKeys)))
 }
 
 // execExists checks if a is existed in db
@@ -28,7 +55,7 @@ func execExists(db *DB, args [][]byte) resp.Reply {
 	result := int64(0)
 	for _, arg := range args {
 		key := string(arg)
-		_, exists := db.GetEntity(key)
+		_, exists := db.peekEntity(key)
 		if exists {
 			result++
 		}
@@ -38,25 +65,103 @@ func execExists(db *DB, args [][]byte) resp.Reply {
 
 // execFlushDB removes all data in current db
 func execFlushDB(db *DB, args [][]byte) resp.Reply {
-	db.Flush()
+	async, errReply := parseFlushMode(args, db.freer)
+	if errReply != nil {
+		return errReply
+	}
+	db.flush(async)
 	db.addAof(utils.ToCmdLine2("flushdb", args...))
 	return &reply.OkReply{}
 }
 
+// execDBSize returns the number of keys in db
+func execDBSize(db *DB, args [][]byte) resp.Reply {
+	return reply.MakeIntReply(int64(db.keyspace().data.Len()))
+}
+
+// maxRandomKeyTries limits retries of randomkey when expired keys are picked
+const maxRandomKeyTries = 100
+
+// execRandomKey returns a random key of db
+func execRandomKey(db *DB, args [][]byte) resp.Reply {
+	for i := 0; i < maxRandomKeyTries; i++ {
+		keys := db.keyspace().data.RandomKeys(1)
+		if len(keys) == 0 {
+			break
+		}
+		// expired keys are removed by peekEntity, then pick again
+		if _, exists := db.peekEntity(keys[0]); exists {
+			return reply.MakeBulkReply([]byte(keys[0]))
+		}
+	}
+	return &reply.NullBulkReply{}
+}
+
 // execType returns the type of entity, including: string, list, hash, set and zset
 func execType(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
-	entity, exists := db.GetEntity(key)
+	entity, exists := db.peekEntity(key)
 	if !exists {
 		return reply.MakeStatusReply("none")
 	}
+	name := typeName(entity)
+	if name == "" {
+		return &reply.UnknownErrReply{}
+	}
+	return reply.MakeStatusReply(name)
+}
+
+// typeName returns the type of entity reported by TYPE command
+func typeName(entity *database.DataEntity) string {
 	switch entity.Data.(type) {
 	case []byte:
-		return reply.MakeStatusReply("string")
+		return "string"
+	case List.List:
+		return "list"
+	case Dict.Dict:
+		return "hash"
+	case *HashSet.Set:
+		return "set"
+	case *sortedset.SortedSet:
+		return "zset"
+	case *stream.Stream:
+		return "stream"
+	}
+	return ""
+}
+
+// copyData returns a deep copy of the value, so that modifying the copy does not affect the origin
+func copyData(data interface{}) interface{} {
+	switch val := data.(type) {
+	case []byte:
+		return append([]byte(nil), val...)
+	case List.List:
+		list := List.NewQuickList()
+		val.ForEach(func(i int, v interface{}) bool {
+			list.Add(v)
+			return true
+		})
+		return list
+	case Dict.Dict:
+		dict := Dict.MakeSimple()
+		val.ForEach(func(key string, v interface{}) bool {
+			dict.Put(key, v)
+			return true
+		})
+		return dict
+	case *HashSet.Set:
+		return val.Union(HashSet.Make())
 	case *sortedset.SortedSet:
-		return reply.MakeStatusReply("zset")
+		zset := sortedset.Make()
+		val.ForEach(func(element *sortedset.Element) bool {
+			zset.Add(element.Member, element.Score)
+			return true
+		})
+		return zset
+	case *stream.Stream:
+		return val.Clone()
 	}
-	return &reply.UnknownErrReply{}
+	return data
 }
 
 // execRename a key
@@ -71,9 +176,13 @@ func execRename(db *DB, args [][]byte) resp.Reply {
 	if !ok {
 		return reply.MakeErrReply("no such key")
 	}
-	db.PutEntity(dest, entity)
+	// remove src first, so that renaming a key to itself keeps it
 	db.Remove(src)
+	db.PutEntity(dest, entity)
 	db.addAof(utils.ToCmdLine2("rename", args...))
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_to", dest)
+	db.signalKeyReady(dest)
 	return &reply.OkReply{}
 }
 
@@ -94,6 +203,9 @@ func execRenameNx(db *DB, args [][]byte) resp.Reply {
 	db.Removes(src, dest) // clean src and dest with their ttl
 	db.PutEntity(dest, entity)
 	db.addAof(utils.ToCmdLine2("renamenx", args...))
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_to", dest)
+	db.signalKeyReady(dest)
 	return reply.MakeIntReply(1)
 }
 
@@ -101,7 +213,7 @@ func execRenameNx(db *DB, args [][]byte) resp.Reply {
 func execKeys(db *DB, args [][]byte) resp.Reply {
 	pattern := wildcard.CompilePattern(string(args[0]))
 	result := make([][]byte, 0)
-	db.data.ForEach(func(key string, val interface{}) bool {
+	db.keyspace().data.ForEach(func(key string, val interface{}) bool {
 		if pattern.IsMatch(key) {
 			result = append(result, []byte(key))
 		}
@@ -110,44 +222,107 @@ func execKeys(db *DB, args [][]byte) resp.Reply {
 	return reply.MakeMultiBulkReply(result)
 }
 
-// execExpire sets expiration time for the given key
-func execExpire(db *DB, args [][]byte) resp.Reply {
+// expireGeneric is the underlying implementation of expire, pexpire, expireat and pexpireat,
+// unit is one of EX, PX, EXAT and PXAT
+func expireGeneric(db *DB, args [][]byte, unit string, cmdName string) resp.Reply {
 	key := string(args[0])
-
-	// Parse seconds
-	seconds, err := strconv.ParseInt(string(args[1]), 10, 64)
+	val, err := strconv.ParseInt(string(args[1]), 10, 64)
 	if err != nil {
 		return reply.MakeErrReply("ERR value is not an integer or out of range")
 	}
-	if seconds <= 0 {
-		// Remove key if given a non-positive expiration time
-		db.Remove(key)
-		return reply.MakeIntReply(1)
+
+	// parse options
+	var nx, xx, gt, lt bool
+	for _, arg := range args[2:] {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		default:
+			return reply.MakeErrReply("ERR Unsupported option " + string(arg))
+		}
+	}
+	if nx && (xx || gt || lt) {
+		return reply.MakeErrReply("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if gt && lt {
+		return reply.MakeErrReply("ERR GT and LT options at the same time are not compatible")
+	}
+
+	// calculate expiration time in milliseconds
+	errReply := reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	expireAt := val
+	if unit == "EX" || unit == "EXAT" {
+		if expireAt > math.MaxInt64/1000 || expireAt < math.MinInt64/1000 {
+			return errReply
+		}
+		expireAt *= 1000
+	}
+	if unit == "EX" || unit == "PX" {
+		now := nowMillis()
+		if expireAt > math.MaxInt64-now {
+			return errReply
+		}
+		expireAt += now
 	}
 
-	// Get entity
 	entity, exists := db.GetEntity(key)
 	if !exists {
 		return reply.MakeIntReply(0)
 	}
+	// a key without ttl is regarded as having infinite ttl by GT and LT
+	current := entity.ExpireTime
+	if (nx && current > 0) || (xx && current == 0) ||
+		(gt && (current == 0 || expireAt <= current)) ||
+		(lt && current > 0 && expireAt >= current) {
+		return reply.MakeIntReply(0)
+	}
 
-	// Calculate expiration time
-	expireTime := time.Now().UnixNano()/1e6 + seconds*1000 // Convert to milliseconds
-	entity.ExpireTime = expireTime
+	if expireAt <= nowMillis() {
+		// Remove key if given an expiration time in the past, freed lazily like an expired key
+		db.removeKey(key, lazyfreeExpire)
+		db.addAof(utils.ToCmdLine("del", key))
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+		return reply.MakeIntReply(1)
+	}
+	db.Expire(key, expireAt)
+	// record absolute timestamp so that replaying gets the same expiration
+	db.addAof(utils.ToCmdLine("pexpireat", key, strconv.FormatInt(expireAt, 10)))
+	db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+	return reply.MakeIntReply(1)
+}
 
-	// Store the key back
-	db.PutEntity(key, entity)
+// execExpire sets expiration time for the given key in seconds
+func execExpire(db *DB, args [][]byte) resp.Reply {
+	return expireGeneric(db, args, "EX", "expire")
+}
 
-	db.addAof(utils.ToCmdLine2("expire", args...))
-	return reply.MakeIntReply(1)
+// execPExpire sets expiration time for the given key in milliseconds
+func execPExpire(db *DB, args [][]byte) resp.Reply {
+	return expireGeneric(db, args, "PX", "pexpire")
 }
 
-// execTTL returns the remaining time to live of a key
-func execTTL(db *DB, args [][]byte) resp.Reply {
+// execExpireAt sets expiration time for the given key as unix timestamp in seconds
+func execExpireAt(db *DB, args [][]byte) resp.Reply {
+	return expireGeneric(db, args, "EXAT", "expireat")
+}
+
+// execPExpireAt sets expiration time for the given key as unix timestamp in milliseconds
+func execPExpireAt(db *DB, args [][]byte) resp.Reply {
+	return expireGeneric(db, args, "PXAT", "pexpireat")
+}
+
+// ttlGeneric returns the remaining time to live of a key, or the absolute expiration time if absolute is set
+func ttlGeneric(db *DB, args [][]byte, inMillis bool, absolute bool) resp.Reply {
 	key := string(args[0])
 
 	// Get entity
-	entity, exists := db.GetEntity(key)
+	entity, exists := db.peekEntity(key)
 	if !exists {
 		return reply.MakeIntReply(-2) // Key does not exist
 	}
@@ -157,28 +332,74 @@ func execTTL(db *DB, args [][]byte) resp.Reply {
 		return reply.MakeIntReply(-1) // No expiration
 	}
 
-	// Calculate remaining time
-	now := time.Now().UnixNano() / 1e6 // current time in milliseconds
-	remaining := entity.ExpireTime - now
-
-	if remaining <= 0 {
-		// Key has expired
-		db.Remove(key)
-		return reply.MakeIntReply(-2)
+	if absolute {
+		if inMillis {
+			return reply.MakeIntReply(entity.ExpireTime)
+		}
+		return reply.MakeIntReply(entity.ExpireTime / 1000)
+	}
+	remaining := entity.ExpireTime - nowMillis()
+	if remaining < 0 {
+		remaining = 0
 	}
+	if inMillis {
+		return reply.MakeIntReply(remaining)
+	}
+	// round to the nearest second like redis
+	return reply.MakeIntReply((remaining + 500) / 1000)
+}
+
+// execTTL returns the remaining time to live of a key in seconds
+func execTTL(db *DB, args [][]byte) resp.Reply {
+	return ttlGeneric(db, args, false, false)
+}
+
+// execPTTL returns the remaining time to live of a key in milliseconds
+func execPTTL(db *DB, args [][]byte) resp.Reply {
+	return ttlGeneric(db, args, true, false)
+}
 
-	// Return remaining seconds
-	return reply.MakeIntReply(remaining / 1000) // Convert from milliseconds to seconds
+// execExpireTime returns the expiration time of a key as unix timestamp in seconds
+func execExpireTime(db *DB, args [][]byte) resp.Reply {
+	return ttlGeneric(db, args, false, true)
+}
+
+// execPExpireTime returns the expiration time of a key as unix timestamp in milliseconds
+func execPExpireTime(db *DB, args [][]byte) resp.Reply {
+	return ttlGeneric(db, args, true, true)
+}
+
+// execPersist removes the expiration of a key
+func execPersist(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	entity, exists := db.GetEntity(key)
+	if !exists || entity.ExpireTime == 0 {
+		return reply.MakeIntReply(0)
+	}
+	db.Persist(key)
+	db.addAof(utils.ToCmdLine2("persist", args...))
+	db.notifyKeyspaceEvent(notifyGeneric, "persist", key)
+	return reply.MakeIntReply(1)
 }
 
 func init() {
-	RegisterCommand("Del", execDel, -2)
+	RegisterCommand("Del", execDel, -2).withFlags(flagWrite)
+	RegisterCommand("Unlink", execUnlink, -2).withFlags(flagWrite)
 	RegisterCommand("Exists", execExists, -2)
 	RegisterCommand("Keys", execKeys, 2)
-	RegisterCommand("FlushDB", execFlushDB, -1)
+	RegisterCommand("FlushDB", execFlushDB, -1).withFlags(flagWrite)
+	RegisterCommand("DBSize", execDBSize, 1)
+	RegisterCommand("RandomKey", execRandomKey, 1)
 	RegisterCommand("Type", execType, 2)
-	RegisterCommand("Rename", execRename, 3)
-	RegisterCommand("RenameNx", execRenameNx, 3)
-	RegisterCommand("Expire", execExpire, 3)
+	RegisterCommand("Rename", execRename, 3).withFlags(flagWrite)
+	RegisterCommand("RenameNx", execRenameNx, 3).withFlags(flagWrite)
+	RegisterCommand("Expire", execExpire, -3).withFlags(flagWrite)
+	RegisterCommand("PExpire", execPExpire, -3).withFlags(flagWrite)
+	RegisterCommand("ExpireAt", execExpireAt, -3).withFlags(flagWrite)
+	RegisterCommand("PExpireAt", execPExpireAt, -3).withFlags(flagWrite)
 	RegisterCommand("TTL", execTTL, 2)
+	RegisterCommand("PTTL", execPTTL, 2)
+	RegisterCommand("ExpireTime", execExpireTime, 2)
+	RegisterCommand("PExpireTime", execPExpireTime, 2)
+	RegisterCommand("Persist", execPersist, 2).withFlags(flagWrite)
 }
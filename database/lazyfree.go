@@ -0,0 +1,226 @@
+package database
+
+import (
+	"goRedis/config"
+	Dict "goRedis/datastruct/dict"
+	List "goRedis/datastruct/list"
+	HashSet "goRedis/datastruct/set"
+	"goRedis/datastruct/sortedset"
+	"goRedis/datastruct/stream"
+	"goRedis/interface/database"
+	"goRedis/resp/reply"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// lazyfreeThreshold is the free effort above which a value is freed in background, the same as redis
+	lazyfreeThreshold = 64
+	lazyfreeQueueSize = 1024
+)
+
+// lazyFreer frees values and keyspaces detached from databases in a background goroutine.
+// In go a value is freed by dropping the last reference to it, so the freer holds the reference
+// until its goroutine picks the value up, and a detached keyspace is cleared by the freer
+// instead of the client goroutine.
+type lazyFreer struct {
+	jobs chan interface{}
+	stop chan struct{}
+	// objects queued but not freed yet, and objects freed in background, accessed atomically
+	pending int64
+	freed   int64
+
+	// free lazily values removed by eviction, expiration, DEL and FLUSHDB/FLUSHALL without SYNC
+	lazyEviction  bool
+	lazyExpire    bool
+	lazyUserDel   bool
+	lazyUserFlush bool
+}
+
+// makeLazyFreer creates a lazyFreer from config and starts its goroutine
+func makeLazyFreer() *lazyFreer {
+	freer := &lazyFreer{
+		jobs:          make(chan interface{}, lazyfreeQueueSize),
+		stop:          make(chan struct{}),
+		lazyEviction:  config.Properties.LazyFreeLazyEviction,
+		lazyExpire:    config.Properties.LazyFreeLazyExpire,
+		lazyUserDel:   config.Properties.LazyFreeLazyUserDel,
+		lazyUserFlush: config.Properties.LazyFreeLazyUserFlush,
+	}
+	go freer.loop()
+	return freer
+}
+
+func (freer *lazyFreer) loop() {
+	for {
+		select {
+		case data := <-freer.jobs:
+			freeValue(data)
+			atomic.AddInt64(&freer.pending, -1)
+			atomic.AddInt64(&freer.freed, 1)
+		case <-freer.stop:
+			return
+		}
+	}
+}
+
+// close stops the goroutine, values still queued are left to the go runtime
+func (freer *lazyFreer) close() {
+	close(freer.stop)
+}
+
+// pendingObjects returns the number of values waiting to be freed
+func (freer *lazyFreer) pendingObjects() int64 {
+	if freer == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&freer.pending)
+}
+
+// freedObjects returns the number of values freed in background
+func (freer *lazyFreer) freedObjects() int64 {
+	if freer == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&freer.freed)
+}
+
+// lazyfree reasons, each is enabled by a lazyfree-lazy-* option
+const (
+	lazyfreeEviction = iota
+	lazyfreeExpire
+	lazyfreeUserDel
+	lazyfreeUserFlush
+)
+
+// isLazy returns whether values removed for the reason are freed in background
+func (freer *lazyFreer) isLazy(reason int) bool {
+	if freer == nil {
+		return false
+	}
+	switch reason {
+	case lazyfreeEviction:
+		return freer.lazyEviction
+	case lazyfreeExpire:
+		return freer.lazyExpire
+	case lazyfreeUserDel:
+		return freer.lazyUserDel
+	default:
+		return freer.lazyUserFlush
+	}
+}
+
+// free queues the value, or frees it at once if it is small, the queue is full or freer is nil
+func (freer *lazyFreer) free(data interface{}) {
+	if freer == nil || freeEffort(data) <= lazyfreeThreshold {
+		freeValue(data)
+		return
+	}
+	atomic.AddInt64(&freer.pending, 1)
+	select {
+	case freer.jobs <- data:
+	default:
+		atomic.AddInt64(&freer.pending, -1)
+		freeValue(data)
+	}
+}
+
+// freeEffort returns the number of allocations of the value, which is the work to free it
+func freeEffort(data interface{}) int {
+	switch val := data.(type) {
+	case List.List:
+		return val.Len()
+	case Dict.Dict:
+		return val.Len()
+	case *HashSet.Set:
+		return val.Len()
+	case *sortedset.SortedSet:
+		return int(val.Len())
+	case *keyspace:
+		return val.data.Len()
+	case *stream.Stream:
+		effort := val.Len()
+		for _, group := range val.Groups() {
+			effort += 1 + group.PendingCount()
+		}
+		return effort
+	}
+	return 1
+}
+
+// freeValue drops the value and returns the effort.
+// A detached keyspace is cleared here, so that its shards are released even if a command
+// which loaded it before the flush still holds it.
+func freeValue(data interface{}) int {
+	effort := freeEffort(data)
+	if ks, ok := data.(*keyspace); ok {
+		ks.data.Clear()
+		ks.ttlKeys.Clear()
+		atomic.StoreInt64(&ks.memory, 0)
+	}
+	return effort
+}
+
+// freeRemoved frees the value of an entity removed from db, in background if it is enabled for the reason
+func (db *DB) freeRemoved(entity *database.DataEntity, reason int) {
+	if db.freer.isLazy(reason) {
+		db.freer.free(entity.Data)
+	}
+}
+
+// removeKey removes the key like Remove, the value is freed lazily if it is enabled for the reason
+func (db *DB) removeKey(key string, reason int) {
+	entity := db.keyspace().rawEntity(key)
+	db.Remove(key)
+	if entity != nil {
+		db.freeRemoved(entity, reason)
+	}
+}
+
+// Unlink removes the key like Remove, but the value is freed in background if it is large
+func (db *DB) Unlink(key string) {
+	entity := db.keyspace().rawEntity(key)
+	db.Remove(key)
+	if entity != nil {
+		db.freer.free(entity.Data)
+	}
+}
+
+// detachKeyspace replaces the keyspace with an empty one, with the same synchronization as SWAPDB
+func (db *DB) detachKeyspace() *keyspace {
+	db.spaceMu.Lock()
+	defer db.spaceMu.Unlock()
+	return db.space.Swap(makeKeyspace())
+}
+
+// FlushAsync detaches the keyspace at once and frees it in background
+func (db *DB) FlushAsync() {
+	db.freer.free(db.detachKeyspace())
+}
+
+// flush removes all keys, the keyspace is freed in background if async is set
+func (db *DB) flush(async bool) {
+	if async {
+		db.FlushAsync()
+	} else {
+		db.Flush()
+	}
+}
+
+// parseFlushMode parses the ASYNC or SYNC option of FLUSHDB and FLUSHALL,
+// the default mode is async if lazyfree-lazy-user-flush is set
+func parseFlushMode(args [][]byte, freer *lazyFreer) (bool, reply.ErrorReply) {
+	if len(args) > 1 {
+		return false, &reply.SyntaxErrReply{}
+	}
+	if len(args) == 0 {
+		return freer.isLazy(lazyfreeUserFlush), nil
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "ASYNC":
+		return true, nil
+	case "SYNC":
+		return false, nil
+	}
+	return false, &reply.SyntaxErrReply{}
+}
@@ -0,0 +1,790 @@
+package database
+
+import (
+	List "goRedis/datastruct/list"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+// getAsList gets entity as list
+func (db *DB) getAsList(key string) (List.List, reply.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	list, ok := entity.Data.(List.List)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return list, nil
+}
+
+func (db *DB) getOrInitList(key string) (list List.List, isNew bool, errReply reply.ErrorReply) {
+	list, errReply = db.getAsList(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	isNew = false
+	if list == nil {
+		list = List.NewQuickList()
+		db.PutEntity(key, &database.DataEntity{
+			Data: list,
+		})
+		isNew = true
+	}
+	return list, isNew, nil
+}
+
+// removeIfEmptyList drops the key once the list has no element, the same as redis does
+func (db *DB) removeIfEmptyList(key string, list List.List) {
+	if list.Len() == 0 {
+		db.Remove(key)
+	}
+}
+
+func listEquals(value []byte) List.Expected {
+	return func(a interface{}) bool {
+		return utils.BytesEquals(a.([]byte), value)
+	}
+}
+
+// normalizeListIndex converts a possibly negative index into an index from head, returns false if out of range
+func normalizeListIndex(index int64, size int) (int, bool) {
+	if index < 0 {
+		index = int64(size) + index
+	}
+	if index < 0 || index >= int64(size) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// execLPush inserts elements at the head of list
+func execLPush(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	values := args[1:]
+
+	list, _, errReply := db.getOrInitList(key)
+	if errReply != nil {
+		return errReply
+	}
+	for _, value := range values {
+		list.Insert(0, value)
+	}
+
+	db.addAof(utils.ToCmdLine2("lpush", args...))
+	// the length is taken before blocked clients are served
+	size := list.Len()
+	db.signalKeyReady(key)
+	return reply.MakeIntReply(int64(size))
+}
+
+// execLPushX inserts elements at the head of list, only if the list exists
+func execLPushX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	values := args[1:]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return reply.MakeIntReply(0)
+	}
+	for _, value := range values {
+		list.Insert(0, value)
+	}
+	db.addAof(utils.ToCmdLine2("lpushx", args...))
+	return reply.MakeIntReply(int64(list.Len()))
+}
+
+// execRPush inserts elements at the tail of list
+func execRPush(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	values := args[1:]
+
+	list, _, errReply := db.getOrInitList(key)
+	if errReply != nil {
+		return errReply
+	}
+	for _, value := range values {
+		list.Add(value)
+	}
+
+	db.addAof(utils.ToCmdLine2("rpush", args...))
+	// the length is taken before blocked clients are served
+	size := list.Len()
+	db.signalKeyReady(key)
+	return reply.MakeIntReply(int64(size))
+}
+
+// execRPushX inserts elements at the tail of list, only if the list exists
+func execRPushX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	values := args[1:]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return reply.MakeIntReply(0)
+	}
+	for _, value := range values {
+		list.Add(value)
+	}
+	db.addAof(utils.ToCmdLine2("rpushx", args...))
+	return reply.MakeIntReply(int64(list.Len()))
+}
+
+// execLPop removes the first elements of list
+func execLPop(db *DB, args [][]byte) resp.Reply {
+	return popList(db, args, "lpop", false)
+}
+
+// execRPop removes the last elements of list
+func execRPop(db *DB, args [][]byte) resp.Reply {
+	return popList(db, args, "rpop", true)
+}
+
+// popList is the underlying implementation of lpop and rpop
+func popList(db *DB, args [][]byte, cmdName string, fromTail bool) resp.Reply {
+	if len(args) > 2 {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+	key := string(args[0])
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || n < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(n)
+	}
+
+	popped, errReply := db.popListElements(key, fromTail, count)
+	if errReply != nil {
+		return errReply
+	}
+	if popped == nil {
+		if withCount {
+			return &reply.NullMultiBulkReply{}
+		}
+		return &reply.NullBulkReply{}
+	}
+	if !withCount {
+		return reply.MakeBulkReply(popped[0])
+	}
+	return reply.MakeMultiBulkReply(popped)
+}
+
+// execLLen gets length of list
+func execLLen(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(list.Len()))
+}
+
+// execLIndex gets element of list at given index
+func execLIndex(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return &reply.NullBulkReply{}
+	}
+
+	i, ok := normalizeListIndex(index, list.Len())
+	if !ok {
+		return &reply.NullBulkReply{}
+	}
+	return reply.MakeBulkReply(list.Get(i).([]byte))
+}
+
+// execLSet puts element at given index of list
+func execLSet(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	value := args[2]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return reply.MakeErrReply("ERR no such key")
+	}
+
+	i, ok := normalizeListIndex(index, list.Len())
+	if !ok {
+		return reply.MakeErrReply("ERR index out of range")
+	}
+	list.Set(i, value)
+	db.addAof(utils.ToCmdLine2("lset", args...))
+	return &reply.OkReply{}
+}
+
+// execLRange gets elements of list in given range
+func execLRange(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	// compute index
+	size := int64(list.Len())
+	if start < 0 {
+		start = size + start
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop || start >= size {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	slice := list.Range(int(start), int(stop)+1)
+	result := make([][]byte, len(slice))
+	for i, raw := range slice {
+		result[i] = raw.([]byte)
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execLInsert inserts element before or after the pivot
+func execLInsert(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	where := strings.ToUpper(string(args[1]))
+	if where != "BEFORE" && where != "AFTER" {
+		return &reply.SyntaxErrReply{}
+	}
+	pivot := args[2]
+	value := args[3]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	pivotIndex := -1
+	list.ForEach(func(i int, v interface{}) bool {
+		if utils.BytesEquals(v.([]byte), pivot) {
+			pivotIndex = i
+			return false
+		}
+		return true
+	})
+	if pivotIndex < 0 {
+		return reply.MakeIntReply(-1)
+	}
+	if where == "AFTER" {
+		pivotIndex++
+	}
+	list.Insert(pivotIndex, value)
+	db.addAof(utils.ToCmdLine2("linsert", args...))
+	return reply.MakeIntReply(int64(list.Len()))
+}
+
+// execLRem removes element of list
+func execLRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	value := args[2]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	var removed int
+	if count == 0 {
+		removed = list.RemoveAllByVal(listEquals(value))
+	} else if count > 0 {
+		removed = list.RemoveByVal(listEquals(value), int(count))
+	} else {
+		removed = list.ReverseRemoveByVal(listEquals(value), int(-count))
+	}
+	db.removeIfEmptyList(key, list)
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine2("lrem", args...))
+	}
+	return reply.MakeIntReply(int64(removed))
+}
+
+// execLTrim trims list so that it only contains elements in given range
+func execLTrim(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return &reply.OkReply{}
+	}
+
+	size := int64(list.Len())
+	if start < 0 {
+		start = size + start
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop || start >= size {
+		// empty range, remove the whole list
+		db.Remove(key)
+	} else {
+		for i := stop + 1; i < size; i++ {
+			list.RemoveLast()
+		}
+		for i := int64(0); i < start; i++ {
+			list.Remove(0)
+		}
+	}
+	db.addAof(utils.ToCmdLine2("ltrim", args...))
+	return &reply.OkReply{}
+}
+
+// execLPos returns the index of matching elements
+func execLPos(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	value := args[1]
+
+	// parse options
+	var rank int64 = 1
+	var count int64 = -1 // -1 means return a single index rather than an array
+	var maxLen int64 = 0
+	for i := 2; i < len(args); i += 2 {
+		arg := strings.ToUpper(string(args[i]))
+		if i+1 >= len(args) {
+			return &reply.SyntaxErrReply{}
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		switch arg {
+		case "RANK":
+			if n == 0 {
+				return reply.MakeErrReply("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return reply.MakeErrReply("ERR COUNT can't be negative")
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return reply.MakeErrReply("ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		if count >= 0 {
+			return &reply.EmptyMultiBulkReply{}
+		}
+		return &reply.NullBulkReply{}
+	}
+
+	positions := make([]int, 0)
+	skip := rank - 1 // matches to skip before collecting
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	var scanned int64 = 0
+	consumer := func(i int, v interface{}) bool {
+		if maxLen > 0 && scanned >= maxLen {
+			return false
+		}
+		scanned++
+		if !utils.BytesEquals(v.([]byte), value) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		positions = append(positions, i)
+		if count < 0 {
+			return false
+		}
+		// COUNT 0 means returning all matches
+		return count == 0 || int64(len(positions)) < count
+	}
+	if rank > 0 {
+		list.ForEach(consumer)
+	} else {
+		list.ReverseForEach(consumer)
+	}
+
+	if count < 0 {
+		if len(positions) == 0 {
+			return &reply.NullBulkReply{}
+		}
+		return reply.MakeIntReply(int64(positions[0]))
+	}
+	result := make([][]byte, len(positions))
+	for i, pos := range positions {
+		result[i] = []byte(strconv.Itoa(pos))
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execLMove atomically pops an element from source and pushes it to destination
+func execLMove(db *DB, args [][]byte) resp.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	srcSide := strings.ToUpper(string(args[2]))
+	destSide := strings.ToUpper(string(args[3]))
+	if !isListSide(srcSide) || !isListSide(destSide) {
+		return &reply.SyntaxErrReply{}
+	}
+
+	val, errReply := db.moveListElement(src, dest, srcSide, destSide)
+	if errReply != nil {
+		return errReply
+	}
+	if val == nil {
+		return &reply.NullBulkReply{}
+	}
+	db.signalKeyReady(dest)
+	return reply.MakeBulkReply(val)
+}
+
+func isListSide(side string) bool {
+	return side == "LEFT" || side == "RIGHT"
+}
+
+// moveListElement pops an element from source and pushes it to destination, returns nil if source is empty
+func (db *DB) moveListElement(src, dest, srcSide, destSide string) ([]byte, reply.ErrorReply) {
+	srcList, errReply := db.getAsList(src)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if srcList == nil {
+		return nil, nil
+	}
+	// check type of destination before modifying source
+	destList, errReply := db.getAsList(dest)
+	if errReply != nil {
+		return nil, errReply
+	}
+
+	var val interface{}
+	if srcSide == "LEFT" {
+		val = srcList.Remove(0)
+	} else {
+		val = srcList.RemoveLast()
+	}
+	if destList == nil {
+		destList, _, _ = db.getOrInitList(dest)
+	}
+	if destSide == "LEFT" {
+		destList.Insert(0, val)
+	} else {
+		destList.Add(val)
+	}
+	// source and destination may be the same key, so check emptiness after pushing
+	db.removeIfEmptyList(src, srcList)
+
+	db.addAof(utils.ToCmdLine("lmove", src, dest, srcSide, destSide))
+	return val.([]byte), nil
+}
+
+// popListElements pops at most count elements from the list, returns nil if the list does not exist
+func (db *DB) popListElements(key string, fromTail bool, count int) ([][]byte, reply.ErrorReply) {
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if list == nil {
+		return nil, nil
+	}
+	if count > list.Len() {
+		count = list.Len()
+	}
+	popped := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		var val interface{}
+		if fromTail {
+			val = list.RemoveLast()
+		} else {
+			val = list.Remove(0)
+		}
+		popped = append(popped, val.([]byte))
+	}
+	db.removeIfEmptyList(key, list)
+
+	if count > 0 {
+		cmdName := "lpop"
+		if fromTail {
+			cmdName = "rpop"
+		}
+		db.addAof(utils.ToCmdLine(cmdName, key, strconv.Itoa(count)))
+	}
+	return popped, nil
+}
+
+// checkListKeys returns error if any of the keys holds a non-list value
+func (db *DB) checkListKeys(keys []string) reply.ErrorReply {
+	for _, key := range keys {
+		if _, errReply := db.getAsList(key); errReply != nil {
+			return errReply
+		}
+	}
+	return nil
+}
+
+// execBLPop removes the first element of the first non-empty list, blocks until timeout if all lists are empty
+func execBLPop(db *DB, args [][]byte) resp.Reply {
+	return blockingPopList(db, args, false)
+}
+
+// execBRPop removes the last element of the first non-empty list, blocks until timeout if all lists are empty
+func execBRPop(db *DB, args [][]byte) resp.Reply {
+	return blockingPopList(db, args, true)
+}
+
+// blockingPopList is the underlying implementation of blpop and brpop
+func blockingPopList(db *DB, args [][]byte, fromTail bool) resp.Reply {
+	keys := bytesToKeys(args[:len(args)-1])
+	timeout, errReply := parseBlockingTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	if errReply := db.checkListKeys(keys); errReply != nil {
+		return errReply
+	}
+	return &blockingReply{
+		keys:    keys,
+		timeout: timeout,
+		serve: func(key string) resp.Reply {
+			popped, errReply := db.popListElements(key, fromTail, 1)
+			if errReply != nil {
+				return errReply
+			}
+			if len(popped) == 0 {
+				return nil
+			}
+			db.refreshMemory(key)
+			return reply.MakeMultiBulkReply([][]byte{[]byte(key), popped[0]})
+		},
+		timeoutReply: &reply.NullMultiBulkReply{},
+	}
+}
+
+// execBLMove is the blocking variant of lmove
+func execBLMove(db *DB, args [][]byte) resp.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	srcSide := strings.ToUpper(string(args[2]))
+	destSide := strings.ToUpper(string(args[3]))
+	if !isListSide(srcSide) || !isListSide(destSide) {
+		return &reply.SyntaxErrReply{}
+	}
+	timeout, errReply := parseBlockingTimeout(args[4])
+	if errReply != nil {
+		return errReply
+	}
+	if errReply := db.checkListKeys([]string{src, dest}); errReply != nil {
+		return errReply
+	}
+	return &blockingReply{
+		keys:    []string{src},
+		timeout: timeout,
+		serve: func(key string) resp.Reply {
+			val, errReply := db.moveListElement(src, dest, srcSide, destSide)
+			if errReply != nil {
+				return errReply
+			}
+			if val == nil {
+				return nil
+			}
+			db.refreshMemory(src, dest)
+			// registry is locked while serving, so mark destination ready instead of signaling
+			db.blocking.markReadyLocked(dest)
+			return reply.MakeBulkReply(val)
+		},
+		timeoutReply: &reply.NullBulkReply{},
+	}
+}
+
+// parseMPopArgs parses `numkeys key [key ...] LEFT|RIGHT [COUNT count]`
+func parseMPopArgs(args [][]byte) (keys []string, fromTail bool, count int, errReply reply.ErrorReply) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-2) {
+		return nil, false, 0, &reply.SyntaxErrReply{}
+	}
+	keys = bytesToKeys(args[1 : 1+numKeys])
+	rest := args[1+numKeys:]
+	side := strings.ToUpper(string(rest[0]))
+	if !isListSide(side) {
+		return nil, false, 0, &reply.SyntaxErrReply{}
+	}
+	fromTail = side == "RIGHT"
+	count = 1
+	if len(rest) > 1 {
+		if len(rest) != 3 || strings.ToUpper(string(rest[1])) != "COUNT" {
+			return nil, false, 0, &reply.SyntaxErrReply{}
+		}
+		n, err := strconv.ParseInt(string(rest[2]), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, false, 0, reply.MakeErrReply("ERR count should be greater than 0")
+		}
+		count = int(n)
+	}
+	return keys, fromTail, count, nil
+}
+
+// makeMPopServer pops elements from the given list, replies key name and popped elements
+func (db *DB) makeMPopServer(fromTail bool, count int) serveFunc {
+	return func(key string) resp.Reply {
+		popped, errReply := db.popListElements(key, fromTail, count)
+		if errReply != nil {
+			return errReply
+		}
+		if len(popped) == 0 {
+			return nil
+		}
+		db.refreshMemory(key)
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(key)),
+			reply.MakeMultiBulkReply(popped),
+		})
+	}
+}
+
+// execLMPop pops elements from the first non-empty list
+func execLMPop(db *DB, args [][]byte) resp.Reply {
+	keys, fromTail, count, errReply := parseMPopArgs(args)
+	if errReply != nil {
+		return errReply
+	}
+	serve := db.makeMPopServer(fromTail, count)
+	for _, key := range keys {
+		if result := serve(key); result != nil {
+			return result
+		}
+	}
+	return &reply.NullMultiBulkReply{}
+}
+
+// execBLMPop is the blocking variant of lmpop
+func execBLMPop(db *DB, args [][]byte) resp.Reply {
+	timeout, errReply := parseBlockingTimeout(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	keys, fromTail, count, errReply := parseMPopArgs(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	if errReply := db.checkListKeys(keys); errReply != nil {
+		return errReply
+	}
+	return &blockingReply{
+		keys:         keys,
+		timeout:      timeout,
+		serve:        db.makeMPopServer(fromTail, count),
+		timeoutReply: &reply.NullMultiBulkReply{},
+	}
+}
+
+func init() {
+	RegisterCommand("LPush", execLPush, -3).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("LPushX", execLPushX, -3).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("RPush", execRPush, -3).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("RPushX", execRPushX, -3).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("LPop", execLPop, -2).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("RPop", execRPop, -2).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("LLen", execLLen, 2)
+	RegisterCommand("LIndex", execLIndex, 3)
+	RegisterCommand("LSet", execLSet, 4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("LRange", execLRange, 4)
+	RegisterCommand("LInsert", execLInsert, 5).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("LRem", execLRem, 4).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("LTrim", execLTrim, 4).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("LPos", execLPos, -3)
+	RegisterCommand("LMove", execLMove, 5).withFlags(flagWrite|flagDenyOOM).withKeys(1, 2, 1)
+	RegisterCommand("LMPop", execLMPop, -4).withFlags(flagWrite)
+	RegisterCommand("BLPop", execBLPop, -3).withFlags(flagWrite)
+	RegisterCommand("BRPop", execBRPop, -3).withFlags(flagWrite)
+	RegisterCommand("BLMove", execBLMove, 6).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("BLMPop", execBLMPop, -5).withFlags(flagWrite)
+}
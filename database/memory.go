@@ -0,0 +1,339 @@
+package database
+
+import (
+	"fmt"
+	Dict "goRedis/datastruct/dict"
+	List "goRedis/datastruct/list"
+	HashSet "goRedis/datastruct/set"
+	"goRedis/datastruct/sortedset"
+	"goRedis/datastruct/stream"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/resp/reply"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// sizes on 64-bit platforms used to estimate memory usage
+const (
+	stringHeaderSize = 16
+	sliceHeaderSize  = 24
+	interfaceSize    = 16
+	pointerSize      = 8
+	// entitySize is the DataEntity itself
+	entitySize = 48
+	// quickListPageSize is the capacity of a page of list.QuickList
+	quickListPageSize = 1024
+	// listElementSize is the element of container/list holding a page
+	listElementSize = 48
+	// streamEntrySize is the stream.Entry and the pointer to it
+	streamEntrySize = 48
+	// pendingEntrySize is a pending entry referenced by its group and consumer
+	pendingEntrySize = 64 + 2*48
+	// defaultMemorySamples is the number of elements measured to estimate a collection, the same as redis
+	defaultMemorySamples = 5
+)
+
+// mapEntrySize returns bytes taken by an entry of go map, buckets are about 80% full and have a tophash byte per slot
+func mapEntrySize(keyValueSize int64) int64 {
+	return (keyValueSize + 1) * 16 / 13
+}
+
+// keyspaceEntrySize is the entry of key in db and the key itself
+func keyspaceEntrySize(key string) int64 {
+	return int64(len(key)) + mapEntrySize(stringHeaderSize+interfaceSize) + entitySize
+}
+
+// expiresEntrySize is the entry of key in ttlKeys and the boxed expire time
+func expiresEntrySize() int64 {
+	return mapEntrySize(stringHeaderSize+interfaceSize) + 8
+}
+
+func bytesSize(bytes []byte) int64 {
+	return sliceHeaderSize + int64(cap(bytes))
+}
+
+// sizeSampler extrapolates the size of a collection from its first elements
+type sizeSampler struct {
+	samples int // 0 means all elements are measured
+	count   int64
+	size    int64
+}
+
+// add measures an element, returns false once enough elements are sampled
+func (s *sizeSampler) add(size int64) bool {
+	s.size += size
+	s.count++
+	return s.samples <= 0 || s.count < int64(s.samples)
+}
+
+// estimate returns the total size of length elements
+func (s *sizeSampler) estimate(length int64) int64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.size * length / s.count
+}
+
+// estimateValueSize returns the approximate bytes used by the value
+func estimateValueSize(data interface{}, samples int) int64 {
+	sampler := &sizeSampler{samples: samples}
+	switch val := data.(type) {
+	case []byte:
+		return bytesSize(val)
+	case List.List:
+		pages := int64((val.Len() + quickListPageSize - 1) / quickListPageSize)
+		val.ForEach(func(i int, v interface{}) bool {
+			bytes, _ := v.([]byte)
+			return sampler.add(bytesSize(bytes))
+		})
+		return pages*(quickListPageSize*interfaceSize+listElementSize) + sampler.estimate(int64(val.Len()))
+	case Dict.Dict:
+		val.ForEach(func(field string, v interface{}) bool {
+			bytes, _ := v.([]byte)
+			size := int64(len(field)) + bytesSize(bytes) + mapEntrySize(stringHeaderSize+interfaceSize)
+			return sampler.add(size)
+		})
+		return sampler.estimate(int64(val.Len()))
+	case *HashSet.Set:
+		val.ForEach(func(member string) bool {
+			return sampler.add(int64(len(member)) + mapEntrySize(stringHeaderSize+interfaceSize))
+		})
+		return sampler.estimate(int64(val.Len()))
+	case *sortedset.SortedSet:
+		return val.EstimateSize(samples)
+	case *stream.Stream:
+		val.ForEach(func(entry *stream.Entry) bool {
+			size := int64(streamEntrySize)
+			for _, field := range entry.Fields {
+				size += bytesSize(field)
+			}
+			return sampler.add(size)
+		})
+		size := sampler.estimate(int64(val.Len()))
+		for _, group := range val.Groups() {
+			size += int64(len(group.Name)) + pendingEntrySize*int64(group.PendingCount())
+		}
+		return size
+	}
+	return 0
+}
+
+// estimateSize returns the approximate bytes used by the key and its value in db
+func estimateSize(key string, entity *database.DataEntity, samples int) int64 {
+	size := keyspaceEntrySize(key) + estimateValueSize(entity.Data, samples)
+	if entity.ExpireTime > 0 {
+		size += expiresEntrySize()
+	}
+	return size
+}
+
+// accountMemory updates memory usage of the keyspace after the old entity of key is replaced by entity.
+// The old entity is nil if key did not exist, or entity itself if its value is modified in place.
+// MemorySize is accessed atomically as the same entity may be estimated again by concurrent writes.
+func (ks *keyspace) accountMemory(key string, entity *database.DataEntity, old *database.DataEntity) {
+	size := estimateSize(key, entity, defaultMemorySamples)
+	var delta int64
+	if old == entity {
+		delta = size - atomic.SwapInt64(&entity.MemorySize, size)
+	} else {
+		atomic.StoreInt64(&entity.MemorySize, size)
+		delta = size
+		if old != nil {
+			delta -= atomic.LoadInt64(&old.MemorySize)
+		}
+	}
+	atomic.AddInt64(&ks.memory, delta)
+}
+
+// refreshMemory estimates values of keys modified in place again.
+// It is called with the key positions of write commands, and by code modifying keys on behalf of other clients,
+// like serving blocked clients.
+func (db *DB) refreshMemory(keys ...string) {
+	ks := db.keyspace()
+	for _, key := range keys {
+		if entity := ks.rawEntity(key); entity != nil {
+			ks.accountMemory(key, entity, entity)
+		}
+	}
+}
+
+// usedMemory returns the estimated bytes used by keys and values of db
+func (db *DB) usedMemory() int64 {
+	return atomic.LoadInt64(&db.keyspace().memory)
+}
+
+var memoryHelp = []string{
+	"MEMORY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"DOCTOR",
+	"    Return memory problems reports.",
+	"PURGE",
+	"    Return freed memory to the operating system.",
+	"STATS",
+	"    Return information about the memory usage of the server.",
+	"USAGE <key> [SAMPLES <count>]",
+	"    Return memory in bytes used by <key> and its value. Nested values are",
+	"    sampled up to <count> times (default: 5, 0 means sample all).",
+	"HELP",
+	"    Print this help.",
+}
+
+// execMemory reports memory usage, stats and doctor need all databases
+func execMemory(c resp.Connection, mdb *StandaloneDatabase, args [][]byte) resp.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	switch {
+	case subCmd == "HELP" && len(args) == 1:
+		return makeHelpReply(memoryHelp)
+	case subCmd == "USAGE" && (len(args) == 2 || len(args) == 4):
+		return execMemoryUsage(mdb.dbSet[c.GetDBIndex()], args[1:])
+	case subCmd == "STATS" && len(args) == 1:
+		return execMemoryStats(mdb)
+	case subCmd == "DOCTOR" && len(args) == 1:
+		return reply.MakeBulkReply([]byte(mdb.memoryDoctor()))
+	case subCmd == "PURGE" && len(args) == 1:
+		debug.FreeOSMemory()
+		return &reply.OkReply{}
+	}
+	return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + string(args[0]) + "'. Try MEMORY HELP.")
+}
+
+// execMemoryUsage returns estimated bytes of key and its value
+func execMemoryUsage(db *DB, args [][]byte) resp.Reply {
+	samples := defaultMemorySamples
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[1])) != "SAMPLES" {
+			return &reply.SyntaxErrReply{}
+		}
+		n, err := strconv.Atoi(string(args[2]))
+		if err != nil || n < 0 {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		samples = n
+	}
+	entity, exists := db.peekEntity(string(args[0]))
+	if !exists {
+		return &reply.NullBulkReply{}
+	}
+	return reply.MakeIntReply(estimateSize(string(args[0]), entity, samples))
+}
+
+// memoryStats is a snapshot of memory usage of server
+type memoryStats struct {
+	runtime runtime.MemStats
+	keys    int64
+	dataset int64
+}
+
+func (mdb *StandaloneDatabase) getMemoryStats() *memoryStats {
+	stats := &memoryStats{}
+	runtime.ReadMemStats(&stats.runtime)
+	for _, db := range mdb.dbSet {
+		stats.keys += int64(db.keyspace().data.Len())
+		stats.dataset += db.usedMemory()
+	}
+	return stats
+}
+
+func formatRatio(ratio float64) []byte {
+	return []byte(strconv.FormatFloat(ratio, 'f', 2, 64))
+}
+
+// execMemoryStats returns memory usage of server, fields are named like redis where there is an equivalent
+func execMemoryStats(mdb *StandaloneDatabase) resp.Reply {
+	stats := mdb.getMemoryStats()
+	m := &stats.runtime
+	var replies []resp.Reply
+	add := func(name string, value resp.Reply) {
+		replies = append(replies, reply.MakeBulkReply([]byte(name)), value)
+	}
+	add("total.allocated", reply.MakeIntReply(int64(m.HeapAlloc)))
+	add("startup.allocated", reply.MakeIntReply(mdb.startupMemory))
+	for i, db := range mdb.dbSet {
+		ks := db.keyspace()
+		if ks.data.Len() == 0 {
+			continue
+		}
+		add("db."+strconv.Itoa(i), reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("keys")), reply.MakeIntReply(int64(ks.data.Len())),
+			reply.MakeBulkReply([]byte("expires")), reply.MakeIntReply(int64(ks.ttlKeys.Len())),
+			reply.MakeBulkReply([]byte("dataset.bytes")), reply.MakeIntReply(atomic.LoadInt64(&ks.memory)),
+		}))
+	}
+	add("keys.count", reply.MakeIntReply(stats.keys))
+	bytesPerKey := int64(0)
+	if stats.keys > 0 {
+		bytesPerKey = (int64(m.HeapAlloc) - mdb.startupMemory) / stats.keys
+	}
+	add("keys.bytes-per-key", reply.MakeIntReply(bytesPerKey))
+	add("dataset.bytes", reply.MakeIntReply(stats.dataset))
+	add("dataset.percentage", reply.MakeBulkReply(formatRatio(100*float64(stats.dataset)/float64(m.HeapAlloc))))
+	add("allocator.allocated", reply.MakeIntReply(int64(m.HeapAlloc)))
+	add("allocator.active", reply.MakeIntReply(int64(m.HeapInuse)))
+	add("allocator.resident", reply.MakeIntReply(int64(m.HeapSys-m.HeapReleased)))
+	add("allocator-fragmentation.ratio", reply.MakeBulkReply(formatRatio(float64(m.HeapInuse)/float64(m.HeapAlloc))))
+	add("allocator.objects", reply.MakeIntReply(int64(m.HeapObjects)))
+	add("gc.count", reply.MakeIntReply(int64(m.NumGC)))
+	add("gc.next", reply.MakeIntReply(int64(m.NextGC)))
+	add("gc.pause-total-ms", reply.MakeIntReply(int64(m.PauseTotalNs/1e6)))
+	add("goroutines", reply.MakeIntReply(int64(runtime.NumGoroutine())))
+	add("lazyfree.pending-objects", reply.MakeIntReply(mdb.freer.pendingObjects()))
+	add("lazyfreed.objects", reply.MakeIntReply(mdb.freer.freedObjects()))
+	return reply.MakeMultiRawReply(replies)
+}
+
+const (
+	// doctorMinHeap is the heap size below which the doctor does not diagnose
+	doctorMinHeap = 5 << 20
+	// doctorMaxFragmentation is the max ratio of heap in use to heap allocated
+	doctorMaxFragmentation = 1.4
+	// doctorMaxRSSOverhead is the max ratio of heap held from os to heap in use
+	doctorMaxRSSOverhead = 1.5
+	// doctorMinDatasetRatio is the min ratio of data set to heap allocated, less means a lot of garbage or overhead
+	doctorMinDatasetRatio = 0.25
+	// doctorMaxMemoryRatio is the ratio of maxmemory reached that is reported
+	doctorMaxMemoryRatio = 0.9
+)
+
+// memoryDoctor reports memory problems like MEMORY DOCTOR of redis
+func (mdb *StandaloneDatabase) memoryDoctor() string {
+	stats := mdb.getMemoryStats()
+	m := &stats.runtime
+	if m.HeapAlloc < doctorMinHeap {
+		return "Hi Sam, this instance is empty or is using very little memory, " +
+			"my issues detector can't be used in these conditions. " +
+			"Please, leave for your mission on Earth and fill it with some data. " +
+			"The new Sam and I will be back to our programming as soon as I finished rebooting."
+	}
+	var issues []string
+	if fragmentation := float64(m.HeapInuse) / float64(m.HeapAlloc); fragmentation > doctorMaxFragmentation {
+		issues = append(issues, fmt.Sprintf("High heap fragmentation: This instance has a heap fragmentation "+
+			"greater than %.1f (%.2f), spans of the heap are partly used by live objects. "+
+			"It usually happens after many values of different sizes are deleted.", doctorMaxFragmentation, fragmentation))
+	}
+	resident := m.HeapSys - m.HeapReleased
+	if overhead := float64(resident) / float64(m.HeapInuse); overhead > doctorMaxRSSOverhead {
+		issues = append(issues, fmt.Sprintf("High heap RSS overhead: This instance holds %.2f times the memory "+
+			"in use from the operating system. MEMORY PURGE returns the free memory at once.", overhead))
+	}
+	if ratio := float64(stats.dataset) / float64(m.HeapAlloc); stats.keys > 0 && ratio < doctorMinDatasetRatio {
+		issues = append(issues, fmt.Sprintf("Big heap compared to data set: The data set is estimated to use "+
+			"%.0f%% of the heap, the rest is garbage waiting for GC or taken by clients, aof buffer and pub/sub.", 100*ratio))
+	}
+	if evictor := mdb.dbSet[0].evictor; evictor != nil {
+		if used := evictor.usedMemory(); float64(used) > doctorMaxMemoryRatio*float64(evictor.maxMemory) {
+			issues = append(issues, fmt.Sprintf("Maxmemory almost reached: The data set uses %d bytes of %d bytes. ", used, evictor.maxMemory)+
+				"Keys are evicted, or write commands are refused if maxmemory-policy is noeviction.")
+		}
+	}
+	if len(issues) == 0 {
+		return "Hi Sam, I can't find any memory issue in your instance. I can only account for what occurs on this base."
+	}
+	report := "Sam, I detected a few issues in this instance memory implants:\n\n"
+	for _, issue := range issues {
+		report += " * " + issue + "\n\n"
+	}
+	return report + "I'm here to keep you safe, Sam. I want to help you.\n"
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// classes of keyspace events, the same as flags of notify-keyspace-events in redis.
+// Only the classes below are published, the flags of list, set, hash, stream, key miss and new key events
+// (l, s, h, t, m and n) are rejected instead of being accepted silently.
+const (
+	notifyKeyspace = 1 << iota // K, publish to __keyspace@<db>__:<key>
+	notifyKeyevent             // E, publish to __keyevent@<db>__:<event>
+	notifyGeneric              // g, commands not specific to a type, like del, expire, rename
+	notifyString               // $
+	notifyZSet                 // z
+	notifyExpired              // x, a key expired
+	notifyEvicted              // e, a key evicted for maxmemory
+
+	// notifyAll is the alias "A" of all the classes above
+	notifyAll = notifyGeneric | notifyString | notifyZSet | notifyExpired | notifyEvicted
+)
+
+// parseNotifyFlags parses the value of notify-keyspace-events
+func parseNotifyFlags(value string) (int, error) {
+	flags := 0
+	for _, ch := range strings.Trim(value, `"'`) {
+		switch ch {
+		case 'A':
+			flags |= notifyAll
+		case 'g':
+			flags |= notifyGeneric
+		case '$':
+			flags |= notifyString
+		case 'z':
+			flags |= notifyZSet
+		case 'x':
+			flags |= notifyExpired
+		case 'e':
+			flags |= notifyEvicted
+		case 'K':
+			flags |= notifyKeyspace
+		case 'E':
+			flags |= notifyKeyevent
+		case 'l', 's', 'h', 't', 'm', 'n':
+			return 0, fmt.Errorf("notify-keyspace-events flag '%c' is not supported", ch)
+		default:
+			return 0, fmt.Errorf("invalid notify-keyspace-events flag '%c'", ch)
+		}
+	}
+	if flags&(notifyKeyspace|notifyKeyevent) == 0 {
+		// neither channel is enabled
+		return 0, nil
+	}
+	return flags, nil
+}
+
+// notifyKeyspaceEvent publishes the event of key if the class is enabled.
+// Like redis, the keyspace channel receives the event name and the keyevent channel receives the key.
+func (db *DB) notifyKeyspaceEvent(class int, event string, key string) {
+	if db.notifyFlags&class == 0 || db.hub == nil || !db.hub.HasSubscribers() {
+		return
+	}
+	prefix := "@" + strconv.Itoa(db.index) + "__:"
+	if db.notifyFlags&notifyKeyspace != 0 {
+		db.hub.Publish("__keyspace"+prefix+key, []byte(event))
+	}
+	if db.notifyFlags&notifyKeyevent != 0 {
+		db.hub.Publish("__keyevent"+prefix+event, []byte(key))
+	}
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"goRedis/config"
+	Dict "goRedis/datastruct/dict"
+	List "goRedis/datastruct/list"
+	HashSet "goRedis/datastruct/set"
+	"goRedis/datastruct/sortedset"
+	"goRedis/datastruct/stream"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/resp/reply"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// lfuInitVal is the frequency of new keys, so that they are not evicted before getting a chance to be accessed
+	lfuInitVal = 5
+	// lfuLogFactor controls how fast the frequency grows, the counter saturates at about 1M accesses
+	lfuLogFactor = 10
+	// lfuDecayPeriod is the time in milliseconds for the frequency to be decremented by one
+	lfuDecayPeriod = 60 * 1000
+	// embstrSizeLimit is the longest string reported as embstr, the same as redis
+	embstrSizeLimit = 44
+)
+
+// initAccess sets the access metadata of an entity being stored for the first time.
+// Like redis, a value overwriting the old one inherits its frequency, so that a hot key stays hot after being set.
+func initAccess(entity *database.DataEntity, old *database.DataEntity) {
+	if lastAccess, _ := loadAccess(entity); lastAccess > 0 {
+		// the entity is moved from another key, e.g. by rename
+		return
+	}
+	frequency := uint8(lfuInitVal)
+	if old != nil {
+		_, frequency = loadAccess(old)
+	}
+	storeAccess(entity, nowMillis(), frequency)
+}
+
+// loadAccess returns the access metadata of the entity, which may be updated by concurrent reads
+func loadAccess(entity *database.DataEntity) (lastAccess int64, frequency uint8) {
+	return atomic.LoadInt64(&entity.LastAccess), uint8(atomic.LoadUint32(&entity.Frequency))
+}
+
+// storeAccess sets the access metadata of the entity
+func storeAccess(entity *database.DataEntity, lastAccess int64, frequency uint8) {
+	atomic.StoreUint32(&entity.Frequency, uint32(frequency))
+	atomic.StoreInt64(&entity.LastAccess, lastAccess)
+}
+
+// touchEntity records an access of the entity.
+// Like the approximated counter of redis, an increment may be lost if the entity is read concurrently.
+func touchEntity(entity *database.DataEntity, now int64) {
+	storeAccess(entity, now, lfuLogIncr(lfuDecr(entity, now)))
+}
+
+// lfuLogIncr increments the counter with probability 1/((counter-lfuInitVal)*lfuLogFactor+1),
+// the more the key was accessed the less likely the counter grows
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return counter
+	}
+	base := float64(counter) - lfuInitVal
+	if base < 0 {
+		base = 0
+	}
+	if rand.Float64() < 1.0/(base*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecr returns the counter decremented by the decay periods elapsed since the last access
+func lfuDecr(entity *database.DataEntity, now int64) uint8 {
+	lastAccess, frequency := loadAccess(entity)
+	periods := (now - lastAccess) / lfuDecayPeriod
+	if periods <= 0 {
+		return frequency
+	}
+	if periods >= int64(frequency) {
+		return 0
+	}
+	return frequency - uint8(periods)
+}
+
+// stringEncoding returns the encoding redis would use for the string
+func stringEncoding(bytes []byte) string {
+	if len(bytes) <= 20 {
+		val, err := strconv.ParseInt(string(bytes), 10, 64)
+		if err == nil && strconv.FormatInt(val, 10) == string(bytes) {
+			return "int"
+		}
+	}
+	if len(bytes) <= embstrSizeLimit {
+		return "embstr"
+	}
+	return "raw"
+}
+
+// encodingName returns the name of underlying data structure of entity
+func encodingName(entity *database.DataEntity) string {
+	switch data := entity.Data.(type) {
+	case []byte:
+		return stringEncoding(data)
+	case List.List:
+		return "quicklist"
+	case Dict.Dict, *HashSet.Set:
+		return "hashtable"
+	case *sortedset.SortedSet:
+		return "skiplist"
+	case *stream.Stream:
+		return "stream"
+	}
+	return "unknown"
+}
+
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"HELP",
+	"    Print this help.",
+}
+
+var (
+	lfuNotSelectedErrReply = reply.MakeErrReply("ERR An LFU maxmemory policy is not selected, access frequency not tracked. " +
+		"Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+	lfuSelectedErrReply = reply.MakeErrReply("ERR An LFU maxmemory policy is selected, idle time not tracked. " +
+		"Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+)
+
+// isLFUPolicy returns whether maxmemory-policy is allkeys-lfu or volatile-lfu
+func isLFUPolicy() bool {
+	return strings.HasSuffix(strings.ToLower(config.Properties.MaxMemoryPolicy), "-lfu")
+}
+
+// execObject inspects the internals of the value of key, it does not count as an access
+func execObject(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	switch subCmd {
+	case "HELP":
+		return makeHelpReply(objectHelp)
+	case "ENCODING", "FREQ", "IDLETIME":
+	default:
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try OBJECT HELP.")
+	}
+	if len(args) != 2 {
+		return reply.MakeErrReply("ERR wrong number of arguments for 'object|" + strings.ToLower(subCmd) + "' command")
+	}
+	entity, exists := db.peekEntity(string(args[1]))
+	if !exists {
+		return &reply.NullBulkReply{}
+	}
+	switch subCmd {
+	case "ENCODING":
+		return reply.MakeBulkReply([]byte(encodingName(entity)))
+	case "FREQ":
+		if !isLFUPolicy() {
+			return lfuNotSelectedErrReply
+		}
+		return reply.MakeIntReply(int64(lfuDecr(entity, nowMillis())))
+	default:
+		if isLFUPolicy() {
+			return lfuSelectedErrReply
+		}
+		lastAccess, _ := loadAccess(entity)
+		return reply.MakeIntReply((nowMillis() - lastAccess) / 1000)
+	}
+}
+
+func init() {
+	RegisterCommand("Object", execObject, -2)
+}
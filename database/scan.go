@@ -0,0 +1,177 @@
+package database
+
+import (
+	"goRedis/interface/resp"
+	"goRedis/lib/wildcard"
+	"goRedis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+const defaultScanCount = 10
+
+// scanOption is the parsed arguments of scan commands
+type scanOption struct {
+	cursor   uint64
+	count    int
+	pattern  *wildcard.Pattern
+	typeName string
+	noValues bool
+}
+
+// parseScanOption parses `cursor [MATCH pattern] [COUNT count]`, TYPE is accepted only by scan and NOVALUES only by hscan
+func parseScanOption(args [][]byte, allowType bool, allowNoValues bool) (*scanOption, reply.ErrorReply) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, reply.MakeErrReply("ERR invalid cursor")
+	}
+	option := &scanOption{
+		cursor: cursor,
+		count:  defaultScanCount,
+	}
+	for i := 1; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "MATCH" && i+1 < len(args):
+			option.pattern = wildcard.CompilePattern(string(args[i+1]))
+			i++
+		case arg == "COUNT" && i+1 < len(args):
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			option.count = int(count)
+			i++
+		case arg == "TYPE" && allowType && i+1 < len(args):
+			option.typeName = strings.ToLower(string(args[i+1]))
+			i++
+		case arg == "NOVALUES" && allowNoValues:
+			option.noValues = true
+		default:
+			return nil, &reply.SyntaxErrReply{}
+		}
+	}
+	return option, nil
+}
+
+func (option *scanOption) isMatch(key string) bool {
+	return option.pattern == nil || option.pattern.IsMatch(key)
+}
+
+// makeScanReply replies the cursor of next call and the scanned elements
+func makeScanReply(next uint64, result [][]byte) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(strconv.FormatUint(next, 10))),
+		reply.MakeMultiBulkReply(result),
+	})
+}
+
+// execScan iterates keys of db
+func execScan(db *DB, args [][]byte) resp.Reply {
+	option, errReply := parseScanOption(args, true, false)
+	if errReply != nil {
+		return errReply
+	}
+	keys, next := db.keyspace().data.Scan(option.cursor, option.count)
+	result := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		if !option.isMatch(key) {
+			continue
+		}
+		// expired keys are removed and skipped
+		entity, exists := db.peekEntity(key)
+		if !exists {
+			continue
+		}
+		if option.typeName != "" && typeName(entity) != option.typeName {
+			continue
+		}
+		result = append(result, []byte(key))
+	}
+	return makeScanReply(next, result)
+}
+
+// execHScan iterates fields and values of hash
+func execHScan(db *DB, args [][]byte) resp.Reply {
+	option, errReply := parseScanOption(args[1:], false, true)
+	if errReply != nil {
+		return errReply
+	}
+	dict, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return makeScanReply(0, nil)
+	}
+	fields, next := dict.Scan(option.cursor, option.count)
+	result := make([][]byte, 0, 2*len(fields))
+	for _, field := range fields {
+		if !option.isMatch(field) {
+			continue
+		}
+		result = append(result, []byte(field))
+		if !option.noValues {
+			val, _ := dict.Get(field)
+			result = append(result, val.([]byte))
+		}
+	}
+	return makeScanReply(next, result)
+}
+
+// execSScan iterates members of set
+func execSScan(db *DB, args [][]byte) resp.Reply {
+	option, errReply := parseScanOption(args[1:], false, false)
+	if errReply != nil {
+		return errReply
+	}
+	set, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return makeScanReply(0, nil)
+	}
+	members, next := set.Scan(option.cursor, option.count)
+	result := make([][]byte, 0, len(members))
+	for _, member := range members {
+		if option.isMatch(member) {
+			result = append(result, []byte(member))
+		}
+	}
+	return makeScanReply(next, result)
+}
+
+// execZScan iterates members and scores of sorted set
+func execZScan(db *DB, args [][]byte) resp.Reply {
+	option, errReply := parseScanOption(args[1:], false, false)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsZSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return makeScanReply(0, nil)
+	}
+	elements, next := zset.Scan(option.cursor, option.count)
+	result := make([][]byte, 0, 2*len(elements))
+	for _, element := range elements {
+		if !option.isMatch(element.Member) {
+			continue
+		}
+		result = append(result, []byte(element.Member), []byte(strconv.FormatFloat(element.Score, 'f', -1, 64)))
+	}
+	return makeScanReply(next, result)
+}
+
+func init() {
+	RegisterCommand("Scan", execScan, -2)
+	RegisterCommand("HScan", execHScan, -3)
+	RegisterCommand("SScan", execSScan, -3)
+	RegisterCommand("ZScan", execZScan, -3)
+}
@@ -0,0 +1,378 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	Dict "goRedis/datastruct/dict"
+	List "goRedis/datastruct/list"
+	HashSet "goRedis/datastruct/set"
+	"goRedis/datastruct/sortedset"
+	"goRedis/datastruct/stream"
+	"hash/crc64"
+	"math"
+)
+
+// A serialized value is laid out as:
+//
+//	type byte | value | version byte | crc64 of all preceding bytes (8 bytes, little endian)
+//
+// Lengths and integers are varints, scores are IEEE 754 doubles in little endian.
+// The version is increased whenever the layout changes, so that payloads of newer versions are refused.
+const dumpVersion = 1
+
+// types of serialized values
+const (
+	dumpTypeString = iota
+	dumpTypeList
+	dumpTypeSet
+	dumpTypeZSet
+	dumpTypeHash
+	dumpTypeStream
+)
+
+const dumpTrailerSize = 1 + 8
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+var (
+	errDumpChecksum   = errors.New("ERR DUMP payload version or checksum are wrong")
+	errDumpBadFormat  = errors.New("ERR Bad data format")
+	errDumpUnknownVal = errors.New("ERR value of unknown type can not be serialized")
+)
+
+// dumpEncoder appends the serialized value to buf
+type dumpEncoder struct {
+	buf []byte
+}
+
+func (enc *dumpEncoder) writeByte(b byte) {
+	enc.buf = append(enc.buf, b)
+}
+
+func (enc *dumpEncoder) writeUint(val uint64) {
+	enc.buf = binary.AppendUvarint(enc.buf, val)
+}
+
+func (enc *dumpEncoder) writeInt(val int64) {
+	enc.buf = binary.AppendVarint(enc.buf, val)
+}
+
+func (enc *dumpEncoder) writeBytes(bytes []byte) {
+	enc.writeUint(uint64(len(bytes)))
+	enc.buf = append(enc.buf, bytes...)
+}
+
+func (enc *dumpEncoder) writeString(s string) {
+	enc.writeUint(uint64(len(s)))
+	enc.buf = append(enc.buf, s...)
+}
+
+func (enc *dumpEncoder) writeFloat(val float64) {
+	enc.buf = binary.LittleEndian.AppendUint64(enc.buf, math.Float64bits(val))
+}
+
+func (enc *dumpEncoder) writeID(id stream.ID) {
+	enc.writeUint(id.Ms)
+	enc.writeUint(id.Seq)
+}
+
+// serializeValue returns the payload of DUMP
+func serializeValue(data interface{}) ([]byte, error) {
+	enc := &dumpEncoder{}
+	switch val := data.(type) {
+	case []byte:
+		enc.writeByte(dumpTypeString)
+		enc.writeBytes(val)
+	case List.List:
+		enc.writeByte(dumpTypeList)
+		enc.writeUint(uint64(val.Len()))
+		val.ForEach(func(i int, v interface{}) bool {
+			bytes, _ := v.([]byte)
+			enc.writeBytes(bytes)
+			return true
+		})
+	case *HashSet.Set:
+		enc.writeByte(dumpTypeSet)
+		enc.writeUint(uint64(val.Len()))
+		val.ForEach(func(member string) bool {
+			enc.writeString(member)
+			return true
+		})
+	case *sortedset.SortedSet:
+		enc.writeByte(dumpTypeZSet)
+		enc.writeUint(uint64(val.Len()))
+		val.ForEach(func(element *sortedset.Element) bool {
+			enc.writeString(element.Member)
+			enc.writeFloat(element.Score)
+			return true
+		})
+	case Dict.Dict:
+		enc.writeByte(dumpTypeHash)
+		enc.writeUint(uint64(val.Len()))
+		val.ForEach(func(field string, v interface{}) bool {
+			bytes, _ := v.([]byte)
+			enc.writeString(field)
+			enc.writeBytes(bytes)
+			return true
+		})
+	case *stream.Stream:
+		enc.writeByte(dumpTypeStream)
+		enc.writeStream(val)
+	default:
+		return nil, errDumpUnknownVal
+	}
+	enc.writeByte(dumpVersion)
+	enc.buf = binary.LittleEndian.AppendUint64(enc.buf, crc64.Checksum(enc.buf, crc64Table))
+	return enc.buf, nil
+}
+
+// writeStream writes entries, metadata and consumer groups with their pending entries
+func (enc *dumpEncoder) writeStream(s *stream.Stream) {
+	enc.writeUint(uint64(s.Len()))
+	s.ForEach(func(entry *stream.Entry) bool {
+		enc.writeID(entry.ID)
+		enc.writeUint(uint64(len(entry.Fields)))
+		for _, field := range entry.Fields {
+			enc.writeBytes(field)
+		}
+		return true
+	})
+	enc.writeID(s.LastID())
+	enc.writeID(s.MaxDeletedID())
+	enc.writeUint(s.EntriesAdded())
+	groups := s.Groups()
+	enc.writeUint(uint64(len(groups)))
+	for _, group := range groups {
+		enc.writeString(group.Name)
+		enc.writeID(group.LastID)
+		consumers := group.Consumers()
+		enc.writeUint(uint64(len(consumers)))
+		for _, consumer := range consumers {
+			enc.writeString(consumer.Name)
+			enc.writeInt(consumer.SeenTime)
+			enc.writeInt(consumer.ActiveTime)
+		}
+		pendings := group.PendingRange(stream.MinID, stream.MaxID, group.PendingCount(), "")
+		enc.writeUint(uint64(len(pendings)))
+		for _, pending := range pendings {
+			enc.writeID(pending.ID)
+			enc.writeString(pending.Consumer)
+			enc.writeInt(pending.DeliveryTime)
+			enc.writeUint(pending.DeliveryCount)
+		}
+	}
+}
+
+// verifyPayload checks the version and checksum, returns the serialized value without the trailer
+func verifyPayload(payload []byte) ([]byte, error) {
+	if len(payload) < 1+dumpTrailerSize {
+		return nil, errDumpChecksum
+	}
+	body := payload[:len(payload)-8]
+	checksum := binary.LittleEndian.Uint64(payload[len(payload)-8:])
+	if body[len(body)-1] != dumpVersion || crc64.Checksum(body, crc64Table) != checksum {
+		return nil, errDumpChecksum
+	}
+	return body[:len(body)-1], nil
+}
+
+// dumpDecoder reads the serialized value, the first error is kept and later reads return zero values
+type dumpDecoder struct {
+	buf []byte
+	err error
+}
+
+func (dec *dumpDecoder) fail() {
+	if dec.err == nil {
+		dec.err = errDumpBadFormat
+	}
+	dec.buf = nil
+}
+
+func (dec *dumpDecoder) readByte() byte {
+	if len(dec.buf) == 0 {
+		dec.fail()
+		return 0
+	}
+	b := dec.buf[0]
+	dec.buf = dec.buf[1:]
+	return b
+}
+
+func (dec *dumpDecoder) readUint() uint64 {
+	val, n := binary.Uvarint(dec.buf)
+	if n <= 0 {
+		dec.fail()
+		return 0
+	}
+	dec.buf = dec.buf[n:]
+	return val
+}
+
+func (dec *dumpDecoder) readInt() int64 {
+	val, n := binary.Varint(dec.buf)
+	if n <= 0 {
+		dec.fail()
+		return 0
+	}
+	dec.buf = dec.buf[n:]
+	return val
+}
+
+// readLen reads the number of elements, every element takes at least a byte,
+// so that a corrupted length does not cause a huge allocation
+func (dec *dumpDecoder) readLen() int {
+	n := dec.readUint()
+	if n > uint64(len(dec.buf)) {
+		dec.fail()
+		return 0
+	}
+	return int(n)
+}
+
+// readCollectionLen reads the number of elements of a list, set, zset or hash, which can not be empty
+func (dec *dumpDecoder) readCollectionLen() int {
+	n := dec.readLen()
+	if n == 0 {
+		dec.fail()
+	}
+	return n
+}
+
+func (dec *dumpDecoder) readBytes() []byte {
+	n := dec.readLen()
+	if dec.err != nil {
+		return nil
+	}
+	bytes := make([]byte, n)
+	copy(bytes, dec.buf)
+	dec.buf = dec.buf[n:]
+	return bytes
+}
+
+func (dec *dumpDecoder) readString() string {
+	return string(dec.readBytes())
+}
+
+func (dec *dumpDecoder) readFloat() float64 {
+	if len(dec.buf) < 8 {
+		dec.fail()
+		return 0
+	}
+	val := math.Float64frombits(binary.LittleEndian.Uint64(dec.buf))
+	dec.buf = dec.buf[8:]
+	if math.IsNaN(val) {
+		dec.fail()
+	}
+	return val
+}
+
+func (dec *dumpDecoder) readID() stream.ID {
+	return stream.ID{Ms: dec.readUint(), Seq: dec.readUint()}
+}
+
+// deserializeValue returns the value of a DUMP payload
+func deserializeValue(payload []byte) (interface{}, error) {
+	body, err := verifyPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	dec := &dumpDecoder{buf: body}
+	var data interface{}
+	switch dec.readByte() {
+	case dumpTypeString:
+		data = dec.readBytes()
+	case dumpTypeList:
+		list := List.NewQuickList()
+		for i, n := 0, dec.readCollectionLen(); i < n && dec.err == nil; i++ {
+			list.Add(dec.readBytes())
+		}
+		data = list
+	case dumpTypeSet:
+		set := HashSet.Make()
+		for i, n := 0, dec.readCollectionLen(); i < n && dec.err == nil; i++ {
+			if set.Add(dec.readString()) == 0 {
+				// duplicated member
+				dec.fail()
+			}
+		}
+		data = set
+	case dumpTypeZSet:
+		zset := sortedset.Make()
+		for i, n := 0, dec.readCollectionLen(); i < n && dec.err == nil; i++ {
+			member := dec.readString()
+			if !zset.Add(member, dec.readFloat()) {
+				dec.fail()
+			}
+		}
+		data = zset
+	case dumpTypeHash:
+		dict := Dict.MakeSimple()
+		for i, n := 0, dec.readCollectionLen(); i < n && dec.err == nil; i++ {
+			field := dec.readString()
+			if dict.Put(field, dec.readBytes()) == 0 {
+				dec.fail()
+			}
+		}
+		data = dict
+	case dumpTypeStream:
+		data = dec.readStream()
+	default:
+		dec.fail()
+	}
+	if dec.err == nil && len(dec.buf) > 0 {
+		// trailing bytes
+		dec.fail()
+	}
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	return data, nil
+}
+
+// readStream reads a stream written by writeStream, entries and pending entries must be valid
+func (dec *dumpDecoder) readStream() *stream.Stream {
+	s := stream.Make()
+	for i, n := 0, dec.readLen(); i < n && dec.err == nil; i++ {
+		id := dec.readID()
+		fields := make([][]byte, dec.readLen())
+		for j := range fields {
+			fields[j] = dec.readBytes()
+		}
+		if len(fields) == 0 || len(fields)%2 != 0 || !s.Add(id, fields) {
+			dec.fail()
+		}
+	}
+	lastID, maxDeletedID, entriesAdded := dec.readID(), dec.readID(), dec.readUint()
+	if last := s.Last(); last != nil && lastID.Less(last.ID) {
+		dec.fail()
+	}
+	s.SetMeta(lastID, maxDeletedID, entriesAdded)
+	for i, n := 0, dec.readLen(); i < n && dec.err == nil; i++ {
+		group := s.CreateGroup(dec.readString(), dec.readID())
+		if group == nil {
+			// duplicated group
+			dec.fail()
+			break
+		}
+		for j, m := 0, dec.readLen(); j < m && dec.err == nil; j++ {
+			consumer, created := group.CreateConsumer(dec.readString(), 0)
+			if !created {
+				dec.fail()
+			}
+			consumer.SeenTime = dec.readInt()
+			consumer.ActiveTime = dec.readInt()
+		}
+		for j, m := 0, dec.readLen(); j < m && dec.err == nil; j++ {
+			id := dec.readID()
+			consumer := group.GetConsumer(dec.readString())
+			deliveryTime, deliveryCount := dec.readInt(), dec.readUint()
+			if consumer == nil || group.GetPending(id) != nil {
+				dec.fail()
+				break
+			}
+			group.SetPending(id, consumer, deliveryTime, deliveryCount)
+		}
+	}
+	return s
+}
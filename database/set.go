@@ -0,0 +1,471 @@
+package database
+
+import (
+	HashSet "goRedis/datastruct/set"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+const (
+	setIntersect = iota
+	setUnion
+	setDiff
+)
+
+// getAsSet gets entity as set
+func (db *DB) getAsSet(key string) (*HashSet.Set, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	set, ok := entity.Data.(*HashSet.Set)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return set, nil
+}
+
+func (db *DB) getOrInitSet(key string) (set *HashSet.Set, inited bool, errReply reply.ErrorReply) {
+	set, errReply = db.getAsSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if set == nil {
+		set = HashSet.Make()
+		db.PutEntity(key, &database.DataEntity{
+			Data: set,
+		})
+		inited = true
+	}
+	return set, inited, nil
+}
+
+// removeIfEmptySet drops the key once the set has no member
+func (db *DB) removeIfEmptySet(key string, set *HashSet.Set) {
+	if set.Len() == 0 {
+		db.Remove(key)
+	}
+}
+
+func setToReply(set *HashSet.Set) resp.Reply {
+	result := make([][]byte, 0, set.Len())
+	set.ForEach(func(member string) bool {
+		result = append(result, []byte(member))
+		return true
+	})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execSAdd adds members into set
+func execSAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	members := args[1:]
+
+	set, _, errReply := db.getOrInitSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	counter := 0
+	for _, member := range members {
+		counter += set.Add(string(member))
+	}
+	if counter > 0 {
+		db.addAof(utils.ToCmdLine2("sadd", args...))
+	}
+	return reply.MakeIntReply(int64(counter))
+}
+
+// execSIsMember checks if the given value is member of set
+func execSIsMember(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	member := string(args[1])
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	if set.Has(member) {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// execSMIsMember checks membership of each given value, replies in the order of arguments
+func execSMIsMember(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	members := args[1:]
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(members))
+	for i, member := range members {
+		if set != nil && set.Has(string(member)) {
+			result[i] = reply.MakeIntReply(1)
+		} else {
+			result[i] = reply.MakeIntReply(0)
+		}
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execSRem removes members from set
+func execSRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	members := args[1:]
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.MakeIntReply(0)
+	}
+	counter := 0
+	for _, member := range members {
+		counter += set.Remove(string(member))
+	}
+	db.removeIfEmptySet(key, set)
+	if counter > 0 {
+		db.addAof(utils.ToCmdLine2("srem", args...))
+	}
+	return reply.MakeIntReply(int64(counter))
+}
+
+// execSCard gets the number of members in set
+func execSCard(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(set.Len()))
+}
+
+// execSMembers gets all members in set
+func execSMembers(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+	return setToReply(set)
+}
+
+// execSPop removes and returns random members of set
+func execSPop(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return &reply.SyntaxErrReply{}
+	}
+	key := string(args[0])
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || n < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(n)
+	}
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		if withCount {
+			return &reply.EmptyMultiBulkReply{}
+		}
+		return &reply.NullBulkReply{}
+	}
+
+	members := set.RandomDistinctMembers(count)
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		set.Remove(member)
+		result[i] = []byte(member)
+	}
+	db.removeIfEmptySet(key, set)
+	if len(result) > 0 {
+		// the popped members are random, log them as srem to make replay deterministic
+		db.addAof(utils.ToCmdLine2("srem", append([][]byte{args[0]}, result...)...))
+	}
+
+	if !withCount {
+		return reply.MakeBulkReply(result[0])
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execSRandMember gets random members from set
+func execSRandMember(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return &reply.SyntaxErrReply{}
+	}
+	key := string(args[0])
+	withCount := len(args) == 2
+	var count int64 = 1
+	if withCount {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+	}
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		if withCount {
+			return &reply.EmptyMultiBulkReply{}
+		}
+		return &reply.NullBulkReply{}
+	}
+
+	if !withCount {
+		members := set.RandomMembers(1)
+		return reply.MakeBulkReply([]byte(members[0]))
+	}
+	var members []string
+	if count >= 0 {
+		// positive count returns distinct members
+		members = set.RandomDistinctMembers(int(count))
+	} else {
+		// negative count allows the same member multiple times
+		members = set.RandomMembers(int(-count))
+	}
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		result[i] = []byte(member)
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execSMove moves a member from source set to destination set
+func execSMove(db *DB, args [][]byte) resp.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	member := string(args[2])
+
+	srcSet, errReply := db.getAsSet(src)
+	if errReply != nil {
+		return errReply
+	}
+	destSet, errReply := db.getAsSet(dest)
+	if errReply != nil {
+		return errReply
+	}
+	if srcSet == nil || !srcSet.Has(member) {
+		return reply.MakeIntReply(0)
+	}
+	if src == dest {
+		return reply.MakeIntReply(1)
+	}
+
+	srcSet.Remove(member)
+	db.removeIfEmptySet(src, srcSet)
+	if destSet == nil {
+		destSet, _, _ = db.getOrInitSet(dest)
+	}
+	destSet.Add(member)
+	db.addAof(utils.ToCmdLine2("smove", args...))
+	return reply.MakeIntReply(1)
+}
+
+// computeSets runs set algebra over the given keys. Like redis, every key is looked up and type checked
+// before computing, so that a key of wrong type is reported even after a missing key.
+func (db *DB) computeSets(keys []string, op int) (*HashSet.Set, reply.ErrorReply) {
+	sets := make([]*HashSet.Set, len(keys))
+	for i, key := range keys {
+		set, errReply := db.getAsSet(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		sets[i] = set
+	}
+	var result *HashSet.Set
+	for i, set := range sets {
+		if set == nil {
+			if op == setIntersect || (op == setDiff && i == 0) {
+				// intersecting with an empty set, or diff from an empty set, always gets an empty set
+				return HashSet.Make(), nil
+			}
+			continue
+		}
+		if result == nil {
+			result = set.Union(HashSet.Make()) // copy
+			continue
+		}
+		switch op {
+		case setIntersect:
+			result = result.Intersect(set)
+		case setUnion:
+			result = result.Union(set)
+		case setDiff:
+			result = result.Diff(set)
+		}
+	}
+	if result == nil {
+		result = HashSet.Make()
+	}
+	return result, nil
+}
+
+// storeSet saves the result of set algebra into destination
+func (db *DB) storeSet(dest string, set *HashSet.Set) {
+	if set.Len() == 0 {
+		db.Remove(dest)
+		return
+	}
+	db.PutEntity(dest, &database.DataEntity{
+		Data: set,
+	})
+}
+
+func bytesToKeys(args [][]byte) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return keys
+}
+
+// execSInter intersects multiple sets
+func execSInter(db *DB, args [][]byte) resp.Reply {
+	result, errReply := db.computeSets(bytesToKeys(args), setIntersect)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(result)
+}
+
+// execSInterStore intersects multiple sets and stores the result in destination
+func execSInterStore(db *DB, args [][]byte) resp.Reply {
+	result, errReply := db.computeSets(bytesToKeys(args[1:]), setIntersect)
+	if errReply != nil {
+		return errReply
+	}
+	db.storeSet(string(args[0]), result)
+	db.addAof(utils.ToCmdLine2("sinterstore", args...))
+	return reply.MakeIntReply(int64(result.Len()))
+}
+
+// execSUnion adds multiple sets
+func execSUnion(db *DB, args [][]byte) resp.Reply {
+	result, errReply := db.computeSets(bytesToKeys(args), setUnion)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(result)
+}
+
+// execSUnionStore adds multiple sets and stores the result in destination
+func execSUnionStore(db *DB, args [][]byte) resp.Reply {
+	result, errReply := db.computeSets(bytesToKeys(args[1:]), setUnion)
+	if errReply != nil {
+		return errReply
+	}
+	db.storeSet(string(args[0]), result)
+	db.addAof(utils.ToCmdLine2("sunionstore", args...))
+	return reply.MakeIntReply(int64(result.Len()))
+}
+
+// execSDiff subtracts multiple sets from the first one
+func execSDiff(db *DB, args [][]byte) resp.Reply {
+	result, errReply := db.computeSets(bytesToKeys(args), setDiff)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(result)
+}
+
+// execSDiffStore subtracts multiple sets from the first one and stores the result in destination
+func execSDiffStore(db *DB, args [][]byte) resp.Reply {
+	result, errReply := db.computeSets(bytesToKeys(args[1:]), setDiff)
+	if errReply != nil {
+		return errReply
+	}
+	db.storeSet(string(args[0]), result)
+	db.addAof(utils.ToCmdLine2("sdiffstore", args...))
+	return reply.MakeIntReply(int64(result.Len()))
+}
+
+// execSInterCard returns the cardinality of the intersection
+func execSInterCard(db *DB, args [][]byte) resp.Reply {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys <= 0 {
+		return reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return reply.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys := bytesToKeys(args[1 : 1+numKeys])
+
+	var limit int64 = 0 // 0 means unlimited
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		if strings.ToUpper(string(rest[i])) != "LIMIT" || i+1 >= len(rest) {
+			return &reply.SyntaxErrReply{}
+		}
+		limit, err = strconv.ParseInt(string(rest[i+1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR LIMIT can't be negative")
+		}
+		if limit < 0 {
+			return reply.MakeErrReply("ERR LIMIT can't be negative")
+		}
+		i++
+	}
+
+	result, errReply := db.computeSets(keys, setIntersect)
+	if errReply != nil {
+		return errReply
+	}
+	card := int64(result.Len())
+	if limit > 0 && card > limit {
+		card = limit
+	}
+	return reply.MakeIntReply(card)
+}
+
+func init() {
+	RegisterCommand("SAdd", execSAdd, -3).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("SIsMember", execSIsMember, 3)
+	RegisterCommand("SMIsMember", execSMIsMember, -3)
+	RegisterCommand("SRem", execSRem, -3).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("SCard", execSCard, 2)
+	RegisterCommand("SMembers", execSMembers, 2)
+	RegisterCommand("SPop", execSPop, -2).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("SRandMember", execSRandMember, -2)
+	RegisterCommand("SMove", execSMove, 4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 2, 1)
+	RegisterCommand("SInter", execSInter, -2)
+	RegisterCommand("SInterStore", execSInterStore, -3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("SUnion", execSUnion, -2)
+	RegisterCommand("SUnionStore", execSUnionStore, -3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("SDiff", execSDiff, -2)
+	RegisterCommand("SDiffStore", execSDiffStore, -3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("SInterCard", execSInterCard, -3)
+}
@@ -0,0 +1,303 @@
+package database
+
+import (
+	"bytes"
+	Dict "goRedis/datastruct/dict"
+	List "goRedis/datastruct/list"
+	HashSet "goRedis/datastruct/set"
+	"goRedis/datastruct/sortedset"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortOptions are the arguments of SORT after the key
+type sortOptions struct {
+	byPattern   string
+	dontSort    bool
+	getPatterns []string
+	offset      int
+	count       int // negative means all
+	desc        bool
+	alpha       bool
+	store       string
+}
+
+// sortItem is an element to be sorted and its weight
+type sortItem struct {
+	value []byte
+	score float64
+	// weight is the BY value compared by ALPHA, nil if the weight key does not exist
+	weight []byte
+}
+
+// parseSortOptions parses options of SORT, readOnly refuses STORE like SORT_RO
+func parseSortOptions(args [][]byte, readOnly bool) (*sortOptions, reply.ErrorReply) {
+	opts := &sortOptions{count: -1}
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		remaining := len(args) - i - 1
+		switch {
+		case arg == "ASC":
+			opts.desc = false
+		case arg == "DESC":
+			opts.desc = true
+		case arg == "ALPHA":
+			opts.alpha = true
+		case arg == "LIMIT" && remaining >= 2:
+			offset, err1 := strconv.Atoi(string(args[i+1]))
+			count, err2 := strconv.Atoi(string(args[i+2]))
+			if err1 != nil || err2 != nil {
+				return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			opts.offset, opts.count = offset, count
+			i += 2
+		case arg == "STORE" && remaining >= 1 && !readOnly:
+			opts.store = string(args[i+1])
+			i++
+		case arg == "BY" && remaining >= 1:
+			opts.byPattern = string(args[i+1])
+			// sorting by a constant key does nothing, like redis
+			if !strings.Contains(opts.byPattern, "*") {
+				opts.dontSort = true
+			}
+			i++
+		case arg == "GET" && remaining >= 1:
+			opts.getPatterns = append(opts.getPatterns, string(args[i+1]))
+			i++
+		default:
+			return nil, &reply.SyntaxErrReply{}
+		}
+	}
+	return opts, nil
+}
+
+// lookupByPattern returns the value of the key which is the pattern with the first '*' replaced by subst.
+// A pattern like "hash_*->field" gets the field of the hash, and "#" gets subst itself.
+// It returns nil if the key does not exist or is of wrong type.
+func (db *DB) lookupByPattern(pattern string, subst []byte) []byte {
+	if pattern == "#" {
+		return subst
+	}
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return nil
+	}
+	key, field := pattern, ""
+	// the field is after the first "->" following '*', and can not be empty
+	if arrow := strings.Index(pattern[star+1:], "->"); arrow >= 0 && star+arrow+3 < len(pattern) {
+		key, field = pattern[:star+1+arrow], pattern[star+arrow+3:]
+	}
+	key = key[:star] + string(subst) + key[star+1:]
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil
+	}
+	if field == "" {
+		value, _ := entity.Data.([]byte)
+		return value
+	}
+	dict, ok := entity.Data.(Dict.Dict)
+	if !ok {
+		return nil
+	}
+	value, ok := dict.Get(field)
+	if !ok {
+		return nil
+	}
+	val, _ := value.([]byte)
+	return val
+}
+
+// sortElements returns the elements of list, set or sorted set, and whether the collection is ordered by itself
+func sortElements(entity *database.DataEntity) ([][]byte, bool, reply.ErrorReply) {
+	var elements [][]byte
+	switch val := entity.Data.(type) {
+	case List.List:
+		elements = make([][]byte, 0, val.Len())
+		val.ForEach(func(i int, v interface{}) bool {
+			elements = append(elements, v.([]byte))
+			return true
+		})
+		return elements, true, nil
+	case *HashSet.Set:
+		elements = make([][]byte, 0, val.Len())
+		val.ForEach(func(member string) bool {
+			elements = append(elements, []byte(member))
+			return true
+		})
+		return elements, false, nil
+	case *sortedset.SortedSet:
+		elements = make([][]byte, 0, val.Len())
+		val.ForEach(func(element *sortedset.Element) bool {
+			elements = append(elements, []byte(element.Member))
+			return true
+		})
+		return elements, true, nil
+	}
+	return nil, false, &reply.WrongTypeErrReply{}
+}
+
+// compareSortItems compares two items by their weights, ties are broken by the elements themselves
+func compareSortItems(a, b *sortItem, opts *sortOptions) int {
+	cmp := 0
+	switch {
+	case !opts.alpha:
+		if a.score < b.score {
+			cmp = -1
+		} else if a.score > b.score {
+			cmp = 1
+		}
+	case opts.byPattern != "":
+		// missing weights are the smallest
+		if a.weight == nil || b.weight == nil {
+			if a.weight != nil {
+				cmp = 1
+			} else if b.weight != nil {
+				cmp = -1
+			}
+		} else {
+			cmp = bytes.Compare(a.weight, b.weight)
+		}
+	}
+	if cmp == 0 {
+		cmp = bytes.Compare(a.value, b.value)
+	}
+	return cmp
+}
+
+// sortGeneric is the implementation of SORT and SORT_RO
+func sortGeneric(db *DB, args [][]byte, readOnly bool) resp.Reply {
+	key := string(args[0])
+	opts, errReply := parseSortOptions(args[1:], readOnly)
+	if errReply != nil {
+		return errReply
+	}
+
+	var elements [][]byte
+	ordered, isZSet := false, false
+	if entity, exists := db.GetEntity(key); exists {
+		elements, ordered, errReply = sortElements(entity)
+		if errReply != nil {
+			return errReply
+		}
+		_, isZSet = entity.Data.(*sortedset.SortedSet)
+	}
+	// elements of set are in random order, sort them anyway so that the stored result is deterministic
+	if opts.dontSort && !ordered && opts.store != "" {
+		opts.dontSort = false
+		opts.alpha = true
+		opts.byPattern = ""
+	}
+
+	// the range of LIMIT
+	start, end := opts.offset, len(elements)
+	if start < 0 {
+		start = 0
+	}
+	if start > len(elements) {
+		start = len(elements)
+	}
+	if opts.count >= 0 && start+opts.count < end {
+		end = start + opts.count
+	}
+
+	items := make([]*sortItem, len(elements))
+	for i, element := range elements {
+		items[i] = &sortItem{value: element}
+	}
+	if !opts.dontSort {
+		for _, item := range items {
+			weight := item.value
+			if opts.byPattern != "" {
+				weight = db.lookupByPattern(opts.byPattern, item.value)
+			}
+			if opts.alpha {
+				item.weight = weight
+				continue
+			}
+			if weight == nil {
+				// missing weights are regarded as 0
+				continue
+			}
+			score, err := strconv.ParseFloat(string(weight), 64)
+			if err != nil || math.IsNaN(score) {
+				return reply.MakeErrReply("ERR One or more scores can't be converted into double")
+			}
+			item.score = score
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			cmp := compareSortItems(items[i], items[j], opts)
+			if opts.desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	} else if opts.desc && isZSet {
+		// members of sorted set in reverse order like redis, lists are kept in their order
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	items = items[start:end]
+
+	var result [][]byte
+	if len(opts.getPatterns) == 0 {
+		result = make([][]byte, len(items))
+		for i, item := range items {
+			result[i] = item.value
+		}
+	} else {
+		result = make([][]byte, 0, len(items)*len(opts.getPatterns))
+		for _, item := range items {
+			for _, pattern := range opts.getPatterns {
+				result = append(result, db.lookupByPattern(pattern, item.value))
+			}
+		}
+	}
+
+	if opts.store == "" {
+		return reply.MakeMultiBulkReply(result)
+	}
+	list := List.NewQuickList()
+	for _, value := range result {
+		if value == nil {
+			// missing values are stored as empty strings
+			value = []byte{}
+		}
+		list.Add(value)
+	}
+	if list.Len() > 0 {
+		db.PutEntity(opts.store, &database.DataEntity{
+			Data: list,
+		})
+		db.addAof(utils.ToCmdLine2("sort", args...))
+		db.signalKeyReady(opts.store)
+	} else if _, exists := db.GetEntity(opts.store); exists {
+		db.Remove(opts.store)
+		db.addAof(utils.ToCmdLine("del", opts.store))
+		db.notifyKeyspaceEvent(notifyGeneric, "del", opts.store)
+	}
+	return reply.MakeIntReply(int64(len(result)))
+}
+
+// execSort sorts elements of list, set or sorted set:
+// SORT key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]
+func execSort(db *DB, args [][]byte) resp.Reply {
+	return sortGeneric(db, args, false)
+}
+
+// execSortRO is the read-only variant of SORT, STORE is not allowed
+func execSortRO(db *DB, args [][]byte) resp.Reply {
+	return sortGeneric(db, args, true)
+}
+
+func init() {
+	RegisterCommand("Sort", execSort, -2).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("Sort_RO", execSortRO, -2)
+}
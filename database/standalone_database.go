@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"goRedis/aof"
 	"goRedis/config"
+	"goRedis/interface/database"
 	"goRedis/interface/resp"
 	"goRedis/lib/logger"
+	"goRedis/lib/utils"
+	"goRedis/pubsub"
 	"goRedis/resp/reply"
+	"runtime"
 	"runtime/debug"
 	"strconv"
 	"strings"
@@ -17,18 +21,40 @@ type StandaloneDatabase struct {
 	dbSet []*DB
 	// AOF持久化
 	aofHandler *aof.AofHandler
+	// 停止定期清理过期key
+	stopExpire chan struct{}
+	// 发布订阅
+	hub *pubsub.Hub
+	// 在后台释放被删除的大value
+	freer *lazyFreer
+	// 启动时已分配的堆内存，不包括aof加载的数据
+	startupMemory int64
 }
 
 // NewStandaloneDatabase 创建redis数据库
 func NewStandaloneDatabase() *StandaloneDatabase {
-	mdb := &StandaloneDatabase{}
+	mdb := &StandaloneDatabase{
+		stopExpire: make(chan struct{}),
+		hub:        pubsub.MakeHub(),
+		freer:      makeLazyFreer(),
+	}
 	if config.Properties.Databases == 0 {
 		config.Properties.Databases = 16
 	}
+	notifyFlags, err := parseNotifyFlags(config.Properties.NotifyKeyspaceEvents)
+	if err != nil {
+		logger.Warn(err.Error() + ", keyspace notifications are disabled")
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	mdb.startupMemory = int64(memStats.HeapAlloc)
 	mdb.dbSet = make([]*DB, config.Properties.Databases)
 	for i := range mdb.dbSet {
 		singleDB := makeDB()
 		singleDB.index = i
+		singleDB.hub = mdb.hub
+		singleDB.notifyFlags = notifyFlags
+		singleDB.freer = mdb.freer
 		mdb.dbSet[i] = singleDB
 	}
 	if config.Properties.AppendOnly {
@@ -45,6 +71,14 @@ func NewStandaloneDatabase() *StandaloneDatabase {
 			}
 		}
 	}
+	// evict keys after aof loaded like redis, the data set may be larger than maxmemory before restart
+	if evictor := makeEvictor(mdb.dbSet); evictor != nil {
+		for _, db := range mdb.dbSet {
+			db.evictor = evictor
+		}
+	}
+	// start after aof loaded, so that deletions of expired keys are recorded
+	go mdb.activeExpireLoop()
 	return mdb
 }
 
@@ -65,6 +99,52 @@ func (mdb *StandaloneDatabase) Exec(c resp.Connection, cmdLine [][]byte) (result
 		}
 		return execSelect(c, mdb, cmdLine[1:])
 	}
+	// pub/sub commands are not bound to any database
+	switch cmdName {
+	case "subscribe":
+		if len(cmdLine) < 2 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		return pubsub.Subscribe(mdb.hub, c, cmdLine[1:])
+	case "unsubscribe":
+		return pubsub.UnSubscribe(mdb.hub, c, cmdLine[1:])
+	case "psubscribe":
+		if len(cmdLine) < 2 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		return pubsub.PSubscribe(mdb.hub, c, cmdLine[1:])
+	case "punsubscribe":
+		return pubsub.PUnSubscribe(mdb.hub, c, cmdLine[1:])
+	case "publish":
+		return pubsub.Publish(mdb.hub, cmdLine[1:])
+	case "pubsub":
+		return pubsub.PubSub(mdb.hub, cmdLine[1:])
+	}
+	// commands across databases
+	switch cmdName {
+	case "flushall":
+		return execFlushAll(c, mdb, cmdLine[1:])
+	case "swapdb":
+		if len(cmdLine) != 3 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		return execSwapDB(c, mdb, cmdLine[1:])
+	case "move":
+		if len(cmdLine) != 3 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		return execMove(c, mdb, cmdLine[1:])
+	case "copy":
+		if len(cmdLine) < 3 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		return execCopy(c, mdb, cmdLine[1:])
+	case "memory":
+		if len(cmdLine) < 2 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		return execMemory(c, mdb, cmdLine[1:])
+	}
 	// normal commands
 	dbIndex := c.GetDBIndex()
 	if dbIndex >= len(mdb.dbSet) {
@@ -76,10 +156,16 @@ func (mdb *StandaloneDatabase) Exec(c resp.Connection, cmdLine [][]byte) (result
 
 // Close 优雅关机
 func (mdb *StandaloneDatabase) Close() {
-
+	close(mdb.stopExpire)
+	mdb.freer.close()
 }
 
+// AfterClientClose 清理断开连接的客户端，唤醒它被阻塞的命令并取消它的订阅
 func (mdb *StandaloneDatabase) AfterClientClose(c resp.Connection) {
+	for _, db := range mdb.dbSet {
+		db.blocking.cancel(c)
+	}
+	mdb.hub.UnsubscribeAll(c)
 }
 
 func execSelect(c resp.Connection, mdb *StandaloneDatabase, args [][]byte) resp.Reply {
@@ -93,3 +179,141 @@ func execSelect(c resp.Connection, mdb *StandaloneDatabase, args [][]byte) resp.
 	c.SelectDB(dbIndex)
 	return reply.MakeOkReply()
 }
+
+// parseDBIndex parses the index of database
+func (mdb *StandaloneDatabase) parseDBIndex(arg []byte) (int, reply.ErrorReply) {
+	dbIndex, err := strconv.Atoi(string(arg))
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if dbIndex < 0 || dbIndex >= len(mdb.dbSet) {
+		return 0, reply.MakeErrReply("ERR DB index is out of range")
+	}
+	return dbIndex, nil
+}
+
+// execFlushAll removes all keys of all databases
+func execFlushAll(c resp.Connection, mdb *StandaloneDatabase, args [][]byte) resp.Reply {
+	async, errReply := parseFlushMode(args, mdb.freer)
+	if errReply != nil {
+		return errReply
+	}
+	for _, db := range mdb.dbSet {
+		db.flush(async)
+	}
+	mdb.dbSet[c.GetDBIndex()].addAof(utils.ToCmdLine("flushall"))
+	return &reply.OkReply{}
+}
+
+// execSwapDB swaps keys of two databases, clients connected to them see the swapped data immediately
+func execSwapDB(c resp.Connection, mdb *StandaloneDatabase, args [][]byte) resp.Reply {
+	index1, err1 := strconv.Atoi(string(args[0]))
+	if err1 != nil {
+		return reply.MakeErrReply("ERR invalid first DB index")
+	}
+	index2, err2 := strconv.Atoi(string(args[1]))
+	if err2 != nil {
+		return reply.MakeErrReply("ERR invalid second DB index")
+	}
+	if index1 < 0 || index1 >= len(mdb.dbSet) || index2 < 0 || index2 >= len(mdb.dbSet) {
+		return reply.MakeErrReply("ERR DB index is out of range")
+	}
+	if errReply := mdb.denyOOM(); errReply != nil {
+		return errReply
+	}
+	db1, db2 := mdb.dbSet[index1], mdb.dbSet[index2]
+	if index1 != index2 {
+		// swap keyspace only, blocked clients and aof of each index stay unchanged.
+		// 按下标顺序加锁，避免两个方向相反的SWAPDB死锁
+		first, second := db1, db2
+		if index1 > index2 {
+			first, second = db2, db1
+		}
+		first.spaceMu.Lock()
+		second.spaceMu.Lock()
+		db1.space.Store(db2.space.Swap(db1.keyspace()))
+		second.spaceMu.Unlock()
+		first.spaceMu.Unlock()
+	}
+	mdb.dbSet[c.GetDBIndex()].addAof(utils.ToCmdLine2("swapdb", args...))
+	db1.blocking.allKeysReady()
+	db2.blocking.allKeysReady()
+	return &reply.OkReply{}
+}
+
+// execMove moves a key from current database to another, the ttl is kept
+func execMove(c resp.Connection, mdb *StandaloneDatabase, args [][]byte) resp.Reply {
+	key := string(args[0])
+	destIndex, errReply := mdb.parseDBIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	srcIndex := c.GetDBIndex()
+	if srcIndex == destIndex {
+		return reply.MakeErrReply("ERR source and destination objects are the same")
+	}
+	if errReply := mdb.denyOOM(); errReply != nil {
+		return errReply
+	}
+	srcDB, destDB := mdb.dbSet[srcIndex], mdb.dbSet[destIndex]
+	entity, exists := srcDB.GetEntity(key)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+	if _, exists := destDB.GetEntity(key); exists {
+		return reply.MakeIntReply(0)
+	}
+	srcDB.Remove(key)
+	destDB.PutEntity(key, entity)
+	srcDB.addAof(utils.ToCmdLine2("move", args...))
+	srcDB.notifyKeyspaceEvent(notifyGeneric, "move_from", key)
+	destDB.notifyKeyspaceEvent(notifyGeneric, "move_to", key)
+	destDB.signalKeyReady(key)
+	return reply.MakeIntReply(1)
+}
+
+// execCopy copies value of source key to destination key, which may be in another database
+func execCopy(c resp.Connection, mdb *StandaloneDatabase, args [][]byte) resp.Reply {
+	src, dest := string(args[0]), string(args[1])
+	srcIndex := c.GetDBIndex()
+	destIndex := srcIndex
+	replace := false
+	for i := 2; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "REPLACE":
+			replace = true
+		case arg == "DB" && i+1 < len(args):
+			var errReply reply.ErrorReply
+			destIndex, errReply = mdb.parseDBIndex(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			i++
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+	}
+	if srcIndex == destIndex && src == dest {
+		return reply.MakeErrReply("ERR source and destination objects are the same")
+	}
+	srcDB, destDB := mdb.dbSet[srcIndex], mdb.dbSet[destIndex]
+	if errReply := mdb.denyOOM(); errReply != nil {
+		return errReply
+	}
+	entity, exists := srcDB.GetEntity(src)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+	if _, exists := destDB.GetEntity(dest); exists && !replace {
+		return reply.MakeIntReply(0)
+	}
+	destDB.PutEntity(dest, &database.DataEntity{
+		Data:       copyData(entity.Data),
+		ExpireTime: entity.ExpireTime,
+	})
+	srcDB.addAof(utils.ToCmdLine2("copy", args...))
+	destDB.notifyKeyspaceEvent(notifyGeneric, "copy_to", dest)
+	destDB.signalKeyReady(dest)
+	return reply.MakeIntReply(1)
+}
@@ -0,0 +1,1386 @@
+package database
+
+import (
+	"goRedis/datastruct/stream"
+	"goRedis/interface/database"
+	"goRedis/interface/resp"
+	"goRedis/lib/utils"
+	"goRedis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	xTrimNone = iota
+	xTrimMaxLen
+	xTrimMinID
+)
+
+// xTrimOption is the parsed MAXLEN/MINID argument of XADD and XTRIM
+type xTrimOption struct {
+	strategy int
+	maxLen   int
+	minID    stream.ID
+	limit    int
+}
+
+// getAsStream gets entity as stream
+func (db *DB) getAsStream(key string) (*stream.Stream, reply.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*stream.Stream)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return s, nil
+}
+
+func (db *DB) getOrInitStream(key string) (s *stream.Stream, inited bool, errReply reply.ErrorReply) {
+	s, errReply = db.getAsStream(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if s == nil {
+		s = stream.Make()
+		db.PutEntity(key, &database.DataEntity{
+			Data: s,
+		})
+		inited = true
+	}
+	return s, inited, nil
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / 1e6
+}
+
+func makeNoGroupErrReply(key, group string) reply.ErrorReply {
+	return reply.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + group + "'")
+}
+
+func entryToReply(entry *stream.Entry) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(entry.ID.String())),
+		reply.MakeMultiBulkReply(entry.Fields),
+	})
+}
+
+func entriesToReply(entries []*stream.Entry) resp.Reply {
+	replies := make([]resp.Reply, len(entries))
+	for i, entry := range entries {
+		replies[i] = entryToReply(entry)
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+// parseStreamID parses a complete or incomplete ID, missing sequence number is treated as 0
+func parseStreamID(arg []byte) (stream.ID, reply.ErrorReply) {
+	id, err := stream.ParseID(string(arg), 0)
+	if err != nil {
+		return id, reply.MakeErrReply(err.Error())
+	}
+	return id, nil
+}
+
+// parseRangeID parses the start or end of XRANGE, supports `-`, `+` and exclusive `(` prefix
+func parseRangeID(arg []byte, isStart bool) (stream.ID, reply.ErrorReply) {
+	s := string(arg)
+	if s == "-" {
+		return stream.MinID, nil
+	}
+	if s == "+" {
+		return stream.MaxID, nil
+	}
+	var missingSeq uint64 = 0
+	if !isStart {
+		missingSeq = math.MaxUint64
+	}
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	id, err := stream.ParseID(s, missingSeq)
+	if err != nil {
+		return id, reply.MakeErrReply(err.Error())
+	}
+	if !exclusive {
+		return id, nil
+	}
+	var ok bool
+	if isStart {
+		id, ok = id.Incr()
+		if !ok {
+			return id, reply.MakeErrReply("ERR invalid start ID for the interval")
+		}
+	} else {
+		id, ok = id.Decr()
+		if !ok {
+			return id, reply.MakeErrReply("ERR invalid end ID for the interval")
+		}
+	}
+	return id, nil
+}
+
+// parseXTrimOption parses `MAXLEN|MINID [=|~] threshold [LIMIT count]` starting at args[i], returns index of the next argument
+func parseXTrimOption(args [][]byte, i int) (*xTrimOption, int, reply.ErrorReply) {
+	option := &xTrimOption{}
+	switch strings.ToUpper(string(args[i])) {
+	case "MAXLEN":
+		option.strategy = xTrimMaxLen
+	case "MINID":
+		option.strategy = xTrimMinID
+	default:
+		return nil, i, &reply.SyntaxErrReply{}
+	}
+	i++
+	approx := false
+	if i < len(args) && (string(args[i]) == "=" || string(args[i]) == "~") {
+		approx = string(args[i]) == "~"
+		i++
+	}
+	if i >= len(args) {
+		return nil, i, &reply.SyntaxErrReply{}
+	}
+	if option.strategy == xTrimMaxLen {
+		maxLen, err := strconv.ParseInt(string(args[i]), 10, 64)
+		if err != nil {
+			return nil, i, reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if maxLen < 0 {
+			return nil, i, reply.MakeErrReply("ERR The MAXLEN argument must be >= 0.")
+		}
+		option.maxLen = int(maxLen)
+	} else {
+		minID, errReply := parseStreamID(args[i])
+		if errReply != nil {
+			return nil, i, errReply
+		}
+		option.minID = minID
+	}
+	i++
+	if i < len(args) && strings.ToUpper(string(args[i])) == "LIMIT" {
+		if i+1 >= len(args) {
+			return nil, i, &reply.SyntaxErrReply{}
+		}
+		limit, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil || limit < 0 {
+			return nil, i, reply.MakeErrReply("ERR The LIMIT argument must be >= 0.")
+		}
+		if !approx {
+			return nil, i, reply.MakeErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		option.limit = int(limit)
+		i += 2
+	}
+	return option, i, nil
+}
+
+// trimStream evicts entries according to option and logs it into aof, returns the number of evicted entries
+func (db *DB) trimStream(key string, s *stream.Stream, option *xTrimOption) int {
+	evicted := 0
+	switch option.strategy {
+	case xTrimMaxLen:
+		evicted = s.TrimMaxLen(option.maxLen, option.limit)
+	case xTrimMinID:
+		evicted = s.TrimMinID(option.minID, option.limit)
+	}
+	if evicted > 0 {
+		// log the exact result, so that LIMIT and `~` make no difference while replaying
+		if first := s.First(); first != nil {
+			db.addAof(utils.ToCmdLine("xtrim", key, "minid", first.ID.String()))
+		} else {
+			db.addAof(utils.ToCmdLine("xtrim", key, "maxlen", "0"))
+		}
+	}
+	return evicted
+}
+
+// execXAdd appends a new entry to stream
+func execXAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	noMkStream := false
+	var trimOption *xTrimOption
+
+	// parse options
+	i := 1
+	for ; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		if arg == "NOMKSTREAM" {
+			noMkStream = true
+		} else if arg == "MAXLEN" || arg == "MINID" {
+			var errReply reply.ErrorReply
+			trimOption, i, errReply = parseXTrimOption(args, i)
+			if errReply != nil {
+				return errReply
+			}
+			i--
+		} else {
+			break
+		}
+	}
+	if i >= len(args) || (len(args)-i-1) == 0 || (len(args)-i-1)%2 != 0 {
+		return reply.MakeArgNumErrReply("xadd")
+	}
+	idArg := string(args[i])
+	fields := args[i+1:]
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil && noMkStream {
+		return &reply.NullBulkReply{}
+	}
+	probe := s
+	if probe == nil {
+		probe = stream.Make()
+	}
+
+	// generate id
+	var id stream.ID
+	var ok bool
+	if idArg == "*" {
+		id, ok = probe.NextID(uint64(nowMillis()))
+		if !ok {
+			return reply.MakeErrReply("ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+	} else if strings.HasSuffix(idArg, "-*") {
+		ms, err := strconv.ParseUint(strings.TrimSuffix(idArg, "-*"), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply(stream.ErrInvalidID.Error())
+		}
+		id, ok = probe.NextSeqID(ms)
+		if !ok {
+			return reply.MakeErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+	} else {
+		id, errReply = parseStreamID([]byte(idArg))
+		if errReply != nil {
+			return errReply
+		}
+		if id.IsZero() {
+			return reply.MakeErrReply("ERR The ID specified in XADD must be greater than 0-0")
+		}
+		if !probe.LastID().Less(id) {
+			return reply.MakeErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+	}
+
+	if s == nil {
+		s, _, _ = db.getOrInitStream(key)
+	}
+	values := make([][]byte, len(fields))
+	copy(values, fields)
+	s.Add(id, values)
+	// log the generated id instead of `*`, so that replaying the aof rebuilds the same stream
+	db.addAof(utils.ToCmdLine2("xadd", append([][]byte{args[0], []byte(id.String())}, fields...)...))
+	if trimOption != nil {
+		db.trimStream(key, s, trimOption)
+	}
+	db.signalKeyReady(key)
+	return reply.MakeBulkReply([]byte(id.String()))
+}
+
+// execXLen returns the number of entries in stream
+func execXLen(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(s.Len()))
+}
+
+// execXRange returns entries with ID in range
+func execXRange(db *DB, args [][]byte) resp.Reply {
+	return xRange(db, args, false)
+}
+
+// execXRevRange returns entries with ID in range in reverse order
+func execXRevRange(db *DB, args [][]byte) resp.Reply {
+	return xRange(db, args, true)
+}
+
+// xRange is the underlying implementation of xrange and xrevrange
+func xRange(db *DB, args [][]byte, reverse bool) resp.Reply {
+	key := string(args[0])
+	startArg, endArg := args[1], args[2]
+	if reverse {
+		startArg, endArg = endArg, startArg
+	}
+	start, errReply := parseRangeID(startArg, true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(endArg, false)
+	if errReply != nil {
+		return errReply
+	}
+	count := -1
+	if len(args) > 3 {
+		if len(args) != 5 || strings.ToUpper(string(args[3])) != "COUNT" {
+			return &reply.SyntaxErrReply{}
+		}
+		n, err := strconv.ParseInt(string(args[4]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		count = int(n)
+		if count < 0 {
+			count = 0
+		}
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil || count == 0 {
+		return &reply.EmptyMultiBulkReply{}
+	}
+	return entriesToReply(s.Range(start, end, count, reverse))
+}
+
+// execXDel removes entries from stream
+func execXDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	ids := make([]stream.ID, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		id, errReply := parseStreamID(arg)
+		if errReply != nil {
+			return errReply
+		}
+		ids = append(ids, id)
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	deleted := s.Delete(ids...)
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine2("xdel", args...))
+	}
+	return reply.MakeIntReply(int64(deleted))
+}
+
+// execXTrim evicts older entries of stream
+func execXTrim(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	option, next, errReply := parseXTrimOption(args, 1)
+	if errReply != nil {
+		return errReply
+	}
+	if next != len(args) {
+		return &reply.SyntaxErrReply{}
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(db.trimStream(key, s, option)))
+}
+
+// parseStreamsArgs splits `key [key ...] id [id ...]` following STREAMS
+func parseStreamsArgs(args [][]byte, cmdName string) (keys []string, ids []string, errReply reply.ErrorReply) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, nil, reply.MakeErrReply("ERR Unbalanced '" + cmdName + "' list of streams: for each stream key an ID or '$' must be specified.")
+	}
+	size := len(args) / 2
+	keys = bytesToKeys(args[:size])
+	ids = make([]string, size)
+	for i, arg := range args[size:] {
+		ids[i] = string(arg)
+	}
+	return keys, ids, nil
+}
+
+// parseBlockMillis parses the timeout of BLOCK option in milliseconds
+func parseBlockMillis(arg []byte) (time.Duration, reply.ErrorReply) {
+	ms, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR timeout is not an integer or out of range")
+	}
+	if ms < 0 {
+		return 0, reply.MakeErrReply("ERR timeout is negative")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func streamReadReply(key string, entries resp.Reply) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(key)),
+		entries,
+	})
+}
+
+// execXRead reads entries newer than the given IDs from streams, blocks if BLOCK is given and there is no entry
+func execXRead(db *DB, args [][]byte) resp.Reply {
+	count := -1
+	blocking := false
+	var timeout time.Duration
+	i := 0
+	for ; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		if arg == "STREAMS" {
+			break
+		}
+		if i+1 >= len(args) {
+			return &reply.SyntaxErrReply{}
+		}
+		switch arg {
+		case "COUNT":
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			count = int(n)
+		case "BLOCK":
+			var errReply reply.ErrorReply
+			timeout, errReply = parseBlockMillis(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			blocking = true
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+		i++
+	}
+	if i >= len(args) {
+		return &reply.SyntaxErrReply{}
+	}
+	keys, rawIDs, errReply := parseStreamsArgs(args[i+1:], "xread")
+	if errReply != nil {
+		return errReply
+	}
+
+	// resolve ids
+	idOfKey := make(map[string]stream.ID, len(keys))
+	for j, key := range keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if rawIDs[j] == ">" {
+			return reply.MakeErrReply("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+		}
+		if rawIDs[j] == "$" {
+			if s != nil {
+				idOfKey[key] = s.LastID()
+			} else {
+				idOfKey[key] = stream.MinID
+			}
+			continue
+		}
+		id, errReply := parseStreamID([]byte(rawIDs[j]))
+		if errReply != nil {
+			return errReply
+		}
+		idOfKey[key] = id
+	}
+
+	read := func(key string) resp.Reply {
+		s, _ := db.getAsStream(key)
+		if s == nil {
+			return nil
+		}
+		entries := s.After(idOfKey[key], count)
+		if len(entries) == 0 {
+			return nil
+		}
+		return streamReadReply(key, entriesToReply(entries))
+	}
+	result := make([]resp.Reply, 0)
+	for _, key := range keys {
+		if r := read(key); r != nil {
+			result = append(result, r)
+		}
+	}
+	if len(result) > 0 {
+		return reply.MakeMultiRawReply(result)
+	}
+	if !blocking {
+		return &reply.NullMultiBulkReply{}
+	}
+	return &blockingReply{
+		keys:    keys,
+		timeout: timeout,
+		serve: func(key string) resp.Reply {
+			r := read(key)
+			if r == nil {
+				return nil
+			}
+			return reply.MakeMultiRawReply([]resp.Reply{r})
+		},
+		timeoutReply: &reply.NullMultiBulkReply{},
+	}
+}
+
+// deliverNewEntries delivers entries never delivered to the group, returns nil if there is no new entry
+func (db *DB) deliverNewEntries(key string, s *stream.Stream, group *stream.Group, consumer *stream.Consumer,
+	count int, noAck bool) []*stream.Entry {
+	entries := s.After(group.LastID, count)
+	if len(entries) == 0 {
+		return nil
+	}
+	now := nowMillis()
+	consumer.SeenTime = now
+	consumer.ActiveTime = now
+	for _, entry := range entries {
+		group.LastID = entry.ID
+		if noAck {
+			continue
+		}
+		group.SetPending(entry.ID, consumer, now, 1)
+		db.addAof(utils.ToCmdLine("xclaim", key, group.Name, consumer.Name, "0", entry.ID.String(),
+			"TIME", strconv.FormatInt(now, 10), "RETRYCOUNT", "1", "FORCE", "JUSTID"))
+	}
+	db.addAof(utils.ToCmdLine("xgroup", "setid", key, group.Name, group.LastID.String()))
+	return entries
+}
+
+// execXReadGroup reads entries from streams on behalf of a consumer of group
+func execXReadGroup(db *DB, args [][]byte) resp.Reply {
+	if strings.ToUpper(string(args[0])) != "GROUP" {
+		return &reply.SyntaxErrReply{}
+	}
+	groupName := string(args[1])
+	consumerName := string(args[2])
+	count := -1
+	noAck := false
+	blocking := false
+	var timeout time.Duration
+	i := 3
+	for ; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		if arg == "STREAMS" {
+			break
+		}
+		if arg == "NOACK" {
+			noAck = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return &reply.SyntaxErrReply{}
+		}
+		switch arg {
+		case "COUNT":
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			count = int(n)
+		case "BLOCK":
+			var errReply reply.ErrorReply
+			timeout, errReply = parseBlockMillis(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			blocking = true
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+		i++
+	}
+	if i >= len(args) {
+		return &reply.SyntaxErrReply{}
+	}
+	keys, rawIDs, errReply := parseStreamsArgs(args[i+1:], "xreadgroup")
+	if errReply != nil {
+		return errReply
+	}
+
+	// validate streams and groups before reading anything
+	historyIDs := make(map[string]stream.ID)
+	for j, key := range keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil || s.GetGroup(groupName) == nil {
+			return reply.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + groupName +
+				"' in XREADGROUP with GROUP option")
+		}
+		switch rawIDs[j] {
+		case ">":
+		case "$":
+			return reply.MakeErrReply("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+		default:
+			id, errReply := parseStreamID([]byte(rawIDs[j]))
+			if errReply != nil {
+				return errReply
+			}
+			historyIDs[key] = id
+		}
+	}
+
+	getConsumer := func(key string, group *stream.Group) *stream.Consumer {
+		consumer, created := group.CreateConsumer(consumerName, nowMillis())
+		if created {
+			db.addAof(utils.ToCmdLine("xgroup", "createconsumer", key, groupName, consumerName))
+		}
+		return consumer
+	}
+	readNew := func(key string) resp.Reply {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil || s.GetGroup(groupName) == nil {
+			return reply.MakeErrReply("NOGROUP the consumer group this client was blocked on no longer exists")
+		}
+		group := s.GetGroup(groupName)
+		consumer := getConsumer(key, group)
+		consumer.SeenTime = nowMillis()
+		entries := db.deliverNewEntries(key, s, group, consumer, count, noAck)
+		if len(entries) == 0 {
+			return nil
+		}
+		db.refreshMemory(key)
+		return streamReadReply(key, entriesToReply(entries))
+	}
+
+	result := make([]resp.Reply, 0)
+	hasHistory := false
+	for _, key := range keys {
+		historyID, isHistory := historyIDs[key]
+		if !isHistory {
+			if r := readNew(key); r != nil {
+				result = append(result, r)
+			}
+			continue
+		}
+		// read the history of consumer from its pending entries
+		hasHistory = true
+		s, _ := db.getAsStream(key)
+		group := s.GetGroup(groupName)
+		consumer := getConsumer(key, group)
+		consumer.SeenTime = nowMillis()
+		pendings := group.ConsumerPendingAfter(consumer, historyID, count)
+		replies := make([]resp.Reply, len(pendings))
+		for j, pending := range pendings {
+			entry := s.Get(pending.ID)
+			if entry == nil {
+				// the entry was deleted but still pending
+				replies[j] = reply.MakeMultiRawReply([]resp.Reply{
+					reply.MakeBulkReply([]byte(pending.ID.String())),
+					&reply.NullMultiBulkReply{},
+				})
+				continue
+			}
+			replies[j] = entryToReply(entry)
+		}
+		result = append(result, streamReadReply(key, reply.MakeMultiRawReply(replies)))
+		db.refreshMemory(key)
+	}
+	if len(result) > 0 {
+		return reply.MakeMultiRawReply(result)
+	}
+	if !blocking || hasHistory {
+		return &reply.NullMultiBulkReply{}
+	}
+	return &blockingReply{
+		keys:    keys,
+		timeout: timeout,
+		serve: func(key string) resp.Reply {
+			r := readNew(key)
+			if r == nil {
+				return nil
+			}
+			if reply.IsErrorReply(r) {
+				return r
+			}
+			return reply.MakeMultiRawReply([]resp.Reply{r})
+		},
+		timeoutReply: &reply.NullMultiBulkReply{},
+	}
+}
+
+// execXAck acknowledges pending entries of group
+func execXAck(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	groupName := string(args[1])
+	ids := make([]stream.ID, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		id, errReply := parseStreamID(arg)
+		if errReply != nil {
+			return errReply
+		}
+		ids = append(ids, id)
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	group := s.GetGroup(groupName)
+	if group == nil {
+		return reply.MakeIntReply(0)
+	}
+	acked := 0
+	for _, id := range ids {
+		if group.Ack(id) {
+			acked++
+		}
+	}
+	if acked > 0 {
+		db.addAof(utils.ToCmdLine2("xack", args...))
+	}
+	return reply.MakeIntReply(int64(acked))
+}
+
+// resolveGroupID parses the ID of XGROUP CREATE and SETID, `$` means the last ID of stream
+func resolveGroupID(s *stream.Stream, arg []byte) (stream.ID, reply.ErrorReply) {
+	if string(arg) == "$" {
+		return s.LastID(), nil
+	}
+	return parseStreamID(arg)
+}
+
+// parseEntriesRead validates the optional ENTRIESREAD argument, the counter itself is derived from the stream
+func parseEntriesRead(args [][]byte) reply.ErrorReply {
+	if len(args) == 0 {
+		return nil
+	}
+	if len(args) != 2 || strings.ToUpper(string(args[0])) != "ENTRIESREAD" {
+		return &reply.SyntaxErrReply{}
+	}
+	n, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || n < -1 {
+		return reply.MakeErrReply("ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return nil
+}
+
+var xGroupHelp = []string{
+	"XGROUP <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"CREATE <key> <groupname> <id|$> [option]",
+	"    Create a new consumer group. Options are:",
+	"    * MKSTREAM",
+	"      Create the empty stream if it does not exist.",
+	"    * ENTRIESREAD entries_read",
+	"      Set the group's entries_read counter (internal use).",
+	"CREATECONSUMER <key> <groupname> <consumer>",
+	"    Create a new consumer in the specified group.",
+	"DELCONSUMER <key> <groupname> <consumer>",
+	"    Remove the specified consumer.",
+	"DESTROY <key> <groupname>",
+	"    Remove the specified group.",
+	"SETID <key> <groupname> <id|$> [ENTRIESREAD entries_read]",
+	"    Set the current group ID and entries_read counter.",
+	"HELP",
+	"    Print this help.",
+}
+
+func makeHelpReply(lines []string) resp.Reply {
+	replies := make([]resp.Reply, len(lines))
+	for i, line := range lines {
+		replies[i] = reply.MakeStatusReply(line)
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+// execXGroup manages consumer groups
+func execXGroup(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	if subCmd == "HELP" {
+		return makeHelpReply(xGroupHelp)
+	}
+	arity := map[string]int{
+		"CREATE":         4,
+		"SETID":          4,
+		"DESTROY":        3,
+		"CREATECONSUMER": 4,
+		"DELCONSUMER":    4,
+	}
+	minArgs, ok := arity[subCmd]
+	if !ok {
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XGROUP HELP.")
+	}
+	if len(args) < minArgs {
+		return reply.MakeErrReply("ERR wrong number of arguments for 'xgroup|" + strings.ToLower(subCmd) + "' command")
+	}
+	key := string(args[1])
+	groupName := string(args[2])
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if subCmd == "CREATE" {
+		return xGroupCreate(db, s, args)
+	}
+	if s == nil {
+		return reply.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. " +
+			"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	}
+
+	switch subCmd {
+	case "DESTROY":
+		if len(args) != 3 {
+			return reply.MakeArgNumErrReply("xgroup|destroy")
+		}
+		if !s.DestroyGroup(groupName) {
+			return reply.MakeIntReply(0)
+		}
+		db.addAof(utils.ToCmdLine2("xgroup", args...))
+		return reply.MakeIntReply(1)
+	}
+
+	group := s.GetGroup(groupName)
+	if group == nil {
+		return reply.MakeErrReply("NOGROUP No such consumer group '" + groupName + "' for key name '" + key + "'")
+	}
+	switch subCmd {
+	case "SETID":
+		id, errReply := resolveGroupID(s, args[3])
+		if errReply != nil {
+			return errReply
+		}
+		if errReply := parseEntriesRead(args[4:]); errReply != nil {
+			return errReply
+		}
+		group.LastID = id
+		db.addAof(utils.ToCmdLine("xgroup", "setid", key, groupName, id.String()))
+		return &reply.OkReply{}
+	case "CREATECONSUMER":
+		if len(args) != 4 {
+			return reply.MakeArgNumErrReply("xgroup|createconsumer")
+		}
+		_, created := group.CreateConsumer(string(args[3]), nowMillis())
+		if !created {
+			return reply.MakeIntReply(0)
+		}
+		db.addAof(utils.ToCmdLine2("xgroup", args...))
+		return reply.MakeIntReply(1)
+	default: // DELCONSUMER
+		if len(args) != 4 {
+			return reply.MakeArgNumErrReply("xgroup|delconsumer")
+		}
+		pending, existed := group.DeleteConsumer(string(args[3]))
+		if existed {
+			db.addAof(utils.ToCmdLine2("xgroup", args...))
+		}
+		return reply.MakeIntReply(int64(pending))
+	}
+}
+
+// xGroupCreate implements XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD n]
+func xGroupCreate(db *DB, s *stream.Stream, args [][]byte) resp.Reply {
+	key := string(args[1])
+	groupName := string(args[2])
+	mkStream := false
+	rest := args[4:]
+	if len(rest) > 0 && strings.ToUpper(string(rest[0])) == "MKSTREAM" {
+		mkStream = true
+		rest = rest[1:]
+	}
+	if errReply := parseEntriesRead(rest); errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if !mkStream {
+			return reply.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. " +
+				"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+		}
+		s = stream.Make()
+	}
+	id, errReply := resolveGroupID(s, args[3])
+	if errReply != nil {
+		return errReply
+	}
+	if s.GetGroup(groupName) != nil {
+		return reply.MakeErrReply("BUSYGROUP Consumer Group name already exists")
+	}
+	if _, exists := db.GetEntity(key); !exists {
+		db.PutEntity(key, &database.DataEntity{
+			Data: s,
+		})
+	}
+	s.CreateGroup(groupName, id)
+	// `$` is resolved, so that the group starts from the same position after replaying
+	db.addAof(utils.ToCmdLine("xgroup", "create", key, groupName, id.String(), "MKSTREAM"))
+	return &reply.OkReply{}
+}
+
+// execXPending inspects pending entries of group
+func execXPending(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	groupName := string(args[1])
+
+	// parse extended form: [IDLE min-idle-time] start end count [consumer]
+	extended := len(args) > 2
+	var minIdle int64 = 0
+	var start, end stream.ID
+	count := 0
+	consumerName := ""
+	if extended {
+		rest := args[2:]
+		if strings.ToUpper(string(rest[0])) == "IDLE" {
+			if len(rest) < 2 {
+				return &reply.SyntaxErrReply{}
+			}
+			n, err := strconv.ParseInt(string(rest[1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			minIdle = n
+			rest = rest[2:]
+		}
+		if len(rest) < 3 || len(rest) > 4 {
+			return &reply.SyntaxErrReply{}
+		}
+		var errReply reply.ErrorReply
+		start, errReply = parseRangeID(rest[0], true)
+		if errReply != nil {
+			return errReply
+		}
+		end, errReply = parseRangeID(rest[1], false)
+		if errReply != nil {
+			return errReply
+		}
+		n, err := strconv.ParseInt(string(rest[2]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		count = int(n)
+		if len(rest) == 4 {
+			consumerName = string(rest[3])
+		}
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil || s.GetGroup(groupName) == nil {
+		return makeNoGroupErrReply(key, groupName)
+	}
+	group := s.GetGroup(groupName)
+
+	if !extended {
+		if group.PendingCount() == 0 {
+			return reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeIntReply(0),
+				&reply.NullBulkReply{},
+				&reply.NullBulkReply{},
+				&reply.NullMultiBulkReply{},
+			})
+		}
+		all := group.PendingRange(stream.MinID, stream.MaxID, group.PendingCount(), "")
+		consumers := make([]resp.Reply, 0)
+		for _, consumer := range group.Consumers() {
+			if consumer.PendingCount() == 0 {
+				continue
+			}
+			consumers = append(consumers, reply.MakeMultiBulkReply([][]byte{
+				[]byte(consumer.Name),
+				[]byte(strconv.Itoa(consumer.PendingCount())),
+			}))
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeIntReply(int64(group.PendingCount())),
+			reply.MakeBulkReply([]byte(all[0].ID.String())),
+			reply.MakeBulkReply([]byte(all[len(all)-1].ID.String())),
+			reply.MakeMultiRawReply(consumers),
+		})
+	}
+
+	if count <= 0 {
+		return &reply.EmptyMultiBulkReply{}
+	}
+	now := nowMillis()
+	result := make([]resp.Reply, 0)
+	for _, pending := range group.PendingRange(start, end, group.PendingCount(), consumerName) {
+		if len(result) >= count {
+			break
+		}
+		idle := now - pending.DeliveryTime
+		if idle < minIdle {
+			continue
+		}
+		result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(pending.ID.String())),
+			reply.MakeBulkReply([]byte(pending.Consumer)),
+			reply.MakeIntReply(idle),
+			reply.MakeIntReply(int64(pending.DeliveryCount)),
+		}))
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// claimPending transfers the pending entry to consumer and logs it into aof
+func (db *DB) claimPending(key string, group *stream.Group, consumer *stream.Consumer, id stream.ID,
+	deliveryTime int64, deliveryCount uint64) {
+	group.SetPending(id, consumer, deliveryTime, deliveryCount)
+	db.addAof(utils.ToCmdLine("xclaim", key, group.Name, consumer.Name, "0", id.String(),
+		"TIME", strconv.FormatInt(deliveryTime, 10),
+		"RETRYCOUNT", strconv.FormatUint(deliveryCount, 10),
+		"FORCE", "JUSTID"))
+}
+
+// dropDeletedPending removes a pending entry whose stream entry no longer exists
+func (db *DB) dropDeletedPending(key string, group *stream.Group, id stream.ID) {
+	if group.Ack(id) {
+		db.addAof(utils.ToCmdLine("xack", key, group.Name, id.String()))
+	}
+}
+
+// execXClaim changes the ownership of pending entries
+func execXClaim(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	groupName := string(args[1])
+	consumerName := string(args[2])
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+
+	// parse ids until the first option
+	ids := make([]stream.ID, 0)
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := stream.ParseID(string(args[i]), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return reply.MakeErrReply(stream.ErrInvalidID.Error())
+	}
+
+	now := nowMillis()
+	deliveryTime := now
+	var retryCount int64 = -1
+	force, justID := false, false
+	var lastID *stream.ID
+	for ; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch arg {
+		case "FORCE":
+			force = true
+		case "JUSTID":
+			justID = true
+		case "IDLE", "TIME", "RETRYCOUNT":
+			if i+1 >= len(args) {
+				return &reply.SyntaxErrReply{}
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR Invalid " + arg + " option argument for XCLAIM")
+			}
+			switch arg {
+			case "IDLE":
+				deliveryTime = now - n
+			case "TIME":
+				deliveryTime = n
+			default:
+				retryCount = n
+			}
+			i++
+		case "LASTID":
+			if i+1 >= len(args) {
+				return &reply.SyntaxErrReply{}
+			}
+			id, errReply := parseStreamID(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			lastID = &id
+			i++
+		default:
+			return reply.MakeErrReply("ERR Unrecognized XCLAIM option '" + string(args[i]) + "'")
+		}
+	}
+	if deliveryTime > now {
+		deliveryTime = now
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil || s.GetGroup(groupName) == nil {
+		return makeNoGroupErrReply(key, groupName)
+	}
+	group := s.GetGroup(groupName)
+	if lastID != nil && group.LastID.Less(*lastID) {
+		group.LastID = *lastID
+		db.addAof(utils.ToCmdLine("xgroup", "setid", key, groupName, lastID.String()))
+	}
+	consumer, created := group.CreateConsumer(consumerName, now)
+	if created {
+		db.addAof(utils.ToCmdLine("xgroup", "createconsumer", key, groupName, consumerName))
+	}
+	consumer.SeenTime = now
+
+	result := make([]resp.Reply, 0, len(ids))
+	for _, id := range ids {
+		pending := group.GetPending(id)
+		entry := s.Get(id)
+		if pending == nil {
+			if !force || entry == nil {
+				continue
+			}
+		} else if entry == nil {
+			db.dropDeletedPending(key, group, id)
+			continue
+		} else if now-pending.DeliveryTime < minIdle {
+			continue
+		}
+
+		var deliveryCount uint64 = 1
+		if pending != nil {
+			deliveryCount = pending.DeliveryCount
+			if !justID {
+				deliveryCount++
+			}
+		}
+		if retryCount >= 0 {
+			deliveryCount = uint64(retryCount)
+		}
+		db.claimPending(key, group, consumer, id, deliveryTime, deliveryCount)
+		consumer.ActiveTime = now
+		if justID {
+			result = append(result, reply.MakeBulkReply([]byte(id.String())))
+		} else {
+			result = append(result, entryToReply(entry))
+		}
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execXAutoClaim transfers pending entries idle longer than min-idle-time to consumer, like XPENDING + XCLAIM
+func execXAutoClaim(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	groupName := string(args[1])
+	consumerName := string(args[2])
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+	start, errReply := parseRangeID(args[4], true)
+	if errReply != nil {
+		return errReply
+	}
+	count := 100
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return &reply.SyntaxErrReply{}
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n < 1 || n > math.MaxInt32/10 {
+				return reply.MakeErrReply("ERR COUNT must be > 0")
+			}
+			count = int(n)
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil || s.GetGroup(groupName) == nil {
+		return makeNoGroupErrReply(key, groupName)
+	}
+	group := s.GetGroup(groupName)
+	now := nowMillis()
+	consumer, created := group.CreateConsumer(consumerName, now)
+	if created {
+		db.addAof(utils.ToCmdLine("xgroup", "createconsumer", key, groupName, consumerName))
+	}
+	consumer.SeenTime = now
+
+	// scan at most count*10 pending entries, the same as redis
+	attempts := count * 10
+	scanned := group.PendingRange(start, stream.MaxID, attempts+1, "")
+	next := stream.MinID
+	if len(scanned) > attempts {
+		next = scanned[attempts].ID
+		scanned = scanned[:attempts]
+	}
+	claimed := make([]resp.Reply, 0)
+	deleted := make([][]byte, 0)
+	for _, pending := range scanned {
+		if len(claimed) >= count {
+			next = pending.ID
+			break
+		}
+		entry := s.Get(pending.ID)
+		if entry == nil {
+			deleted = append(deleted, []byte(pending.ID.String()))
+			db.dropDeletedPending(key, group, pending.ID)
+			continue
+		}
+		if now-pending.DeliveryTime < minIdle {
+			continue
+		}
+		deliveryCount := pending.DeliveryCount
+		if !justID {
+			deliveryCount++
+		}
+		db.claimPending(key, group, consumer, pending.ID, now, deliveryCount)
+		consumer.ActiveTime = now
+		if justID {
+			claimed = append(claimed, reply.MakeBulkReply([]byte(pending.ID.String())))
+		} else {
+			claimed = append(claimed, entryToReply(entry))
+		}
+	}
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(next.String())),
+		reply.MakeMultiRawReply(claimed),
+		reply.MakeMultiBulkReply(deleted),
+	})
+}
+
+var xInfoHelp = []string{
+	"XINFO <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"CONSUMERS <key> <groupname>",
+	"    Show consumers of <groupname>.",
+	"GROUPS <key>",
+	"    Show the stream consumer groups.",
+	"STREAM <key>",
+	"    Show information about the stream.",
+	"HELP",
+	"    Print this help.",
+}
+
+func idOrNullReply(entry *stream.Entry) resp.Reply {
+	if entry == nil {
+		return &reply.NullBulkReply{}
+	}
+	return entryToReply(entry)
+}
+
+// execXInfo inspects streams, consumer groups and consumers
+func execXInfo(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	if subCmd == "HELP" {
+		return makeHelpReply(xInfoHelp)
+	}
+	if subCmd != "STREAM" && subCmd != "GROUPS" && subCmd != "CONSUMERS" {
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XINFO HELP.")
+	}
+	if len(args) < 2 || (subCmd == "CONSUMERS" && len(args) != 3) || (subCmd == "GROUPS" && len(args) != 2) {
+		return reply.MakeErrReply("ERR wrong number of arguments for 'xinfo|" + strings.ToLower(subCmd) + "' command")
+	}
+	key := string(args[1])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeErrReply("ERR no such key")
+	}
+
+	switch subCmd {
+	case "STREAM":
+		if len(args) > 2 {
+			return &reply.SyntaxErrReply{}
+		}
+		recordedFirst := stream.MinID
+		if first := s.First(); first != nil {
+			recordedFirst = first.ID
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("length")), reply.MakeIntReply(int64(s.Len())),
+			reply.MakeBulkReply([]byte("last-generated-id")), reply.MakeBulkReply([]byte(s.LastID().String())),
+			reply.MakeBulkReply([]byte("max-deleted-entry-id")), reply.MakeBulkReply([]byte(s.MaxDeletedID().String())),
+			reply.MakeBulkReply([]byte("entries-added")), reply.MakeIntReply(int64(s.EntriesAdded())),
+			reply.MakeBulkReply([]byte("recorded-first-entry-id")), reply.MakeBulkReply([]byte(recordedFirst.String())),
+			reply.MakeBulkReply([]byte("groups")), reply.MakeIntReply(int64(len(s.Groups()))),
+			reply.MakeBulkReply([]byte("first-entry")), idOrNullReply(s.First()),
+			reply.MakeBulkReply([]byte("last-entry")), idOrNullReply(s.Last()),
+		})
+	case "GROUPS":
+		result := make([]resp.Reply, 0)
+		for _, group := range s.Groups() {
+			var entriesRead, lag resp.Reply = &reply.NullBulkReply{}, &reply.NullBulkReply{}
+			if read, ok := s.EntriesRead(group); ok {
+				entriesRead = reply.MakeIntReply(int64(read))
+				lag = reply.MakeIntReply(int64(s.EntriesAdded() - read))
+			}
+			result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeBulkReply([]byte("name")), reply.MakeBulkReply([]byte(group.Name)),
+				reply.MakeBulkReply([]byte("consumers")), reply.MakeIntReply(int64(len(group.Consumers()))),
+				reply.MakeBulkReply([]byte("pending")), reply.MakeIntReply(int64(group.PendingCount())),
+				reply.MakeBulkReply([]byte("last-delivered-id")), reply.MakeBulkReply([]byte(group.LastID.String())),
+				reply.MakeBulkReply([]byte("entries-read")), entriesRead,
+				reply.MakeBulkReply([]byte("lag")), lag,
+			}))
+		}
+		return reply.MakeMultiRawReply(result)
+	default: // CONSUMERS
+		groupName := string(args[2])
+		group := s.GetGroup(groupName)
+		if group == nil {
+			return makeNoGroupErrReply(key, groupName)
+		}
+		now := nowMillis()
+		result := make([]resp.Reply, 0)
+		for _, consumer := range group.Consumers() {
+			var inactive int64 = -1
+			if consumer.ActiveTime >= 0 {
+				inactive = now - consumer.ActiveTime
+			}
+			result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeBulkReply([]byte("name")), reply.MakeBulkReply([]byte(consumer.Name)),
+				reply.MakeBulkReply([]byte("pending")), reply.MakeIntReply(int64(consumer.PendingCount())),
+				reply.MakeBulkReply([]byte("idle")), reply.MakeIntReply(now - consumer.SeenTime),
+				reply.MakeBulkReply([]byte("inactive")), reply.MakeIntReply(inactive),
+			}))
+		}
+		return reply.MakeMultiRawReply(result)
+	}
+}
+
+func init() {
+	RegisterCommand("XAdd", execXAdd, -5).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
+	RegisterCommand("XLen", execXLen, 2)
+	RegisterCommand("XRange", execXRange, -4)
+	RegisterCommand("XRevRange", execXRevRange, -4)
+	RegisterCommand("XDel", execXDel, -3).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("XTrim", execXTrim, -4).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("XRead", execXRead, -4)
+	RegisterCommand("XReadGroup", execXReadGroup, -7).withFlags(flagWrite)
+	RegisterCommand("XAck", execXAck, -4).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("XGroup", execXGroup, -2).withFlags(flagWrite|flagDenyOOM).withKeys(2, 2, 1)
+	RegisterCommand("XPending", execXPending, -3)
+	RegisterCommand("XClaim", execXClaim, -6).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("XAutoClaim", execXAutoClaim, -6).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("XInfo", execXInfo, -2)
+}
@@ -5,9 +5,9 @@ import (
 	"goRedis/interface/resp"
 	"goRedis/lib/utils"
 	"goRedis/resp/reply"
+	"math"
 	"strconv"
 	"strings"
-	"time"
 )
 
 func (db *DB) getAsString(key string) ([]byte, reply.ErrorReply) {
@@ -22,6 +22,18 @@ func (db *DB) getAsString(key string) ([]byte, reply.ErrorReply) {
 	return bytes, nil
 }
 
+// putStringKeepTTL stores the modified string value, the ttl of existing key is kept
+func (db *DB) putStringKeepTTL(key string, bytes []byte) {
+	if entity, ok := db.GetEntity(key); ok {
+		entity.Data = bytes
+		db.refreshMemory(key)
+		return
+	}
+	db.PutEntity(key, &database.DataEntity{
+		Data: bytes,
+	})
+}
+
 func execGet(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
 	bytes, err := db.getAsString(key)
@@ -40,53 +52,85 @@ const (
 	updatePolicy        // set ex
 )
 
+// parseExpireAt converts the argument of EX, PX, EXAT or PXAT into unix timestamp in milliseconds
+func parseExpireAt(unit string, arg []byte, cmdName string) (int64, reply.ErrorReply) {
+	errReply := reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	val, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if val <= 0 {
+		return 0, errReply
+	}
+	if unit == "EX" || unit == "EXAT" {
+		if val > math.MaxInt64/1000 {
+			return 0, errReply
+		}
+		val *= 1000 // convert to milliseconds
+	}
+	if unit == "EX" || unit == "PX" {
+		if val > math.MaxInt64-nowMillis() {
+			return 0, errReply
+		}
+		val += nowMillis()
+	}
+	return val, nil
+}
+
+func isExpireOption(arg string) bool {
+	return arg == "EX" || arg == "PX" || arg == "EXAT" || arg == "PXAT"
+}
+
+// execSet sets string value, options are NX, XX, GET, KEEPTTL and one of EX, PX, EXAT, PXAT
 func execSet(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
 	value := args[1]
 	policy := upsertPolicy
-	var ttl int64 = 0 // default no expiration
+	var expireAt int64 = 0 // default no expiration
+	keepTTL := false
+	withGet := false
 
 	// parse options
-	if len(args) > 2 {
-		i := 2
-		for i < len(args) {
-			arg := strings.ToUpper(string(args[i]))
-			if arg == "NX" { // insert
-				if policy == updatePolicy {
-					return &reply.SyntaxErrReply{}
-				}
-				policy = insertPolicy
-				i++
-			} else if arg == "XX" { // update policy
-				if policy == insertPolicy {
-					return &reply.SyntaxErrReply{}
-				}
-				policy = updatePolicy
-				i++
-			} else if arg == "EX" { // expire in seconds
-				if i+1 >= len(args) {
-					return &reply.SyntaxErrReply{}
-				}
-				seconds, err := strconv.ParseInt(string(args[i+1]), 10, 64)
-				if err != nil || seconds <= 0 {
-					return reply.MakeErrReply("ERR invalid expire time in set")
-				}
-				ttl = seconds * 1000 // convert to milliseconds
-				i += 2
-			} else {
-				return &reply.SyntaxErrReply{}
+	for i := 2; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "NX" && policy != updatePolicy: // insert
+			policy = insertPolicy
+		case arg == "XX" && policy != insertPolicy: // update policy
+			policy = updatePolicy
+		case arg == "GET":
+			withGet = true
+		case arg == "KEEPTTL" && expireAt == 0:
+			keepTTL = true
+		case isExpireOption(arg) && expireAt == 0 && !keepTTL && i+1 < len(args):
+			var errReply reply.ErrorReply
+			expireAt, errReply = parseExpireAt(arg, args[i+1], "set")
+			if errReply != nil {
+				return errReply
 			}
+			i++
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+	}
+
+	var old []byte
+	if withGet {
+		var errReply reply.ErrorReply
+		old, errReply = db.getAsString(key)
+		if errReply != nil {
+			return errReply
 		}
 	}
 
 	// Create entity with optional expiration
 	entity := &database.DataEntity{
-		Data: value,
+		Data:       value,
+		ExpireTime: expireAt,
 	}
-
-	// Add expiration time if TTL is set
-	if ttl > 0 {
-		entity.ExpireTime = time.Now().UnixNano()/1e6 + ttl
+	existed, exists := db.GetEntity(key)
+	if keepTTL && exists {
+		entity.ExpireTime = existed.ExpireTime
 	}
 
 	var result int
@@ -99,13 +143,128 @@ func execSet(db *DB, args [][]byte) resp.Reply {
 	case updatePolicy:
 		result = db.PutIfExists(key, entity)
 	}
-	db.addAof(utils.ToCmdLine2("set", args...))
+	if result > 0 {
+		db.addAof(makeSetCmdLine(key, value, entity.ExpireTime))
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+		if expireAt > 0 {
+			db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+		}
+	}
+
+	if withGet {
+		if old == nil {
+			return &reply.NullBulkReply{}
+		}
+		return reply.MakeBulkReply(old)
+	}
 	if result > 0 {
 		return &reply.OkReply{}
 	}
 	return &reply.NullBulkReply{}
 }
 
+// makeSetCmdLine makes the set command for aof, the expiration is recorded as absolute timestamp
+func makeSetCmdLine(key string, value []byte, expireAt int64) CmdLine {
+	cmdLine := utils.ToCmdLine2("set", []byte(key), value)
+	if expireAt > 0 {
+		cmdLine = append(cmdLine, []byte("PXAT"), []byte(strconv.FormatInt(expireAt, 10)))
+	}
+	return cmdLine
+}
+
+// setWithExpire is the underlying implementation of setex and psetex
+func setWithExpire(db *DB, args [][]byte, unit string, cmdName string) resp.Reply {
+	key := string(args[0])
+	expireAt, errReply := parseExpireAt(unit, args[1], cmdName)
+	if errReply != nil {
+		return errReply
+	}
+	value := args[2]
+	db.PutEntity(key, &database.DataEntity{
+		Data:       value,
+		ExpireTime: expireAt,
+	})
+	db.addAof(makeSetCmdLine(key, value, expireAt))
+	db.notifyKeyspaceEvent(notifyString, "set", key)
+	db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+	return &reply.OkReply{}
+}
+
+// execSetEX sets string value with ttl in seconds
+func execSetEX(db *DB, args [][]byte) resp.Reply {
+	return setWithExpire(db, args, "EX", "setex")
+}
+
+// execPSetEX sets string value with ttl in milliseconds
+func execPSetEX(db *DB, args [][]byte) resp.Reply {
+	return setWithExpire(db, args, "PX", "psetex")
+}
+
+// execGetEX gets string value and optionally sets or removes its expiration
+func execGetEX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	var expireAt int64 = 0
+	persist := false
+	for i := 1; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "PERSIST" && expireAt == 0 && !persist:
+			persist = true
+		case isExpireOption(arg) && expireAt == 0 && !persist && i+1 < len(args):
+			var errReply reply.ErrorReply
+			expireAt, errReply = parseExpireAt(arg, args[i+1], "getex")
+			if errReply != nil {
+				return errReply
+			}
+			i++
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+	}
+
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		return &reply.NullBulkReply{}
+	}
+	entity, _ := db.GetEntity(key)
+	if expireAt > 0 {
+		if expireAt <= nowMillis() {
+			// expire at the past, delete it right now
+			db.Remove(key)
+			db.addAof(utils.ToCmdLine("del", key))
+			db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+			return reply.MakeBulkReply(bytes)
+		}
+		db.Expire(key, expireAt)
+		db.addAof(makeSetCmdLine(key, bytes, expireAt))
+		db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+	} else if persist && entity.ExpireTime > 0 {
+		db.Persist(key)
+		db.addAof(makeSetCmdLine(key, bytes, 0))
+		db.notifyKeyspaceEvent(notifyGeneric, "persist", key)
+	}
+	return reply.MakeBulkReply(bytes)
+}
+
+// execGetDel gets string value and deletes the key
+func execGetDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		return &reply.NullBulkReply{}
+	}
+	db.Remove(key)
+	db.addAof(utils.ToCmdLine("del", key))
+	db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	return reply.MakeBulkReply(bytes)
+}
+
 func execSetNX(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
 	value := args[1]
@@ -114,6 +273,9 @@ func execSetNX(db *DB, args [][]byte) resp.Reply {
 	}
 	result := db.PutIfAbsent(key, entity)
 	db.addAof(utils.ToCmdLine2("setnx", args...))
+	if result > 0 {
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+	}
 	return reply.MakeIntReply(int64(result))
 }
 
@@ -135,6 +297,9 @@ func execMSet(db *DB, args [][]byte) resp.Reply {
 		db.PutEntity(key, &database.DataEntity{Data: value})
 	}
 	db.addAof(utils.ToCmdLine2("mset", args...))
+	for _, key := range keys {
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+	}
 	return &reply.OkReply{}
 }
 
@@ -187,6 +352,9 @@ func execMSetNX(db *DB, args [][]byte) resp.Reply {
 		db.PutEntity(key, &database.DataEntity{Data: value})
 	}
 	db.addAof(utils.ToCmdLine2("msetnx", args...))
+	for _, key := range keys {
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+	}
 	return reply.MakeIntReply(1)
 }
 
@@ -199,10 +367,11 @@ func execGetSet(db *DB, args [][]byte) resp.Reply {
 		return err
 	}
 	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.addAof(utils.ToCmdLine2("getset", args...))
+	db.notifyKeyspaceEvent(notifyString, "set", key)
 	if old == nil {
 		return new(reply.NullBulkReply)
 	}
-	db.addAof(utils.ToCmdLine2("getset", args...))
 	return reply.MakeBulkReply(old)
 }
 
@@ -222,12 +391,14 @@ func execIncr(db *DB, args [][]byte) resp.Reply {
 			Data: []byte(strconv.FormatInt(val+1, 10)),
 		})
 		db.addAof(utils.ToCmdLine2("incr", args...))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val + 1)
 	}
 	db.PutEntity(key, &database.DataEntity{
 		Data: []byte("1"),
 	})
 	db.addAof(utils.ToCmdLine2("incr", args...))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(1)
 }
 
@@ -253,12 +424,14 @@ func execIncrBy(db *DB, args [][]byte) resp.Reply {
 			Data: []byte(strconv.FormatInt(val+delta, 10)),
 		})
 		db.addAof(utils.ToCmdLine2("incrby", args...))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val + delta)
 	}
 	db.PutEntity(key, &database.DataEntity{
 		Data: args[1],
 	})
 	db.addAof(utils.ToCmdLine2("incrby", args...))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(delta)
 }
 
@@ -278,6 +451,7 @@ func execDecr(db *DB, args [][]byte) resp.Reply {
 			Data: []byte(strconv.FormatInt(val-1, 10)),
 		})
 		db.addAof(utils.ToCmdLine2("decr", args...))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val - 1)
 	}
 	entity := &database.DataEntity{
@@ -285,6 +459,7 @@ func execDecr(db *DB, args [][]byte) resp.Reply {
 	}
 	db.PutEntity(key, entity)
 	db.addAof(utils.ToCmdLine2("decr", args...))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(-1)
 }
 
@@ -309,6 +484,7 @@ func execDecrBy(db *DB, args [][]byte) resp.Reply {
 			Data: []byte(strconv.FormatInt(val-delta, 10)),
 		})
 		db.addAof(utils.ToCmdLine2("decrby", args...))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val - delta)
 	}
 	valueStr := strconv.FormatInt(-delta, 10)
@@ -316,9 +492,40 @@ func execDecrBy(db *DB, args [][]byte) resp.Reply {
 		Data: []byte(valueStr),
 	})
 	db.addAof(utils.ToCmdLine2("decrby", args...))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(-delta)
 }
 
+func execIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	delta, err := strconv.ParseFloat(string(args[1]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return reply.MakeErrReply("ERR value is not a valid float")
+	}
+
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var val float64 = 0
+	if bytes != nil {
+		val, err = strconv.ParseFloat(string(bytes), 64)
+		if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+			return reply.MakeErrReply("ERR value is not a valid float")
+		}
+	}
+	val += delta
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result := []byte(strconv.FormatFloat(val, 'f', -1, 64))
+	db.putStringKeepTTL(key, result)
+	// log the result instead of the increment, so that replaying does not accumulate float errors
+	db.addAof(utils.ToCmdLine2("set", []byte(key), result, []byte("KEEPTTL")))
+	db.notifyKeyspaceEvent(notifyString, "incrbyfloat", key)
+	return reply.MakeBulkReply(result)
+}
+
 func execStrLen(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
 	bytes, err := db.getAsString(key)
@@ -342,6 +549,7 @@ func execAppend(db *DB, args [][]byte) resp.Reply {
 		Data: bytes,
 	})
 	db.addAof(utils.ToCmdLine2("append", args...))
+	db.notifyKeyspaceEvent(notifyString, "append", key)
 	return reply.MakeIntReply(int64(len(bytes)))
 }
 
@@ -375,6 +583,7 @@ func execSetRange(db *DB, args [][]byte) resp.Reply {
 		Data: bytes,
 	})
 	db.addAof(utils.ToCmdLine2("setRange", args...))
+	db.notifyKeyspaceEvent(notifyString, "setrange", key)
 	return reply.MakeIntReply(int64(len(bytes)))
 }
 
@@ -422,20 +631,158 @@ func execGetRange(db *DB, args [][]byte) resp.Reply {
 	return reply.MakeBulkReply(bytes[startIdx:endIdx])
 }
 
+// execLCS finds the longest common subsequence of two strings
+func execLCS(db *DB, args [][]byte) resp.Reply {
+	getLen, getIdx, withMatchLen := false, false, false
+	var minMatchLen int64 = 0
+	for i := 2; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "LEN":
+			getLen = true
+		case arg == "IDX":
+			getIdx = true
+		case arg == "WITHMATCHLEN":
+			withMatchLen = true
+		case arg == "MINMATCHLEN" && i+1 < len(args):
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if n > 0 {
+				minMatchLen = n
+			}
+			i++
+		default:
+			return &reply.SyntaxErrReply{}
+		}
+	}
+	if getLen && getIdx {
+		return reply.MakeErrReply("ERR If you want both the length and indexes, please just use IDX.")
+	}
+
+	a, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	b, errReply := db.getAsString(string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+
+	// lcs[i][j] is the length of LCS of a[:i] and b[:j]
+	width := len(b) + 1
+	lcs := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				lcs[i*width+j] = lcs[(i-1)*width+j-1] + 1
+			} else if lcs[(i-1)*width+j] > lcs[i*width+j-1] {
+				lcs[i*width+j] = lcs[(i-1)*width+j]
+			} else {
+				lcs[i*width+j] = lcs[i*width+j-1]
+			}
+		}
+	}
+	size := lcs[len(a)*width+len(b)]
+	if getLen {
+		return reply.MakeIntReply(int64(size))
+	}
+
+	// walk back from the end to collect the subsequence and matched ranges,
+	// so that ranges are emitted from the last to the first like redis does
+	result := make([]byte, size)
+	matches := make([]resp.Reply, 0)
+	idx := size
+	i, j := len(a), len(b)
+	aStart, aEnd, bStart, bEnd := len(a), 0, 0, 0 // aStart == len(a) means no range is tracked
+	for i > 0 && j > 0 {
+		emit := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			if aStart == len(a) {
+				aStart, aEnd = i-1, i-1
+				bStart, bEnd = j-1, j-1
+			} else if aStart == i && bStart == j {
+				// extend the range backward since it is contiguous
+				aStart--
+				bStart--
+			} else {
+				emit = true
+			}
+			if aStart == 0 || bStart == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if lcs[(i-1)*width+j] > lcs[i*width+j-1] {
+				i--
+			} else {
+				j--
+			}
+			if aStart != len(a) {
+				emit = true
+			}
+		}
+		matchLen := aEnd - aStart + 1
+		if emit {
+			if !getIdx {
+				aStart = len(a)
+				continue
+			}
+			if int64(matchLen) >= minMatchLen {
+				match := []resp.Reply{
+					reply.MakeMultiRawReply([]resp.Reply{
+						reply.MakeIntReply(int64(aStart)),
+						reply.MakeIntReply(int64(aEnd)),
+					}),
+					reply.MakeMultiRawReply([]resp.Reply{
+						reply.MakeIntReply(int64(bStart)),
+						reply.MakeIntReply(int64(bEnd)),
+					}),
+				}
+				if withMatchLen {
+					match = append(match, reply.MakeIntReply(int64(matchLen)))
+				}
+				matches = append(matches, reply.MakeMultiRawReply(match))
+			}
+			aStart = len(a) // restart at the next match
+		}
+	}
+
+	if getIdx {
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("matches")),
+			reply.MakeMultiRawReply(matches),
+			reply.MakeBulkReply([]byte("len")),
+			reply.MakeIntReply(int64(size)),
+		})
+	}
+	return reply.MakeBulkReply(result)
+}
+
 func init() {
-	RegisterCommand("Set", execSet, -3)
-	RegisterCommand("SetNx", execSetNX, 3)
-	RegisterCommand("MSet", execMSet, -3)
+	RegisterCommand("Set", execSet, -3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("SetEX", execSetEX, 4).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("PSetEX", execPSetEX, 4).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("SetNx", execSetNX, 3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("MSet", execMSet, -3).withFlags(flagWrite | flagDenyOOM)
 	RegisterCommand("MGet", execMGet, -2)
-	RegisterCommand("MSetNX", execMSetNX, -3)
+	RegisterCommand("MSetNX", execMSetNX, -3).withFlags(flagWrite | flagDenyOOM)
 	RegisterCommand("Get", execGet, 2)
-	RegisterCommand("GetSet", execGetSet, 3)
-	RegisterCommand("Incr", execIncr, 2)
-	RegisterCommand("IncrBy", execIncrBy, 3)
-	RegisterCommand("Decr", execDecr, 2)
-	RegisterCommand("DecrBy", execDecrBy, 3)
+	RegisterCommand("GetSet", execGetSet, 3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("GetEX", execGetEX, -2).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("GetDel", execGetDel, 2).withFlags(flagWrite)
+	RegisterCommand("Incr", execIncr, 2).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("IncrBy", execIncrBy, 3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("Decr", execDecr, 2).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("DecrBy", execDecrBy, 3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("IncrByFloat", execIncrByFloat, 3).withFlags(flagWrite | flagDenyOOM)
 	RegisterCommand("StrLen", execStrLen, 2)
-	RegisterCommand("Append", execAppend, 3)
-	RegisterCommand("SetRange", execSetRange, 4)
+	RegisterCommand("Append", execAppend, 3).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("SetRange", execSetRange, 4).withFlags(flagWrite | flagDenyOOM)
 	RegisterCommand("GetRange", execGetRange, 4)
+	RegisterCommand("LCS", execLCS, -3)
 }
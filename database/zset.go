@@ -1,11 +1,14 @@
 package database
 
 import (
+	HashSet "goRedis/datastruct/set"
 	"goRedis/datastruct/sortedset"
 	"goRedis/interface/database"
 	"goRedis/interface/resp"
 	"goRedis/lib/utils"
 	"goRedis/resp/reply"
+	"math"
+	"math/rand"
 	"strconv"
 	"strings"
 )
@@ -40,56 +43,146 @@ func (db *DB) getOrInitZSet(key string) (zset *sortedset.SortedSet, inited bool,
 	return zset, inited, nil
 }
 
+const (
+	zaddNX = 1 << iota
+	zaddXX
+	zaddGT
+	zaddLT
+	zaddCH
+	zaddIncr
+)
+
 // execZAdd adds member to sorted set
 func execZAdd(db *DB, args [][]byte) resp.Reply {
-	if len(args) < 3 || len(args)%2 == 0 {
-		return reply.MakeSyntaxErrReply()
-	}
 	key := string(args[0])
-	zset, _, errReply := db.getOrInitZSet(key)
-	if errReply != nil {
-		return errReply
-	}
 
 	// parse options
 	var options int
-	for i := 1; i < len(args); {
-		arg := strings.ToUpper(string(args[i]))
-		if arg != "NX" && arg != "XX" {
-			break
+	i := 1
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			options |= zaddNX
+			continue
+		case "XX":
+			options |= zaddXX
+			continue
+		case "GT":
+			options |= zaddGT
+			continue
+		case "LT":
+			options |= zaddLT
+			continue
+		case "CH":
+			options |= zaddCH
+			continue
+		case "INCR":
+			options |= zaddIncr
+			continue
 		}
-		if arg == "NX" {
-			options |= 1
-		} else if arg == "XX" {
-			options |= 2
-		}
-		i++
+		break
+	}
+	if options&zaddNX > 0 && options&zaddXX > 0 {
+		return reply.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if (options&zaddGT > 0 && options&zaddLT > 0) ||
+		(options&zaddNX > 0 && options&(zaddGT|zaddLT) > 0) {
+		return reply.MakeErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%2 == 1 {
+		return &reply.SyntaxErrReply{}
+	}
+	if options&zaddIncr > 0 && len(rest) > 2 {
+		return reply.MakeErrReply("ERR INCR option supports a single increment-element pair")
 	}
 
 	// parse score-member pairs
-	pairs := make([]sortedset.Element, 0)
-	for i := 1; i < len(args); i += 2 {
-		scoreBytes := args[i]
-		member := string(args[i+1])
-		score, err := strconv.ParseFloat(string(scoreBytes), 64)
-		if err != nil {
+	pairs := make([]sortedset.Element, 0, len(rest)/2)
+	for i := 0; i < len(rest); i += 2 {
+		score, err := strconv.ParseFloat(string(rest[i]), 64)
+		if err != nil || math.IsNaN(score) {
 			return reply.MakeErrReply("ERR value is not a valid float")
 		}
 		pairs = append(pairs, sortedset.Element{
-			Member: member,
+			Member: string(rest[i+1]),
 			Score:  score,
 		})
 	}
 
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		if options&zaddXX > 0 {
+			// XX never adds new members, so the key is not created
+			if options&zaddIncr > 0 {
+				return &reply.NullBulkReply{}
+			}
+			return reply.MakeIntReply(0)
+		}
+		zset, _, _ = db.getOrInitZSet(key)
+	}
+
 	// execute
-	var addedCount int64 = 0
+	var addedCount, changedCount int64
+	var incrScore float64
+	incrAborted := false
+	aofLine := utils.ToCmdLine("zadd", key)
 	for _, pair := range pairs {
-		isNew := zset.Add(pair.Member, pair.Score)
-		if isNew {
+		score := pair.Score
+		current, exists := zset.GetScore(pair.Member)
+		if options&zaddIncr > 0 {
+			if exists {
+				score += current
+			}
+			if math.IsNaN(score) {
+				return reply.MakeErrReply("ERR resulting score is not a number (NaN)")
+			}
+		}
+		if (exists && options&zaddNX > 0) || (!exists && options&zaddXX > 0) ||
+			(exists && options&zaddGT > 0 && score <= current) ||
+			(exists && options&zaddLT > 0 && score >= current) {
+			incrAborted = true
+			continue
+		}
+		incrScore = score
+		if exists && score == current {
+			continue
+		}
+		zset.Add(pair.Member, score)
+		if exists {
+			changedCount++
+		} else {
 			addedCount++
 		}
+		aofLine = append(aofLine, []byte(strconv.FormatFloat(score, 'f', -1, 64)), []byte(pair.Member))
+	}
+	if zset.Len() == 0 {
+		db.Remove(key)
+	}
+	if addedCount+changedCount > 0 {
+		db.addAof(aofLine)
+		if options&zaddIncr > 0 {
+			db.notifyKeyspaceEvent(notifyZSet, "zincr", key)
+		} else {
+			db.notifyKeyspaceEvent(notifyZSet, "zadd", key)
+		}
+	}
+	if addedCount > 0 {
+		db.signalKeyReady(key)
+	}
+
+	if options&zaddIncr > 0 {
+		if incrAborted {
+			return &reply.NullBulkReply{}
+		}
+		return reply.MakeBulkReply([]byte(strconv.FormatFloat(incrScore, 'f', -1, 64)))
+	}
+	if options&zaddCH > 0 {
+		return reply.MakeIntReply(addedCount + changedCount)
 	}
-	db.addAof(utils.ToCmdLine2("zadd", args...))
 	return reply.MakeIntReply(addedCount)
 }
 
@@ -163,47 +256,112 @@ func execZCard(db *DB, args [][]byte) resp.Reply {
 	return reply.MakeIntReply(zset.Len())
 }
 
-// execZRange gets members in range
-func execZRange(db *DB, args [][]byte) resp.Reply {
-	withScores := false
-	if len(args) >= 4 {
-		if strings.ToUpper(string(args[3])) == "WITHSCORES" {
-			withScores = true
+const (
+	zrangeByRank = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// zrangeOption is the parsed options of zrange and its compatibility aliases
+type zrangeOption struct {
+	by         int
+	reverse    bool
+	withScores bool
+	hasLimit   bool
+	offset     int64
+	limit      int64
+}
+
+// parseZRangeOption parses `[BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]`,
+// BYSCORE, BYLEX and REV are only accepted by the generic zrange and zrangestore
+func parseZRangeOption(args [][]byte, option *zrangeOption, generic bool, store bool) reply.ErrorReply {
+	option.offset, option.limit = 0, -1
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "WITHSCORES" && !store:
+			option.withScores = true
+		case arg == "LIMIT" && i+2 < len(args):
+			offset, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			limit, err := strconv.ParseInt(string(args[i+2]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			option.hasLimit = true
+			option.offset, option.limit = offset, limit
+			i += 2
+		case arg == "BYSCORE" && generic:
+			option.by = zrangeByScore
+		case arg == "BYLEX" && generic:
+			option.by = zrangeByLex
+		case arg == "REV" && generic:
+			option.reverse = true
+		default:
+			return &reply.SyntaxErrReply{}
 		}
 	}
-	return rangeByRank(db, args[0], args[1], args[2], withScores, false)
+	if option.hasLimit && option.by == zrangeByRank {
+		return reply.MakeErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if option.withScores && option.by == zrangeByLex {
+		return reply.MakeErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	return nil
 }
 
-// execZRevRange gets members in range in reverse order
-func execZRevRange(db *DB, args [][]byte) resp.Reply {
-	withScores := false
-	if len(args) >= 4 {
-		if strings.ToUpper(string(args[3])) == "WITHSCORES" {
-			withScores = true
+// zrange returns elements in range of rank, score or member, start and stop are max and min if range by score or lex in reverse order
+func (db *DB) zrange(key string, startBytes []byte, stopBytes []byte, option *zrangeOption) ([]*sortedset.Element, reply.ErrorReply) {
+	var start, stop int64
+	var minScore, maxScore *sortedset.ScoreBorder
+	var minLex, maxLex *sortedset.LexBorder
+	var err error
+	minBytes, maxBytes := startBytes, stopBytes
+	if option.reverse {
+		minBytes, maxBytes = stopBytes, startBytes
+	}
+	switch option.by {
+	case zrangeByScore:
+		if minScore, err = sortedset.ParseScoreBorder(string(minBytes)); err != nil {
+			return nil, reply.MakeErrReply(err.Error())
+		}
+		if maxScore, err = sortedset.ParseScoreBorder(string(maxBytes)); err != nil {
+			return nil, reply.MakeErrReply(err.Error())
+		}
+	case zrangeByLex:
+		var errReply reply.ErrorReply
+		if minLex, maxLex, errReply = parseLexRange(minBytes, maxBytes); errReply != nil {
+			return nil, errReply
+		}
+	default:
+		if start, err = strconv.ParseInt(string(startBytes), 10, 64); err != nil {
+			return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if stop, err = strconv.ParseInt(string(stopBytes), 10, 64); err != nil {
+			return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
 		}
 	}
-	return rangeByRank(db, args[0], args[1], args[2], withScores, true)
-}
 
-// rangeByRank is the underlying implementation of range and rev_range
-func rangeByRank(db *DB, key []byte, startBytes []byte, stopBytes []byte, withScores bool, reverse bool) resp.Reply {
-	zset, errReply := db.getAsZSet(string(key))
+	zset, errReply := db.getAsZSet(key)
 	if errReply != nil {
-		return errReply
+		return nil, errReply
 	}
-	if zset == nil {
-		return &reply.EmptyMultiBulkReply{}
+	if zset == nil || option.offset < 0 {
+		return nil, nil
 	}
-
-	start, err := strconv.ParseInt(string(startBytes), 10, 64)
-	if err != nil {
-		return reply.MakeErrReply("ERR value is not an integer or out of range")
-	}
-	stop, err := strconv.ParseInt(string(stopBytes), 10, 64)
-	if err != nil {
-		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	switch option.by {
+	case zrangeByScore:
+		return zset.GetByScoreRange(minScore, maxScore, option.offset, option.limit, option.reverse), nil
+	case zrangeByLex:
+		return zset.GetByLexRange(minLex, maxLex, option.offset, option.limit, option.reverse), nil
 	}
+	return rangeByRank(zset, start, stop, option.reverse), nil
+}
 
+// rangeByRank returns elements with rank between start and stop, negative ranks count from the end
+func rangeByRank(zset *sortedset.SortedSet, start int64, stop int64, reverse bool) []*sortedset.Element {
 	// handle out of range values
 	if start < 0 {
 		start = zset.Len() + start
@@ -218,32 +376,101 @@ func rangeByRank(db *DB, key []byte, startBytes []byte, stopBytes []byte, withSc
 		stop = zset.Len() - 1
 	}
 	if start > stop {
-		return &reply.EmptyMultiBulkReply{}
+		return nil
 	}
 
-	// get elements
-	elements := make([]*sortedset.Element, 0)
+	elements := make([]*sortedset.Element, 0, stop-start+1)
 	zset.Range(start, stop, reverse, func(element *sortedset.Element) bool {
 		elements = append(elements, element)
 		return true
 	})
+	return elements
+}
 
-	// format reply
-	size := len(elements)
+// elementsToReply formats elements as members, or member-score pairs if withScores
+func elementsToReply(elements []*sortedset.Element, withScores bool) resp.Reply {
+	if len(elements) == 0 {
+		return &reply.EmptyMultiBulkReply{}
+	}
 	if withScores {
-		result := make([][]byte, 2*size)
+		result := make([][]byte, 2*len(elements))
 		for i, element := range elements {
 			result[2*i] = []byte(element.Member)
 			result[2*i+1] = []byte(strconv.FormatFloat(element.Score, 'f', -1, 64))
 		}
 		return reply.MakeMultiBulkReply(result)
-	} else {
-		result := make([][]byte, size)
-		for i, element := range elements {
-			result[i] = []byte(element.Member)
-		}
-		return reply.MakeMultiBulkReply(result)
 	}
+	result := make([][]byte, len(elements))
+	for i, element := range elements {
+		result[i] = []byte(element.Member)
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execZRangeGeneric is the underlying implementation of zrange and its compatibility aliases
+func execZRangeGeneric(db *DB, args [][]byte, option *zrangeOption, generic bool) resp.Reply {
+	if errReply := parseZRangeOption(args[3:], option, generic, false); errReply != nil {
+		return errReply
+	}
+	elements, errReply := db.zrange(string(args[0]), args[1], args[2], option)
+	if errReply != nil {
+		return errReply
+	}
+	return elementsToReply(elements, option.withScores)
+}
+
+// execZRange gets members in range of rank, score or member
+func execZRange(db *DB, args [][]byte) resp.Reply {
+	return execZRangeGeneric(db, args, &zrangeOption{}, true)
+}
+
+// execZRangeStore stores members in range of source into destination
+func execZRangeStore(db *DB, args [][]byte) resp.Reply {
+	option := &zrangeOption{}
+	if errReply := parseZRangeOption(args[4:], option, true, true); errReply != nil {
+		return errReply
+	}
+	elements, errReply := db.zrange(string(args[1]), args[2], args[3], option)
+	if errReply != nil {
+		return errReply
+	}
+	result := sortedset.Make()
+	for _, element := range elements {
+		result.Add(element.Member, element.Score)
+	}
+	db.storeZSet(string(args[0]), result, "zrangestore")
+	db.addAof(utils.ToCmdLine2("zrangestore", args...))
+	// blocked clients may pop the result once signaled
+	size := result.Len()
+	if size > 0 {
+		db.signalKeyReady(string(args[0]))
+	}
+	return reply.MakeIntReply(size)
+}
+
+// execZRevRange gets members in range in reverse order
+func execZRevRange(db *DB, args [][]byte) resp.Reply {
+	return execZRangeGeneric(db, args, &zrangeOption{reverse: true}, false)
+}
+
+// execZRangeByScore gets members with score in range
+func execZRangeByScore(db *DB, args [][]byte) resp.Reply {
+	return execZRangeGeneric(db, args, &zrangeOption{by: zrangeByScore}, false)
+}
+
+// execZRevRangeByScore gets members with score in range in reverse order
+func execZRevRangeByScore(db *DB, args [][]byte) resp.Reply {
+	return execZRangeGeneric(db, args, &zrangeOption{by: zrangeByScore, reverse: true}, false)
+}
+
+// execZRangeByLex gets members in lexicographical range
+func execZRangeByLex(db *DB, args [][]byte) resp.Reply {
+	return execZRangeGeneric(db, args, &zrangeOption{by: zrangeByLex}, false)
+}
+
+// execZRevRangeByLex gets members in lexicographical range in reverse order
+func execZRevRangeByLex(db *DB, args [][]byte) resp.Reply {
+	return execZRangeGeneric(db, args, &zrangeOption{by: zrangeByLex, reverse: true}, false)
 }
 
 // execZRem removes members
@@ -266,6 +493,7 @@ func execZRem(db *DB, args [][]byte) resp.Reply {
 	}
 	if count > 0 {
 		db.addAof(utils.ToCmdLine2("zrem", args...))
+		db.afterZSetRemove(key, zset, "zrem")
 	}
 	return reply.MakeIntReply(int64(count))
 }
@@ -293,19 +521,21 @@ func execZIncrBy(db *DB, args [][]byte) resp.Reply {
 	zset.Add(member, score)
 
 	db.addAof(utils.ToCmdLine2("zincrby", args...))
+	db.notifyKeyspaceEvent(notifyZSet, "zincr", key)
+	db.signalKeyReady(key)
 	return reply.MakeBulkReply([]byte(strconv.FormatFloat(score, 'f', -1, 64)))
 }
 
 // execZCount counts members with score in range
 func execZCount(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
-	min, err := strconv.ParseFloat(string(args[1]), 64)
+	min, err := sortedset.ParseScoreBorder(string(args[1]))
 	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
+		return reply.MakeErrReply(err.Error())
 	}
-	max, err := strconv.ParseFloat(string(args[2]), 64)
+	max, err := sortedset.ParseScoreBorder(string(args[2]))
 	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
+		return reply.MakeErrReply(err.Error())
 	}
 
 	zset, errReply := db.getAsZSet(key)
@@ -319,126 +549,6 @@ func execZCount(db *DB, args [][]byte) resp.Reply {
 	return reply.MakeIntReply(zset.Count(min, max))
 }
 
-// execZRangeByScore gets members with score in range
-func execZRangeByScore(db *DB, args [][]byte) resp.Reply {
-	key := string(args[0])
-	min, err := strconv.ParseFloat(string(args[1]), 64)
-	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
-	}
-	max, err := strconv.ParseFloat(string(args[2]), 64)
-	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
-	}
-
-	withScores := false
-	var offset, limit int64 = 0, -1
-	if len(args) > 3 {
-		for i := 3; i < len(args); i++ {
-			arg := strings.ToUpper(string(args[i]))
-			if arg == "WITHSCORES" {
-				withScores = true
-			} else if arg == "LIMIT" && i+2 < len(args) {
-				offsetStr := string(args[i+1])
-				limitStr := string(args[i+2])
-				offset, err = strconv.ParseInt(offsetStr, 10, 64)
-				if err != nil {
-					return reply.MakeErrReply("ERR value is not an integer or out of range")
-				}
-				limit, err = strconv.ParseInt(limitStr, 10, 64)
-				if err != nil {
-					return reply.MakeErrReply("ERR value is not an integer or out of range")
-				}
-				i += 2
-			}
-		}
-	}
-
-	zset, errReply := db.getAsZSet(key)
-	if errReply != nil {
-		return errReply
-	}
-	if zset == nil {
-		return &reply.EmptyMultiBulkReply{}
-	}
-
-	elements := zset.GetByScoreRange(min, max, offset, limit, false)
-	if withScores {
-		result := make([][]byte, 2*len(elements))
-		for i, element := range elements {
-			result[2*i] = []byte(element.Member)
-			result[2*i+1] = []byte(strconv.FormatFloat(element.Score, 'f', -1, 64))
-		}
-		return reply.MakeMultiBulkReply(result)
-	} else {
-		result := make([][]byte, len(elements))
-		for i, element := range elements {
-			result[i] = []byte(element.Member)
-		}
-		return reply.MakeMultiBulkReply(result)
-	}
-}
-
-// execZRevRangeByScore gets members with score in range in reverse order
-func execZRevRangeByScore(db *DB, args [][]byte) resp.Reply {
-	key := string(args[0])
-	max, err := strconv.ParseFloat(string(args[1]), 64)
-	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
-	}
-	min, err := strconv.ParseFloat(string(args[2]), 64)
-	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
-	}
-
-	withScores := false
-	var offset, limit int64 = 0, -1
-	if len(args) > 3 {
-		for i := 3; i < len(args); i++ {
-			arg := strings.ToUpper(string(args[i]))
-			if arg == "WITHSCORES" {
-				withScores = true
-			} else if arg == "LIMIT" && i+2 < len(args) {
-				offsetStr := string(args[i+1])
-				limitStr := string(args[i+2])
-				offset, err = strconv.ParseInt(offsetStr, 10, 64)
-				if err != nil {
-					return reply.MakeErrReply("ERR value is not an integer or out of range")
-				}
-				limit, err = strconv.ParseInt(limitStr, 10, 64)
-				if err != nil {
-					return reply.MakeErrReply("ERR value is not an integer or out of range")
-				}
-				i += 2
-			}
-		}
-	}
-
-	zset, errReply := db.getAsZSet(key)
-	if errReply != nil {
-		return errReply
-	}
-	if zset == nil {
-		return &reply.EmptyMultiBulkReply{}
-	}
-
-	elements := zset.GetByScoreRange(min, max, offset, limit, true)
-	if withScores {
-		result := make([][]byte, 2*len(elements))
-		for i, element := range elements {
-			result[2*i] = []byte(element.Member)
-			result[2*i+1] = []byte(strconv.FormatFloat(element.Score, 'f', -1, 64))
-		}
-		return reply.MakeMultiBulkReply(result)
-	} else {
-		result := make([][]byte, len(elements))
-		for i, element := range elements {
-			result[i] = []byte(element.Member)
-		}
-		return reply.MakeMultiBulkReply(result)
-	}
-}
-
 // execZRemRangeByRank removes members with rank in range
 func execZRemRangeByRank(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
@@ -493,6 +603,7 @@ func execZRemRangeByRank(db *DB, args [][]byte) resp.Reply {
 
 	if count > 0 {
 		db.addAof(utils.ToCmdLine2("zremrangebyrank", args...))
+		db.afterZSetRemove(key, zset, "zremrangebyrank")
 	}
 	return reply.MakeIntReply(int64(count))
 }
@@ -500,13 +611,13 @@ func execZRemRangeByRank(db *DB, args [][]byte) resp.Reply {
 // execZRemRangeByScore removes members with score in range
 func execZRemRangeByScore(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
-	min, err := strconv.ParseFloat(string(args[1]), 64)
+	min, err := sortedset.ParseScoreBorder(string(args[1]))
 	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
+		return reply.MakeErrReply(err.Error())
 	}
-	max, err := strconv.ParseFloat(string(args[2]), 64)
+	max, err := sortedset.ParseScoreBorder(string(args[2]))
 	if err != nil {
-		return reply.MakeErrReply("ERR min or max is not a float")
+		return reply.MakeErrReply(err.Error())
 	}
 
 	zset, errReply := db.getAsZSet(key)
@@ -530,23 +641,730 @@ func execZRemRangeByScore(db *DB, args [][]byte) resp.Reply {
 
 	if count > 0 {
 		db.addAof(utils.ToCmdLine2("zremrangebyscore", args...))
+		db.afterZSetRemove(key, zset, "zremrangebyscore")
+	}
+	return reply.MakeIntReply(int64(count))
+}
+
+// parseLexRange parses min and max of lexicographical range
+func parseLexRange(minBytes, maxBytes []byte) (*sortedset.LexBorder, *sortedset.LexBorder, reply.ErrorReply) {
+	min, err := sortedset.ParseLexBorder(string(minBytes))
+	if err != nil {
+		return nil, nil, reply.MakeErrReply(err.Error())
+	}
+	max, err := sortedset.ParseLexBorder(string(maxBytes))
+	if err != nil {
+		return nil, nil, reply.MakeErrReply(err.Error())
+	}
+	return min, max, nil
+}
+
+// execZLexCount counts members in lexicographical range
+func execZLexCount(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	min, max, errReply := parseLexRange(args[1], args[2])
+	if errReply != nil {
+		return errReply
+	}
+
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(zset.RangeCount(min, max))
+}
+
+// execZRemRangeByLex removes members in lexicographical range
+func execZRemRangeByLex(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	min, max, errReply := parseLexRange(args[1], args[2])
+	if errReply != nil {
+		return errReply
+	}
+
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	// get elements to remove
+	elements := zset.GetByLexRange(min, max, 0, -1, false)
+
+	// remove elements
+	count := 0
+	for _, element := range elements {
+		if zset.Remove(element.Member) {
+			count++
+		}
+	}
+	if count > 0 {
+		db.addAof(utils.ToCmdLine2("zremrangebylex", args...))
+		db.afterZSetRemove(key, zset, "zremrangebylex")
 	}
 	return reply.MakeIntReply(int64(count))
 }
 
+const (
+	zsetUnion = iota
+	zsetIntersect
+	zsetDiff
+)
+
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+// zsetAlgebraOption is the parsed arguments of zunion, zinter and zdiff
+type zsetAlgebraOption struct {
+	keys       []string
+	weights    []float64
+	aggregate  int
+	withScores bool
+}
+
+// parseZSetAlgebra parses `numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]`
+func parseZSetAlgebra(cmdName string, args [][]byte, op int, store bool) (*zsetAlgebraOption, reply.ErrorReply) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, reply.MakeErrReply("ERR at least 1 input key is needed for '" + cmdName + "' command")
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, &reply.SyntaxErrReply{}
+	}
+	option := &zsetAlgebraOption{
+		keys:      bytesToKeys(args[1 : 1+numKeys]),
+		aggregate: aggregateSum,
+	}
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		arg := strings.ToUpper(string(rest[i]))
+		switch {
+		case arg == "WEIGHTS" && op != zsetDiff && i+len(option.keys) < len(rest):
+			option.weights = make([]float64, len(option.keys))
+			for j := range option.weights {
+				weight, err := strconv.ParseFloat(string(rest[i+1+j]), 64)
+				if err != nil {
+					return nil, reply.MakeErrReply("ERR weight value is not a float")
+				}
+				option.weights[j] = weight
+			}
+			i += len(option.keys)
+		case arg == "AGGREGATE" && op != zsetDiff && i+1 < len(rest):
+			switch strings.ToUpper(string(rest[i+1])) {
+			case "SUM":
+				option.aggregate = aggregateSum
+			case "MIN":
+				option.aggregate = aggregateMin
+			case "MAX":
+				option.aggregate = aggregateMax
+			default:
+				return nil, &reply.SyntaxErrReply{}
+			}
+			i++
+		case arg == "WITHSCORES" && !store:
+			option.withScores = true
+		default:
+			return nil, &reply.SyntaxErrReply{}
+		}
+	}
+	return option, nil
+}
+
+// getZSetAlgebraSource returns members and scores of a sorted set or a set, members of set have score 1
+func (db *DB) getZSetAlgebraSource(key string) (map[string]float64, bool, reply.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, false, nil
+	}
+	var members map[string]float64
+	switch data := entity.Data.(type) {
+	case *sortedset.SortedSet:
+		members = make(map[string]float64, data.Len())
+		data.ForEach(func(element *sortedset.Element) bool {
+			members[element.Member] = element.Score
+			return true
+		})
+	case *HashSet.Set:
+		members = make(map[string]float64, data.Len())
+		data.ForEach(func(member string) bool {
+			members[member] = 1
+			return true
+		})
+	default:
+		return nil, false, &reply.WrongTypeErrReply{}
+	}
+	return members, true, nil
+}
+
+// aggregateScore combines two scores, NaN produced by adding +inf and -inf is regarded as 0
+func aggregateScore(aggregate int, a, b float64) float64 {
+	switch aggregate {
+	case aggregateMin:
+		return math.Min(a, b)
+	case aggregateMax:
+		return math.Max(a, b)
+	}
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+// computeZSets runs sorted set algebra over the given keys, sets are treated as sorted sets with score 1
+func (db *DB) computeZSets(option *zsetAlgebraOption, op int) (*sortedset.SortedSet, reply.ErrorReply) {
+	var result map[string]float64
+	for i, key := range option.keys {
+		members, exists, errReply := db.getZSetAlgebraSource(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if !exists {
+			if op == zsetIntersect || (op == zsetDiff && i == 0) {
+				return sortedset.Make(), nil
+			}
+			continue
+		}
+		if option.weights != nil {
+			for member, score := range members {
+				score *= option.weights[i]
+				if math.IsNaN(score) {
+					score = 0 // 0 * inf
+				}
+				members[member] = score
+			}
+		}
+		if result == nil {
+			result = members
+			continue
+		}
+		switch op {
+		case zsetUnion:
+			for member, score := range members {
+				if prev, ok := result[member]; ok {
+					score = aggregateScore(option.aggregate, prev, score)
+				}
+				result[member] = score
+			}
+		case zsetIntersect:
+			for member, prev := range result {
+				score, ok := members[member]
+				if !ok {
+					delete(result, member)
+					continue
+				}
+				result[member] = aggregateScore(option.aggregate, prev, score)
+			}
+		case zsetDiff:
+			for member := range members {
+				delete(result, member)
+			}
+		}
+	}
+	zset := sortedset.Make()
+	for member, score := range result {
+		zset.Add(member, score)
+	}
+	return zset, nil
+}
+
+// storeZSet saves the result of sorted set algebra into destination, event is published if the result is not empty
+func (db *DB) storeZSet(dest string, zset *sortedset.SortedSet, event string) {
+	if zset.Len() == 0 {
+		if _, exists := db.GetEntity(dest); exists {
+			db.Remove(dest)
+			db.notifyKeyspaceEvent(notifyGeneric, "del", dest)
+		}
+		return
+	}
+	db.PutEntity(dest, &database.DataEntity{
+		Data: zset,
+	})
+	db.notifyKeyspaceEvent(notifyZSet, event, dest)
+}
+
+// afterZSetRemove deletes the key if no member is left, and publishes events of the removal
+func (db *DB) afterZSetRemove(key string, zset *sortedset.SortedSet, event string) {
+	db.notifyKeyspaceEvent(notifyZSet, event, key)
+	if zset.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+}
+
+// zsetToReply returns members of sorted set in order
+func zsetToReply(zset *sortedset.SortedSet, withScores bool) resp.Reply {
+	result := make([][]byte, 0, zset.Len())
+	zset.ForEach(func(element *sortedset.Element) bool {
+		result = append(result, []byte(element.Member))
+		if withScores {
+			result = append(result, []byte(strconv.FormatFloat(element.Score, 'f', -1, 64)))
+		}
+		return true
+	})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execZSetAlgebra is the underlying implementation of zunion, zinter and zdiff
+func execZSetAlgebra(db *DB, cmdName string, args [][]byte, op int) resp.Reply {
+	option, errReply := parseZSetAlgebra(cmdName, args, op, false)
+	if errReply != nil {
+		return errReply
+	}
+	result, errReply := db.computeZSets(option, op)
+	if errReply != nil {
+		return errReply
+	}
+	return zsetToReply(result, option.withScores)
+}
+
+// execZSetAlgebraStore is the underlying implementation of zunionstore, zinterstore and zdiffstore
+func execZSetAlgebraStore(db *DB, cmdName string, args [][]byte, op int) resp.Reply {
+	option, errReply := parseZSetAlgebra(cmdName, args[1:], op, true)
+	if errReply != nil {
+		return errReply
+	}
+	result, errReply := db.computeZSets(option, op)
+	if errReply != nil {
+		return errReply
+	}
+	db.storeZSet(string(args[0]), result, cmdName)
+	db.addAof(utils.ToCmdLine2(cmdName, args...))
+	// blocked clients may pop the result once signaled
+	size := result.Len()
+	if size > 0 {
+		db.signalKeyReady(string(args[0]))
+	}
+	return reply.MakeIntReply(size)
+}
+
+// execZUnion adds multiple sorted sets
+func execZUnion(db *DB, args [][]byte) resp.Reply {
+	return execZSetAlgebra(db, "zunion", args, zsetUnion)
+}
+
+// execZUnionStore adds multiple sorted sets and stores the result in destination
+func execZUnionStore(db *DB, args [][]byte) resp.Reply {
+	return execZSetAlgebraStore(db, "zunionstore", args, zsetUnion)
+}
+
+// execZInter intersects multiple sorted sets
+func execZInter(db *DB, args [][]byte) resp.Reply {
+	return execZSetAlgebra(db, "zinter", args, zsetIntersect)
+}
+
+// execZInterStore intersects multiple sorted sets and stores the result in destination
+func execZInterStore(db *DB, args [][]byte) resp.Reply {
+	return execZSetAlgebraStore(db, "zinterstore", args, zsetIntersect)
+}
+
+// execZDiff subtracts multiple sorted sets from the first one
+func execZDiff(db *DB, args [][]byte) resp.Reply {
+	return execZSetAlgebra(db, "zdiff", args, zsetDiff)
+}
+
+// execZDiffStore subtracts multiple sorted sets from the first one and stores the result in destination
+func execZDiffStore(db *DB, args [][]byte) resp.Reply {
+	return execZSetAlgebraStore(db, "zdiffstore", args, zsetDiff)
+}
+
+// execZInterCard returns the cardinality of the intersection
+func execZInterCard(db *DB, args [][]byte) resp.Reply {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil || numKeys <= 0 {
+		return reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return reply.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	option := &zsetAlgebraOption{
+		keys:      bytesToKeys(args[1 : 1+numKeys]),
+		aggregate: aggregateSum,
+	}
+
+	var limit int64 = 0 // 0 means unlimited
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		if strings.ToUpper(string(rest[i])) != "LIMIT" || i+1 >= len(rest) {
+			return &reply.SyntaxErrReply{}
+		}
+		limit, err = strconv.ParseInt(string(rest[i+1]), 10, 64)
+		if err != nil || limit < 0 {
+			return reply.MakeErrReply("ERR LIMIT can't be negative")
+		}
+		i++
+	}
+
+	result, errReply := db.computeZSets(option, zsetIntersect)
+	if errReply != nil {
+		return errReply
+	}
+	card := result.Len()
+	if limit > 0 && card > limit {
+		card = limit
+	}
+	return reply.MakeIntReply(card)
+}
+
+// popZSetElements pops at most count elements with the lowest or highest scores, returns nil if the zset does not exist
+func (db *DB) popZSetElements(key string, max bool, count int64) ([]*sortedset.Element, reply.ErrorReply) {
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if zset == nil {
+		return nil, nil
+	}
+	if count > zset.Len() {
+		count = zset.Len()
+	}
+	popped := make([]*sortedset.Element, 0, count)
+	for i := int64(0); i < count; i++ {
+		element, _ := zset.GetByRank(0, max)
+		zset.Remove(element.Member)
+		popped = append(popped, element)
+	}
+
+	if count > 0 {
+		// log popped members as zrem, so that replaying does not depend on the order of equal scores
+		cmdLine := utils.ToCmdLine("zrem", key)
+		for _, element := range popped {
+			cmdLine = append(cmdLine, []byte(element.Member))
+		}
+		db.addAof(cmdLine)
+		if max {
+			db.afterZSetRemove(key, zset, "zpopmax")
+		} else {
+			db.afterZSetRemove(key, zset, "zpopmin")
+		}
+	}
+	return popped, nil
+}
+
+// checkZSetKeys returns error if any of the keys holds a non-zset value
+func (db *DB) checkZSetKeys(keys []string) reply.ErrorReply {
+	for _, key := range keys {
+		if _, errReply := db.getAsZSet(key); errReply != nil {
+			return errReply
+		}
+	}
+	return nil
+}
+
+// execZPopGeneric is the underlying implementation of zpopmin and zpopmax
+func execZPopGeneric(db *DB, args [][]byte, max bool) resp.Reply {
+	if len(args) > 2 {
+		return &reply.SyntaxErrReply{}
+	}
+	var count int64 = 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || count < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+	}
+	popped, errReply := db.popZSetElements(string(args[0]), max, count)
+	if errReply != nil {
+		return errReply
+	}
+	return elementsToReply(popped, true)
+}
+
+// execZPopMin removes and returns members with the lowest scores
+func execZPopMin(db *DB, args [][]byte) resp.Reply {
+	return execZPopGeneric(db, args, false)
+}
+
+// execZPopMax removes and returns members with the highest scores
+func execZPopMax(db *DB, args [][]byte) resp.Reply {
+	return execZPopGeneric(db, args, true)
+}
+
+// blockingPopZSet is the underlying implementation of bzpopmin and bzpopmax
+func blockingPopZSet(db *DB, args [][]byte, max bool) resp.Reply {
+	keys := bytesToKeys(args[:len(args)-1])
+	timeout, errReply := parseBlockingTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	if errReply := db.checkZSetKeys(keys); errReply != nil {
+		return errReply
+	}
+	return &blockingReply{
+		keys:    keys,
+		timeout: timeout,
+		serve: func(key string) resp.Reply {
+			popped, errReply := db.popZSetElements(key, max, 1)
+			if errReply != nil {
+				return errReply
+			}
+			if len(popped) == 0 {
+				return nil
+			}
+			db.refreshMemory(key)
+			return reply.MakeMultiBulkReply([][]byte{
+				[]byte(key),
+				[]byte(popped[0].Member),
+				[]byte(strconv.FormatFloat(popped[0].Score, 'f', -1, 64)),
+			})
+		},
+		timeoutReply: &reply.NullMultiBulkReply{},
+	}
+}
+
+// execBZPopMin is the blocking variant of zpopmin
+func execBZPopMin(db *DB, args [][]byte) resp.Reply {
+	return blockingPopZSet(db, args, false)
+}
+
+// execBZPopMax is the blocking variant of zpopmax
+func execBZPopMax(db *DB, args [][]byte) resp.Reply {
+	return blockingPopZSet(db, args, true)
+}
+
+// parseZMPopArgs parses `numkeys key [key ...] MIN|MAX [COUNT count]`
+func parseZMPopArgs(args [][]byte) (keys []string, max bool, count int64, errReply reply.ErrorReply) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-2) {
+		return nil, false, 0, &reply.SyntaxErrReply{}
+	}
+	keys = bytesToKeys(args[1 : 1+numKeys])
+	rest := args[1+numKeys:]
+	switch strings.ToUpper(string(rest[0])) {
+	case "MIN":
+		max = false
+	case "MAX":
+		max = true
+	default:
+		return nil, false, 0, &reply.SyntaxErrReply{}
+	}
+	count = 1
+	if len(rest) > 1 {
+		if len(rest) != 3 || strings.ToUpper(string(rest[1])) != "COUNT" {
+			return nil, false, 0, &reply.SyntaxErrReply{}
+		}
+		count, err = strconv.ParseInt(string(rest[2]), 10, 64)
+		if err != nil || count <= 0 {
+			return nil, false, 0, reply.MakeErrReply("ERR count should be greater than 0")
+		}
+	}
+	return keys, max, count, nil
+}
+
+// makeZMPopServer pops elements from the given zset, replies key name and popped member-score pairs
+func (db *DB) makeZMPopServer(max bool, count int64) serveFunc {
+	return func(key string) resp.Reply {
+		popped, errReply := db.popZSetElements(key, max, count)
+		if errReply != nil {
+			return errReply
+		}
+		if len(popped) == 0 {
+			return nil
+		}
+		db.refreshMemory(key)
+		pairs := make([]resp.Reply, len(popped))
+		for i, element := range popped {
+			pairs[i] = reply.MakeMultiBulkReply([][]byte{
+				[]byte(element.Member),
+				[]byte(strconv.FormatFloat(element.Score, 'f', -1, 64)),
+			})
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(key)),
+			reply.MakeMultiRawReply(pairs),
+		})
+	}
+}
+
+// execZMPop pops elements from the first non-empty zset
+func execZMPop(db *DB, args [][]byte) resp.Reply {
+	keys, max, count, errReply := parseZMPopArgs(args)
+	if errReply != nil {
+		return errReply
+	}
+	serve := db.makeZMPopServer(max, count)
+	for _, key := range keys {
+		if result := serve(key); result != nil {
+			return result
+		}
+	}
+	return &reply.NullMultiBulkReply{}
+}
+
+// execBZMPop is the blocking variant of zmpop
+func execBZMPop(db *DB, args [][]byte) resp.Reply {
+	timeout, errReply := parseBlockingTimeout(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	keys, max, count, errReply := parseZMPopArgs(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	if errReply := db.checkZSetKeys(keys); errReply != nil {
+		return errReply
+	}
+	return &blockingReply{
+		keys:         keys,
+		timeout:      timeout,
+		serve:        db.makeZMPopServer(max, count),
+		timeoutReply: &reply.NullMultiBulkReply{},
+	}
+}
+
+// execZRandMember gets random members from sorted set
+func execZRandMember(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 3 {
+		return &reply.SyntaxErrReply{}
+	}
+	key := string(args[0])
+	withCount := len(args) >= 2
+	var count int64 = 1
+	if withCount {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	withScores := false
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHSCORES" {
+			return &reply.SyntaxErrReply{}
+		}
+		withScores = true
+	}
+
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		if withCount {
+			return &reply.EmptyMultiBulkReply{}
+		}
+		return &reply.NullBulkReply{}
+	}
+
+	size := zset.Len()
+	if !withCount {
+		element, _ := zset.GetByRank(rand.Int63n(size), false)
+		return reply.MakeBulkReply([]byte(element.Member))
+	}
+	var elements []*sortedset.Element
+	if count >= 0 {
+		// positive count returns distinct members
+		if count > size {
+			count = size
+		}
+		elements = make([]*sortedset.Element, 0, count)
+		for _, rank := range randomRanks(size, count) {
+			element, _ := zset.GetByRank(rank, false)
+			elements = append(elements, element)
+		}
+	} else {
+		// negative count allows the same member multiple times
+		elements = make([]*sortedset.Element, 0, -count)
+		for i := int64(0); i < -count; i++ {
+			element, _ := zset.GetByRank(rand.Int63n(size), false)
+			elements = append(elements, element)
+		}
+	}
+	return elementsToReply(elements, withScores)
+}
+
+// randomRanks picks count distinct ranks in [0, size) with a partial Fisher-Yates shuffle,
+// only the swapped positions are stored so it costs O(count) instead of O(size)
+func randomRanks(size int64, count int64) []int64 {
+	swapped := make(map[int64]int64, count)
+	ranks := make([]int64, count)
+	for i := int64(0); i < count; i++ {
+		j := i + rand.Int63n(size-i)
+		rankJ, ok := swapped[j]
+		if !ok {
+			rankJ = j
+		}
+		rankI, ok := swapped[i]
+		if !ok {
+			rankI = i
+		}
+		ranks[i] = rankJ
+		swapped[j] = rankI
+	}
+	return ranks
+}
+
+// execZMScore gets scores of members in sorted set
+func execZMScore(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	zset, errReply := db.getAsZSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(args)-1)
+	for i, arg := range args[1:] {
+		if zset == nil {
+			result[i] = &reply.NullBulkReply{}
+			continue
+		}
+		score, exists := zset.GetScore(string(arg))
+		if !exists {
+			result[i] = &reply.NullBulkReply{}
+			continue
+		}
+		result[i] = reply.MakeBulkReply([]byte(strconv.FormatFloat(score, 'f', -1, 64)))
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
 func init() {
-	RegisterCommand("ZAdd", execZAdd, -4)
+	RegisterCommand("ZAdd", execZAdd, -4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
 	RegisterCommand("ZScore", execZScore, 3)
 	RegisterCommand("ZRank", execZRank, 3)
 	RegisterCommand("ZRevRank", execZRevRank, 3)
 	RegisterCommand("ZCard", execZCard, 2)
 	RegisterCommand("ZRange", execZRange, -4)
+	RegisterCommand("ZRangeStore", execZRangeStore, -5).withFlags(flagWrite | flagDenyOOM)
 	RegisterCommand("ZRevRange", execZRevRange, -4)
-	RegisterCommand("ZRem", execZRem, -3)
-	RegisterCommand("ZIncrBy", execZIncrBy, 4)
+	RegisterCommand("ZRem", execZRem, -3).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("ZIncrBy", execZIncrBy, 4).withFlags(flagWrite|flagDenyOOM).withKeys(1, 1, 1)
 	RegisterCommand("ZCount", execZCount, 4)
 	RegisterCommand("ZRangeByScore", execZRangeByScore, -4)
 	RegisterCommand("ZRevRangeByScore", execZRevRangeByScore, -4)
-	RegisterCommand("ZRemRangeByRank", execZRemRangeByRank, 4)
-	RegisterCommand("ZRemRangeByScore", execZRemRangeByScore, 4)
+	RegisterCommand("ZRemRangeByRank", execZRemRangeByRank, 4).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("ZRemRangeByScore", execZRemRangeByScore, 4).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("ZRangeByLex", execZRangeByLex, -4)
+	RegisterCommand("ZRevRangeByLex", execZRevRangeByLex, -4)
+	RegisterCommand("ZLexCount", execZLexCount, 4)
+	RegisterCommand("ZRemRangeByLex", execZRemRangeByLex, 4).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("ZUnion", execZUnion, -3)
+	RegisterCommand("ZUnionStore", execZUnionStore, -4).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("ZInter", execZInter, -3)
+	RegisterCommand("ZInterStore", execZInterStore, -4).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("ZDiff", execZDiff, -3)
+	RegisterCommand("ZDiffStore", execZDiffStore, -4).withFlags(flagWrite | flagDenyOOM)
+	RegisterCommand("ZInterCard", execZInterCard, -3)
+	RegisterCommand("ZPopMin", execZPopMin, -2).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("ZPopMax", execZPopMax, -2).withFlags(flagWrite).withKeys(1, 1, 1)
+	RegisterCommand("BZPopMin", execBZPopMin, -3).withFlags(flagWrite)
+	RegisterCommand("BZPopMax", execBZPopMax, -3).withFlags(flagWrite)
+	RegisterCommand("ZMPop", execZMPop, -4).withFlags(flagWrite)
+	RegisterCommand("BZMPop", execBZMPop, -5).withFlags(flagWrite)
+	RegisterCommand("ZRandMember", execZRandMember, -2)
+	RegisterCommand("ZMScore", execZMScore, -3)
 }
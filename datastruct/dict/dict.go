@@ -10,9 +10,13 @@ type Dict interface {
 	PutIfAbsent(key string, val interface{}) (result int)
 	PutIfExists(key string, val interface{}) (result int)
 	Remove(key string) (result int)
+	// RemoveIfEqual 只有key当前的值仍然是val时才删除，val必须是可比较的类型
+	RemoveIfEqual(key string, val interface{}) (result int)
 	ForEach(consumer Consumer)
 	Keys() []string
 	RandomKeys(limit int) []string
 	RandomDistinctKeys(limit int) []string
+	// Scan 按hash顺序从cursor开始遍历至少count个key，返回下一次遍历的cursor，0表示遍历结束
+	Scan(cursor uint64, count int) (keys []string, next uint64)
 	Clear()
 }
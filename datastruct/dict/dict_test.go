@@ -0,0 +1,206 @@
+package dict
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSyncDict(t *testing.T) {
+	d := MakeSyncDict()
+	size := 10000
+	for i := 0; i < size; i++ {
+		if d.Put(strconv.Itoa(i), i) != 1 {
+			t.Fatalf("putting a new key %d should return 1", i)
+		}
+	}
+	if d.Put("0", -1) != 0 {
+		t.Error("putting an existing key should return 0")
+	}
+	if d.PutIfAbsent("0", 0) != 0 || d.PutIfExists("absent", 0) != 0 {
+		t.Error("conditional put should not change the dict")
+	}
+	if d.Len() != size {
+		t.Errorf("expect len %d, actual %d", size, d.Len())
+	}
+	if val, ok := d.Get("0"); !ok || val != -1 {
+		t.Errorf("expect -1, actual %v", val)
+	}
+	if len(d.Keys()) != size {
+		t.Errorf("expect %d keys, actual %d", size, len(d.Keys()))
+	}
+	if keys := d.RandomDistinctKeys(100); len(keys) != 100 {
+		t.Errorf("expect 100 random keys, actual %d", len(keys))
+	}
+	for i := 0; i < size; i += 2 {
+		if d.Remove(strconv.Itoa(i)) != 1 {
+			t.Fatalf("removing key %d should return 1", i)
+		}
+	}
+	if d.Remove("0") != 0 {
+		t.Error("removing an absent key should return 0")
+	}
+	if d.Len() != size/2 {
+		t.Errorf("expect len %d, actual %d", size/2, d.Len())
+	}
+	d.Clear()
+	if d.Len() != 0 || len(d.Keys()) != 0 {
+		t.Errorf("expect empty dict after clear, actual %d", d.Len())
+	}
+}
+
+func TestSyncDictConcurrent(t *testing.T) {
+	d := MakeSyncDict()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(g*1000 + i)
+				d.Put(key, i)
+				d.Get(key)
+				if i%2 == 0 {
+					d.Remove(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if d.Len() != 4000 {
+		t.Errorf("expect len 4000, actual %d", d.Len())
+	}
+}
+
+// testScanWithMutation adds and removes keys between scan calls,
+// keys present during the whole iteration must be returned at least once
+func testScanWithMutation(t *testing.T, d Dict) {
+	size := 5000
+	for i := 0; i < size; i++ {
+		d.Put("stable"+strconv.Itoa(i), nil)
+		d.Put("removed"+strconv.Itoa(i), nil)
+	}
+	seen := make(map[string]struct{})
+	var cursor uint64
+	round := 0
+	for {
+		keys, next := d.Scan(cursor, 10)
+		for _, key := range keys {
+			seen[key] = struct{}{}
+		}
+		// grow and shrink the dict so that buckets are resized during the iteration
+		for i := 0; i < 20; i++ {
+			d.Put("added"+strconv.Itoa(round*20+i), nil)
+		}
+		if round < size/10 {
+			for i := round * 10; i < round*10+10; i++ {
+				d.Remove("removed" + strconv.Itoa(i))
+			}
+		}
+		round++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	for i := 0; i < size; i++ {
+		key := "stable" + strconv.Itoa(i)
+		if _, ok := seen[key]; !ok {
+			t.Fatalf("%s is not returned", key)
+		}
+	}
+}
+
+func TestSimpleDictScan(t *testing.T) {
+	testScanWithMutation(t, MakeSimple())
+}
+
+func TestSyncDictScan(t *testing.T) {
+	testScanWithMutation(t, MakeSyncDict())
+}
+
+func TestScanCount(t *testing.T) {
+	d := MakeSimple()
+	for i := 0; i < 1000; i++ {
+		d.Put(strconv.Itoa(i), nil)
+	}
+	total := 0
+	var cursor uint64
+	for {
+		keys, next := d.Scan(cursor, 100)
+		total += len(keys)
+		if next == 0 {
+			break
+		}
+		if len(keys) < 100 {
+			t.Errorf("expect at least 100 keys before the end, actual %d", len(keys))
+		}
+		cursor = next
+	}
+	// no key is changed, so that each key is returned exactly once
+	if total != 1000 {
+		t.Errorf("expect 1000 keys, actual %d", total)
+	}
+}
+
+// testRandomSpread checks that random sampling reaches every key with a roughly even frequency
+func testRandomSpread(t *testing.T, d Dict) {
+	size := 1000
+	for i := 0; i < size; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	checkSpread := func(name string, counts map[string]int, expected int, min int, max int) {
+		if len(counts) != size {
+			t.Errorf("%s: expect all %d keys to be sampled, actual %d", name, size, len(counts))
+		}
+		for key, n := range counts {
+			if n < min || n > max {
+				t.Errorf("%s: key %s sampled %d times, expect about %d", name, key, n, expected)
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, key := range d.RandomKeys(100 * size) {
+		counts[key]++
+	}
+	// buckets and shards are chosen before keys, so keys in small buckets are more likely
+	checkSpread("RandomKeys", counts, 100, 10, 1000)
+
+	for _, limit := range []int{10, 500} {
+		counts = make(map[string]int)
+		rounds := 200 * size / limit
+		for i := 0; i < rounds; i++ {
+			keys := d.RandomDistinctKeys(limit)
+			if len(keys) != limit {
+				t.Fatalf("expect %d keys, actual %d", limit, len(keys))
+			}
+			picked := make(map[string]struct{}, limit)
+			for _, key := range keys {
+				if _, ok := picked[key]; ok {
+					t.Fatalf("key %s is returned twice", key)
+				}
+				picked[key] = struct{}{}
+				counts[key]++
+			}
+		}
+		if limit*3 <= size {
+			checkSpread("RandomDistinctKeys", counts, 200, 20, 2000)
+		} else {
+			// reservoir sampling is uniform
+			checkSpread("RandomDistinctKeys", counts, 200, 120, 280)
+		}
+	}
+
+	if keys := d.RandomDistinctKeys(2 * size); len(keys) != size {
+		t.Errorf("expect all %d keys, actual %d", size, len(keys))
+	}
+}
+
+func TestSimpleDictRandom(t *testing.T) {
+	testRandomSpread(t, MakeSimple())
+}
+
+func TestSyncDictRandom(t *testing.T) {
+	testRandomSpread(t, MakeSyncDict())
+}
@@ -0,0 +1,61 @@
+package dict
+
+import "sort"
+
+// 遍历时key按照hash排序，cursor即下一个待返回key的hash的下界。
+// key的hash不会改变，所以整个遍历过程中一直存在的key至少会被返回一次，
+// 遍历过程中新增或删除的key可能返回也可能不返回。
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// Hash 返回key的fnv-1a hash，决定key在遍历中的位置。
+// 分片和桶按hash的高位划分，fnv-1a的高位几乎不受短key最后几个字节的影响，
+// 所以再用murmur3的fmix64打散，fmix64是双射，不会增加冲突
+func Hash(key string) uint64 {
+	var hash uint64 = fnvOffset64
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= fnvPrime64
+	}
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// ScanKeys 按hash顺序返回keys中hash不小于cursor的至少count个key，hash相同的key会一起返回，
+// next为下一次遍历的cursor，0表示keys已经遍历完
+func ScanKeys(keys []string, cursor uint64, count int) (result []string, next uint64) {
+	if count < 1 {
+		count = 1
+	}
+	type hashedKey struct {
+		key  string
+		hash uint64
+	}
+	candidates := make([]hashedKey, 0, len(keys))
+	for _, key := range keys {
+		if hash := Hash(key); hash >= cursor {
+			candidates = append(candidates, hashedKey{key: key, hash: hash})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].hash < candidates[j].hash
+	})
+
+	i := 0
+	for i < len(candidates) && (i < count || candidates[i].hash == candidates[i-1].hash) {
+		result = append(result, candidates[i].key)
+		i++
+	}
+	if i == len(candidates) {
+		return result, 0
+	}
+	// the hash of last returned key is less than the next one, so that it cannot overflow
+	return result, candidates[i-1].hash + 1
+}
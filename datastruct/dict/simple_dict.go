@@ -2,52 +2,43 @@ package dict
 
 // SimpleDict 注意：并发不安全
 type SimpleDict struct {
-	m map[string]interface{}
+	t table
 }
 
 // MakeSimple 创建新map
 func MakeSimple() *SimpleDict {
 	return &SimpleDict{
-		m: make(map[string]interface{}),
+		t: makeTable(0, 0),
 	}
 }
 
 // Get 返回绑定的value不管key是否存在
 func (dict *SimpleDict) Get(key string) (val interface{}, exists bool) {
-	val, ok := dict.m[key]
-	return val, ok
+	return dict.t.get(key)
 }
 
 // Len dict的长度
 func (dict *SimpleDict) Len() int {
-	if dict.m == nil {
-		panic("m is nil")
-	}
-	return len(dict.m)
+	return dict.t.size
 }
 
 func (dict *SimpleDict) Put(key string, val interface{}) (result int) {
-	_, existed := dict.m[key]
-	dict.m[key] = val
-	if existed {
-		return 0
+	if dict.t.put(key, val) {
+		return 1
 	}
-	return 1
+	return 0
 }
 
 func (dict *SimpleDict) PutIfAbsent(key string, val interface{}) (result int) {
-	_, existed := dict.m[key]
-	if existed {
+	if _, existed := dict.t.get(key); existed {
 		return 0
 	}
-	dict.m[key] = val
+	dict.t.put(key, val)
 	return 1
 }
 
 func (dict *SimpleDict) PutIfExists(key string, val interface{}) (result int) {
-	_, existed := dict.m[key]
-	if existed {
-		dict.m[key] = val
+	if dict.t.putIfExists(key, val) {
 		return 1
 	}
 	return 0
@@ -55,9 +46,14 @@ func (dict *SimpleDict) PutIfExists(key string, val interface{}) (result int) {
 
 // Remove 删除key，返回key-value的数量
 func (dict *SimpleDict) Remove(key string) (result int) {
-	_, existed := dict.m[key]
-	delete(dict.m, key)
-	if existed {
+	if dict.t.remove(key) {
+		return 1
+	}
+	return 0
+}
+
+func (dict *SimpleDict) RemoveIfEqual(key string, val interface{}) (result int) {
+	if dict.t.removeIfEqual(key, val) {
 		return 1
 	}
 	return 0
@@ -65,53 +61,37 @@ func (dict *SimpleDict) Remove(key string) (result int) {
 
 // Keys 返回dict里的所有keys
 func (dict *SimpleDict) Keys() []string {
-	result := make([]string, len(dict.m))
-	i := 0
-	for k := range dict.m {
-		result[i] = k
-	}
-	return result
+	return dict.t.keys()
 }
 
 // ForEach 遍历dict
 func (dict *SimpleDict) ForEach(consumer Consumer) {
-	for k, v := range dict.m {
-		if !consumer(k, v) {
-			break
-		}
-	}
+	dict.t.forEach(consumer)
 }
 
 // RandomKeys 随机返回给定的数量的key
 func (dict *SimpleDict) RandomKeys(limit int) []string {
-	result := make([]string, limit)
+	result := make([]string, 0, limit)
 	for i := 0; i < limit; i++ {
-		for k := range dict.m {
-			result[i] = k
+		key, ok := dict.t.randomKey()
+		if !ok {
 			break
 		}
+		result = append(result, key)
 	}
 	return result
 }
 
 func (dict *SimpleDict) RandomDistinctKeys(limit int) []string {
-	size := limit
-	if size > len(dict.m) {
-		size = len(dict.m)
-	}
-	result := make([]string, size)
-	i := 0
-	for k := range dict.m {
-		if i == limit {
-			break
-		}
-		result[i] = k
-		i++
-	}
-	return result
+	return randomDistinctKeys(dict.t.size, limit, dict.t.randomKey, dict.t.forEach)
+}
+
+// Scan 按hash顺序从cursor开始遍历至少count个key，只需要检查cursor之后的桶
+func (dict *SimpleDict) Scan(cursor uint64, count int) ([]string, uint64) {
+	return dict.t.scan(cursor, count)
 }
 
 // Clear removes all keys in dict
 func (dict *SimpleDict) Clear() {
-	*dict = *MakeSimple()
+	dict.t.clear()
 }
@@ -1,109 +1,247 @@
 package dict
 
-import "sync"
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
 
+const (
+	// shardBits 分片数量的位数，key按hash的高位分片，分片顺序即遍历顺序
+	shardBits  = 10
+	shardCount = 1 << shardBits
+)
+
+// SyncDict 分片加锁的并发安全dict
 type SyncDict struct {
-	m sync.Map
+	shards []*shard
+	count  int64
+}
+
+type shard struct {
+	t  table
+	mu sync.RWMutex
 }
 
 func MakeSyncDict() *SyncDict {
-	return &SyncDict{}
+	dict := &SyncDict{
+		shards: make([]*shard, shardCount),
+	}
+	for i := range dict.shards {
+		dict.shards[i] = &shard{
+			t: makeTable(shardStart(i), shardBits),
+		}
+	}
+	return dict
+}
+
+// shardIndex 返回hash所在分片的下标
+func shardIndex(hash uint64) int {
+	return int(hash >> (64 - shardBits))
+}
+
+// shardStart 返回分片的最小hash
+func shardStart(i int) uint64 {
+	return uint64(i) << (64 - shardBits)
+}
+
+func (dict *SyncDict) getShard(key string) *shard {
+	return dict.shards[shardIndex(Hash(key))]
 }
 
 func (dict *SyncDict) Get(key string) (val interface{}, exists bool) {
-	val, ok := dict.m.Load(key)
-	return val, ok
+	s := dict.getShard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.t.get(key)
 }
 
 func (dict *SyncDict) Len() int {
-	lenth := 0
-	dict.m.Range(func(k, v interface{}) bool {
-		lenth++
-		return true
-	})
-	return lenth
+	return int(atomic.LoadInt64(&dict.count))
 }
 
 func (dict *SyncDict) Put(key string, val interface{}) (result int) {
-	_, existed := dict.m.Load(key)
-	dict.m.Store(key, val)
-	if existed {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.t.put(key, val) {
 		return 0
 	}
+	atomic.AddInt64(&dict.count, 1)
 	return 1
 }
 
 func (dict *SyncDict) PutIfAbsent(key string, val interface{}) (result int) {
-	_, existed := dict.m.Load(key)
-	if existed {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, existed := s.t.get(key); existed {
 		return 0
 	}
-	dict.m.Store(key, val)
+	s.t.put(key, val)
+	atomic.AddInt64(&dict.count, 1)
 	return 1
 }
 
 func (dict *SyncDict) PutIfExists(key string, val interface{}) (result int) {
-	_, existed := dict.m.Load(key)
-	if existed {
-		dict.m.Store(key, val)
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t.putIfExists(key, val) {
 		return 1
 	}
 	return 0
 }
 
 func (dict *SyncDict) Remove(key string) (result int) {
-	_, existed := dict.m.Load(key)
-	dict.m.Delete(key)
-	if existed {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t.remove(key) {
+		atomic.AddInt64(&dict.count, -1)
 		return 1
 	}
 	return 0
 }
 
+func (dict *SyncDict) RemoveIfEqual(key string, val interface{}) (result int) {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t.removeIfEqual(key, val) {
+		atomic.AddInt64(&dict.count, -1)
+		return 1
+	}
+	return 0
+}
+
+// keys 返回分片中所有key的快照
+func (s *shard) keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.t.keys()
+}
+
 func (dict *SyncDict) Keys() []string {
-	result := make([]string, dict.Len())
-	i := 0
-	dict.m.Range(func(key, value interface{}) bool {
-		result[i] = key.(string)
-		i++
-		return true
-	})
+	result := make([]string, 0, dict.Len())
+	for _, s := range dict.shards {
+		result = append(result, s.keys()...)
+	}
 	return result
 }
 
+// ForEach 遍历dict，consumer在分片的快照上执行，所以可以在consumer中修改dict
 func (dict *SyncDict) ForEach(consumer Consumer) {
-	dict.m.Range(func(key, value interface{}) bool {
-		consumer(key.(string), value)
-		return true
-	})
+	for _, s := range dict.shards {
+		s.mu.RLock()
+		entries := make(map[string]interface{}, s.t.size)
+		s.t.forEach(func(key string, val interface{}) bool {
+			entries[key] = val
+			return true
+		})
+		s.mu.RUnlock()
+		for key, val := range entries {
+			if !consumer(key, val) {
+				return
+			}
+		}
+	}
+}
+
+// randomShardTries 是randomKey随机选分片的次数，都选到空分片时再从随机的分片开始顺序查找
+const randomShardTries = 8
+
+// randomKey 随机选一个非空的分片，返回其中随机的key
+func (dict *SyncDict) randomKey() (string, bool) {
+	if dict.Len() == 0 {
+		return "", false
+	}
+	for i := 0; i < randomShardTries; i++ {
+		s := dict.shards[rand.Intn(shardCount)]
+		s.mu.RLock()
+		key, ok := s.t.randomKey()
+		s.mu.RUnlock()
+		if ok {
+			return key, true
+		}
+	}
+	start := rand.Intn(shardCount)
+	for i := 0; i < shardCount; i++ {
+		s := dict.shards[(start+i)%shardCount]
+		s.mu.RLock()
+		key, ok := s.t.randomKey()
+		s.mu.RUnlock()
+		if ok {
+			return key, true
+		}
+	}
+	return "", false
 }
 
 func (dict *SyncDict) RandomKeys(limit int) []string {
-	result := make([]string, limit)
+	result := make([]string, 0, limit)
 	for i := 0; i < limit; i++ {
-		dict.m.Range(func(key, value interface{}) bool {
-			result[i] = key.(string)
-			return false
-		})
+		key, ok := dict.randomKey()
+		if !ok {
+			break
+		}
+		result = append(result, key)
 	}
 	return result
-
 }
 
 func (dict *SyncDict) RandomDistinctKeys(limit int) []string {
-	result := make([]string, limit)
-	i := 0
-	dict.m.Range(func(key, value interface{}) bool {
-		result[i] = key.(string)
-		i++
-		if i == limit {
-			return false
+	return randomDistinctKeys(dict.Len(), limit, dict.randomKey, dict.forEachShard)
+}
+
+// forEachShard 依次在每个分片的读锁内遍历，不复制分片，consumer不能再访问dict
+func (dict *SyncDict) forEachShard(consumer Consumer) {
+	stopped := false
+	for _, s := range dict.shards {
+		s.mu.RLock()
+		s.t.forEach(func(key string, val interface{}) bool {
+			stopped = !consumer(key, val)
+			return !stopped
+		})
+		s.mu.RUnlock()
+		if stopped {
+			return
 		}
-		return true
-	})
-	return result
+	}
+}
+
+// Scan 按hash顺序从cursor开始遍历至少count个key，返回下一次遍历的cursor，0表示遍历结束
+func (dict *SyncDict) Scan(cursor uint64, count int) ([]string, uint64) {
+	if count < 1 {
+		count = 1
+	}
+	var result []string
+	for i := shardIndex(cursor); i < shardCount; i++ {
+		s := dict.shards[i]
+		s.mu.RLock()
+		keys, next := s.t.scan(cursor, count-len(result))
+		s.mu.RUnlock()
+		result = append(result, keys...)
+		if next != 0 {
+			// stopped inside the shard
+			return result, next
+		}
+		if i+1 == shardCount {
+			break
+		}
+		cursor = shardStart(i + 1)
+		if len(result) >= count {
+			return result, cursor
+		}
+	}
+	return result, 0
 }
 
 func (dict *SyncDict) Clear() {
-	*dict = *MakeSyncDict()
+	for _, s := range dict.shards {
+		s.mu.Lock()
+		atomic.AddInt64(&dict.count, -int64(s.t.size))
+		s.t.clear()
+		s.mu.Unlock()
+	}
 }
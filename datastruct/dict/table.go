@@ -0,0 +1,243 @@
+package dict
+
+import "math/rand"
+
+// table 按key的hash高位分桶，每个桶是hash的一段连续区间，桶的顺序即遍历顺序。
+// Scan只需要排序cursor所在的桶，而不是复制和排序整个dict。
+// 桶的数量随key的数量翻倍或减半，扩缩容不改变key的hash，所以cursor始终有效。
+
+const (
+	maxBucketLoad = 8 // 平均每个桶的key超过这个数量时桶的数量翻倍
+	minBucketLoad = 2 // 平均每个桶的key少于这个数量时桶的数量减半
+)
+
+type table struct {
+	prefix  uint64 // 表中所有key的hash的高offset位都与prefix相同
+	offset  uint
+	bits    uint // 桶的数量的位数，桶按hash的第offset位之后的bits位划分
+	buckets []map[string]interface{}
+	size    int
+	resized int // 扩缩容的次数，用于判断遍历过程中是否发生了扩缩容
+}
+
+func makeTable(prefix uint64, offset uint) table {
+	return table{
+		prefix:  prefix,
+		offset:  offset,
+		buckets: []map[string]interface{}{make(map[string]interface{})},
+	}
+}
+
+// bucketIndex 返回hash所在的桶的下标
+func (t *table) bucketIndex(hash uint64) int {
+	if t.bits == 0 {
+		return 0
+	}
+	return int(hash << t.offset >> (64 - t.bits))
+}
+
+func (t *table) bucket(key string) map[string]interface{} {
+	if t.bits == 0 {
+		return t.buckets[0]
+	}
+	return t.buckets[t.bucketIndex(Hash(key))]
+}
+
+// bucketStart 返回第i个桶的最小hash
+func (t *table) bucketStart(i int) uint64 {
+	return t.prefix | uint64(i)<<(64-t.bits)>>t.offset
+}
+
+func (t *table) get(key string) (interface{}, bool) {
+	val, ok := t.bucket(key)[key]
+	return val, ok
+}
+
+// put 返回key是否是新增的
+func (t *table) put(key string, val interface{}) bool {
+	b := t.bucket(key)
+	_, existed := b[key]
+	b[key] = val
+	if existed {
+		return false
+	}
+	t.size++
+	if t.size > maxBucketLoad<<t.bits {
+		t.resize(t.bits + 1)
+	}
+	return true
+}
+
+func (t *table) putIfExists(key string, val interface{}) bool {
+	b := t.bucket(key)
+	if _, existed := b[key]; !existed {
+		return false
+	}
+	b[key] = val
+	return true
+}
+
+func (t *table) remove(key string) bool {
+	b := t.bucket(key)
+	if _, existed := b[key]; !existed {
+		return false
+	}
+	delete(b, key)
+	t.size--
+	if t.bits > 0 && t.size < minBucketLoad<<t.bits {
+		t.resize(t.bits - 1)
+	}
+	return true
+}
+
+// removeIfEqual 只有当前值是val时才删除key
+func (t *table) removeIfEqual(key string, val interface{}) bool {
+	if cur, ok := t.get(key); !ok || cur != val {
+		return false
+	}
+	return t.remove(key)
+}
+
+func (t *table) resize(bits uint) {
+	old := t.buckets
+	t.bits = bits
+	t.buckets = make([]map[string]interface{}, 1<<bits)
+	for i := range t.buckets {
+		t.buckets[i] = make(map[string]interface{})
+	}
+	for _, b := range old {
+		for key, val := range b {
+			t.bucket(key)[key] = val
+		}
+	}
+	t.resized++
+}
+
+func (t *table) clear() {
+	resized := t.resized + 1
+	*t = makeTable(t.prefix, t.offset)
+	t.resized = resized
+}
+
+// forEach 遍历所有key，consumer中可以修改table，遍历中途被删除的key不会再返回
+func (t *table) forEach(consumer Consumer) {
+	buckets, resized := t.buckets, t.resized
+	for _, b := range buckets {
+		for key, val := range b {
+			if t.resized != resized {
+				// the bucket is stale after resizing, check the key again
+				var ok bool
+				if val, ok = t.get(key); !ok {
+					continue
+				}
+			}
+			if !consumer(key, val) {
+				return
+			}
+		}
+	}
+}
+
+func (t *table) keys() []string {
+	keys := make([]string, 0, t.size)
+	for _, b := range t.buckets {
+		for key := range b {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// randomKey 随机选一个非空的桶，再返回桶中随机位置的key。
+// 扩缩容使桶的平均key数量保持在minBucketLoad和maxBucketLoad之间，所以很少选到空桶
+func (t *table) randomKey() (string, bool) {
+	if t.size == 0 {
+		return "", false
+	}
+	for {
+		b := t.buckets[rand.Intn(len(t.buckets))]
+		if len(b) == 0 {
+			continue
+		}
+		n := rand.Intn(len(b))
+		for key := range b {
+			if n == 0 {
+				return key, true
+			}
+			n--
+		}
+	}
+}
+
+// randomDistinctKeys 从有size个key的dict中随机返回最多limit个不同的key。
+// limit远小于size时重复随机取key并去重，否则用蓄水池抽样遍历一次dict
+func randomDistinctKeys(size int, limit int, randomKey func() (string, bool), forEach func(Consumer)) []string {
+	if limit <= 0 || size == 0 {
+		return []string{}
+	}
+	if limit*3 <= size {
+		result := make([]string, 0, limit)
+		picked := make(map[string]struct{}, limit)
+		// 并发删除可能让dict变小，限制尝试次数避免一直取不够
+		for i := 0; i < limit*10 && len(result) < limit; i++ {
+			key, ok := randomKey()
+			if !ok {
+				break
+			}
+			if _, exists := picked[key]; exists {
+				continue
+			}
+			picked[key] = struct{}{}
+			result = append(result, key)
+		}
+		return result
+	}
+	if limit > size {
+		limit = size
+	}
+	result := make([]string, 0, limit)
+	seen := 0
+	forEach(func(key string, val interface{}) bool {
+		if len(result) < limit {
+			result = append(result, key)
+		} else if i := rand.Intn(seen + 1); i < limit {
+			result[i] = key
+		}
+		seen++
+		return true
+	})
+	// 前limit个key是按hash顺序放入的，打乱顺序
+	rand.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result
+}
+
+// scan 从cursor所在的桶开始按hash顺序返回至少count个key，cursor必须在table的hash区间内，
+// next为0表示table已经遍历完
+func (t *table) scan(cursor uint64, count int) ([]string, uint64) {
+	if count < 1 {
+		count = 1
+	}
+	var result []string
+	for i := t.bucketIndex(cursor); i < len(t.buckets); i++ {
+		keys := make([]string, 0, len(t.buckets[i]))
+		for key := range t.buckets[i] {
+			keys = append(keys, key)
+		}
+		scanned, next := ScanKeys(keys, cursor, count-len(result))
+		result = append(result, scanned...)
+		if next != 0 {
+			// stopped inside the bucket
+			return result, next
+		}
+		if i+1 == len(t.buckets) {
+			break
+		}
+		cursor = t.bucketStart(i + 1)
+		if len(result) >= count {
+			return result, cursor
+		}
+	}
+	return result, 0
+}
@@ -0,0 +1,474 @@
+// Package hyperloglog implements the HyperLogLog stored in string values, the layout is the same as redis:
+//
+//	+------+---+-----+----------+
+//	| HYLL | E | N/U | Cardin.  |
+//	+------+---+-----+----------+
+//
+// The 16 bytes header is composed of the magic "HYLL", one byte of encoding (dense or sparse), three unused bytes
+// and the cached cardinality as a little endian 64 bit integer. The most significant bit of the last byte is set
+// if the cache is invalid.
+//
+// The dense encoding stores 16384 registers of 6 bits, the least significant bits of a register come first.
+// The sparse encoding is a run length encoding of registers with three opcodes:
+//
+//	ZERO:  00xxxxxx, a run of 1-64 zero registers
+//	XZERO: 01xxxxxx yyyyyyyy, a run of 1-16384 zero registers
+//	VAL:   1vvvvvxx, a run of 1-4 registers of value 1-32
+package hyperloglog
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const (
+	precision     = 14
+	RegisterCount = 1 << precision // the number of registers, 16384
+	registerMask  = RegisterCount - 1
+	registerBits  = 6
+	registerMax   = 1<<registerBits - 1
+	hashBits      = 64 - precision // the number of bits used to count leading zeros
+
+	headerSize = 16
+	denseSize  = headerSize + (RegisterCount*registerBits+7)/8
+
+	encodingDense  = 0
+	encodingSparse = 1
+
+	sparseValMax    = 32
+	sparseValRunMax = 4
+	sparseZeroMax   = 64
+	sparseXZeroMax  = 16384
+
+	// SparseMaxBytes is the greatest size of sparse representation, it is converted to dense beyond
+	SparseMaxBytes = 3000
+
+	hashSeed = 0xadc83b19
+
+	alphaInf = 0.721347520444481703680 // 0.5/ln(2)
+)
+
+var magic = []byte("HYLL")
+
+var (
+	// ErrInvalid is returned if the string is not a HyperLogLog
+	ErrInvalid = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	// ErrCorrupted is returned if the sparse representation is broken
+	ErrCorrupted = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// Make creates an empty HyperLogLog in sparse encoding
+func Make() []byte {
+	b := make([]byte, headerSize, headerSize+2)
+	copy(b, magic)
+	b[4] = encodingSparse
+	// a single XZERO opcode covers all registers
+	b = append(b, 0x40|byte((RegisterCount-1)>>8), byte((RegisterCount-1)&0xff))
+	return b
+}
+
+// Validate checks whether the string is a HyperLogLog
+func Validate(b []byte) error {
+	if len(b) < headerSize || string(b[:4]) != string(magic) {
+		return ErrInvalid
+	}
+	switch b[4] {
+	case encodingDense:
+		if len(b) != denseSize {
+			return ErrInvalid
+		}
+	case encodingSparse:
+	default:
+		return ErrInvalid
+	}
+	return nil
+}
+
+// IsDense returns whether the HyperLogLog is in dense encoding
+func IsDense(b []byte) bool {
+	return b[4] == encodingDense
+}
+
+func invalidateCache(b []byte) {
+	b[15] |= 1 << 7
+}
+
+// hashElement returns the register index of element and the length of the pattern 000..1 of its hash
+func hashElement(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, hashSeed)
+	index := int(hash & registerMask)
+	hash >>= precision
+	hash |= 1 << hashBits // make sure the loop terminates
+	var count uint8 = 1
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+func getDenseRegister(registers []byte, index int) uint8 {
+	offset := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	b0 := registers[offset]
+	var b1 byte
+	if offset+1 < len(registers) {
+		b1 = registers[offset+1]
+	}
+	return uint8((uint(b0)>>fb)|(uint(b1)<<(8-fb))) & registerMax
+}
+
+func setDenseRegister(registers []byte, index int, value uint8) {
+	offset := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	v := uint(value)
+	registers[offset] &^= byte(registerMax << fb)
+	registers[offset] |= byte(v << fb)
+	if offset+1 < len(registers) {
+		registers[offset+1] &^= byte(registerMax >> (8 - fb))
+		registers[offset+1] |= byte(v >> (8 - fb))
+	}
+}
+
+// decodeSparse expands the sparse representation into registers
+func decodeSparse(data []byte, registers []uint8) error {
+	index := 0
+	for i := 0; i < len(data); {
+		op := data[i]
+		switch {
+		case op&0xc0 == 0x00: // ZERO
+			index += int(op&0x3f) + 1
+			i++
+		case op&0xc0 == 0x40: // XZERO
+			if i+1 >= len(data) {
+				return ErrCorrupted
+			}
+			index += (int(op&0x3f)<<8 | int(data[i+1])) + 1
+			i += 2
+		default: // VAL
+			value := (op>>2)&0x1f + 1
+			run := int(op&0x03) + 1
+			if index+run > RegisterCount {
+				return ErrCorrupted
+			}
+			for j := 0; j < run; j++ {
+				registers[index+j] = value
+			}
+			index += run
+			i++
+		}
+		if index > RegisterCount {
+			return ErrCorrupted
+		}
+	}
+	if index != RegisterCount {
+		return ErrCorrupted
+	}
+	return nil
+}
+
+// Registers returns values of all registers
+func Registers(b []byte) ([]uint8, error) {
+	registers := make([]uint8, RegisterCount)
+	if err := MergeRegisters(registers, b); err != nil {
+		return nil, err
+	}
+	return registers, nil
+}
+
+// MergeRegisters sets each register in max to the greater one of itself and that of b
+func MergeRegisters(max []uint8, b []byte) error {
+	if err := Validate(b); err != nil {
+		return err
+	}
+	if IsDense(b) {
+		for i := 0; i < RegisterCount; i++ {
+			if v := getDenseRegister(b[headerSize:], i); v > max[i] {
+				max[i] = v
+			}
+		}
+		return nil
+	}
+	registers := make([]uint8, RegisterCount)
+	if err := decodeSparse(b[headerSize:], registers); err != nil {
+		return err
+	}
+	for i, v := range registers {
+		if v > max[i] {
+			max[i] = v
+		}
+	}
+	return nil
+}
+
+// appendSparseRun appends opcodes of a run of registers with the same value, value must be <= sparseValMax
+func appendSparseRun(data []byte, value uint8, run int) []byte {
+	for run > 0 {
+		if value == 0 {
+			if run <= sparseZeroMax {
+				data = append(data, byte(run-1))
+				run = 0
+			} else {
+				n := run
+				if n > sparseXZeroMax {
+					n = sparseXZeroMax
+				}
+				data = append(data, 0x40|byte((n-1)>>8), byte((n-1)&0xff))
+				run -= n
+			}
+		} else {
+			n := run
+			if n > sparseValRunMax {
+				n = sparseValRunMax
+			}
+			data = append(data, 0x80|(value-1)<<2|byte(n-1))
+			run -= n
+		}
+	}
+	return data
+}
+
+// encodeSparse returns the sparse representation of registers, false if it is not representable or too large
+func encodeSparse(registers []uint8) ([]byte, bool) {
+	data := make([]byte, 0, 64)
+	for i := 0; i < RegisterCount; {
+		value := registers[i]
+		run := 1
+		for i+run < RegisterCount && registers[i+run] == value {
+			run++
+		}
+		i += run
+		if value > sparseValMax {
+			return nil, false
+		}
+		data = appendSparseRun(data, value, run)
+		if len(data) > SparseMaxBytes-headerSize {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// decodeSparseOp returns the register value, the number of registers and the size in bytes of the opcode at i
+func decodeSparseOp(data []byte, i int) (uint8, int, int, error) {
+	op := data[i]
+	switch {
+	case op&0xc0 == 0x00: // ZERO
+		return 0, int(op&0x3f) + 1, 1, nil
+	case op&0xc0 == 0x40: // XZERO
+		if i+1 >= len(data) {
+			return 0, 0, 0, ErrCorrupted
+		}
+		return 0, (int(op&0x3f)<<8 | int(data[i+1])) + 1, 2, nil
+	default: // VAL
+		return (op>>2)&0x1f + 1, int(op&0x03) + 1, 1, nil
+	}
+}
+
+// mergeSparseVals joins adjacent VAL opcodes of the same value in place, like redis does after updating a register
+func mergeSparseVals(data []byte) []byte {
+	w := 0
+	lastVal := -1 // position of the last written opcode if it is a VAL
+	for r := 0; r < len(data); {
+		op := data[r]
+		if op&0xc0 == 0x40 { // XZERO
+			data[w], data[w+1] = op, data[r+1]
+			w += 2
+			r += 2
+			lastVal = -1
+			continue
+		}
+		r++
+		if op&0x80 != 0 && lastVal >= 0 {
+			prev := data[lastVal]
+			run := int(prev&0x03) + int(op&0x03) + 2
+			if prev&0x7c == op&0x7c && run <= sparseValRunMax {
+				data[lastVal] = 0x80 | op&0x7c | byte(run-1)
+				continue
+			}
+		}
+		data[w] = op
+		if op&0x80 != 0 {
+			lastVal = w
+		} else {
+			lastVal = -1
+		}
+		w++
+	}
+	return data[:w]
+}
+
+// sparseSet sets the register at index to count if it is greater, updating the opcodes in place as redis does.
+// It returns the new HyperLogLog, whether the register was updated, and false if it has to be promoted to dense.
+func sparseSet(b []byte, index int, count uint8) ([]byte, bool, bool, error) {
+	data := b[headerSize:]
+	first := 0
+	for i := 0; i < len(data); {
+		value, run, size, err := decodeSparseOp(data, i)
+		if err != nil {
+			return nil, false, false, err
+		}
+		if first+run <= index {
+			first += run
+			i += size
+			continue
+		}
+		if value >= count {
+			return b, false, true, nil
+		}
+		if count > sparseValMax {
+			return nil, false, false, nil
+		}
+		// split the opcode covering index into at most three runs
+		seq := make([]byte, 0, 5)
+		seq = appendSparseRun(seq, value, index-first)
+		seq = appendSparseRun(seq, count, 1)
+		seq = appendSparseRun(seq, value, first+run-index-1)
+		result := make([]byte, 0, len(b)-size+len(seq))
+		result = append(result, b[:headerSize+i]...)
+		result = append(result, seq...)
+		result = append(result, data[i+size:]...)
+		result = append(result[:headerSize], mergeSparseVals(result[headerSize:])...)
+		if len(result) > SparseMaxBytes {
+			return nil, false, false, nil
+		}
+		return result, true, true, nil
+	}
+	return nil, false, false, ErrCorrupted
+}
+
+// FromRegisters encodes registers as a HyperLogLog, it uses sparse encoding if possible unless dense is required
+func FromRegisters(registers []uint8, dense bool) []byte {
+	if !dense {
+		if data, ok := encodeSparse(registers); ok {
+			b := make([]byte, headerSize, headerSize+len(data))
+			copy(b, magic)
+			b[4] = encodingSparse
+			b = append(b, data...)
+			invalidateCache(b)
+			return b
+		}
+	}
+	b := make([]byte, denseSize)
+	copy(b, magic)
+	b[4] = encodingDense
+	for i, v := range registers {
+		setDenseRegister(b[headerSize:], i, v)
+	}
+	invalidateCache(b)
+	return b
+}
+
+// Add adds elements into HyperLogLog.
+// It returns the HyperLogLog which may be a new slice if encoding changed, and whether any register was updated.
+func Add(b []byte, elements ...[]byte) ([]byte, bool, error) {
+	if err := Validate(b); err != nil {
+		return nil, false, err
+	}
+	updated := false
+	if IsDense(b) {
+		for _, element := range elements {
+			index, count := hashElement(element)
+			if getDenseRegister(b[headerSize:], index) < count {
+				setDenseRegister(b[headerSize:], index, count)
+				updated = true
+			}
+		}
+		if updated {
+			invalidateCache(b)
+		}
+		return b, updated, nil
+	}
+
+	for i, element := range elements {
+		index, count := hashElement(element)
+		result, set, ok, err := sparseSet(b, index, count)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			// promote to dense and add the rest
+			registers, err := Registers(b)
+			if err != nil {
+				return nil, false, err
+			}
+			dense, _, err := Add(FromRegisters(registers, true), elements[i:]...)
+			return dense, true, err
+		}
+		if set {
+			b = result
+			updated = true
+		}
+	}
+	if updated {
+		invalidateCache(b)
+	}
+	return b, updated, nil
+}
+
+// Count returns the estimated cardinality, the cached value in header is used and refreshed
+func Count(b []byte) (uint64, error) {
+	if err := Validate(b); err != nil {
+		return 0, err
+	}
+	if b[15]&(1<<7) == 0 {
+		return binary.LittleEndian.Uint64(b[8:16]), nil
+	}
+	registers, err := Registers(b)
+	if err != nil {
+		return 0, err
+	}
+	card := CountRegisters(registers)
+	binary.LittleEndian.PutUint64(b[8:16], card)
+	return card, nil
+}
+
+// CountRegisters estimates cardinality with the improved estimator by Otmar Ertl, the same as redis
+func CountRegisters(registers []uint8) uint64 {
+	var histogram [registerMax + 1]int
+	for _, v := range registers {
+		histogram[v]++
+	}
+	m := float64(RegisterCount)
+	z := m * tau((m-float64(histogram[hashBits+1]))/m)
+	for j := hashBits; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histogram[0])/m)
+	return uint64(math.Round(alphaInf * m * m / z))
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
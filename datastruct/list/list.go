@@ -0,0 +1,25 @@
+package list
+
+// Expected checks whether the given item equals to the expected value
+type Expected func(a interface{}) bool
+
+// Consumer traverses list, it returns false to break the loop
+type Consumer func(i int, v interface{}) bool
+
+// List is the interface of the list data structure
+type List interface {
+	Add(val interface{})
+	Get(index int) (val interface{})
+	Set(index int, val interface{})
+	Insert(index int, val interface{})
+	Remove(index int) (val interface{})
+	RemoveLast() (val interface{})
+	RemoveAllByVal(expected Expected) int
+	RemoveByVal(expected Expected, count int) int
+	ReverseRemoveByVal(expected Expected, count int) int
+	Len() int
+	ForEach(consumer Consumer)
+	ReverseForEach(consumer Consumer)
+	Contains(expected Expected) bool
+	Range(start int, stop int) []interface{}
+}
@@ -0,0 +1,115 @@
+package set
+
+import "goRedis/datastruct/dict"
+
+// Set 基于dict实现的集合，注意：并发不安全
+type Set struct {
+	dict dict.Dict
+}
+
+// Make 创建新的集合并加入给定的成员
+func Make(members ...string) *Set {
+	set := &Set{
+		dict: dict.MakeSimple(),
+	}
+	for _, member := range members {
+		set.Add(member)
+	}
+	return set
+}
+
+// Add 添加成员，返回新增的成员数量
+func (set *Set) Add(val string) int {
+	return set.dict.Put(val, nil)
+}
+
+// Remove 删除成员，返回删除的成员数量
+func (set *Set) Remove(val string) int {
+	return set.dict.Remove(val)
+}
+
+// Has 判断成员是否存在
+func (set *Set) Has(val string) bool {
+	_, exists := set.dict.Get(val)
+	return exists
+}
+
+// Len 返回成员数量
+func (set *Set) Len() int {
+	return set.dict.Len()
+}
+
+// ToSlice 以slice的形式返回所有成员
+func (set *Set) ToSlice() []string {
+	slice := make([]string, 0, set.Len())
+	set.dict.ForEach(func(key string, val interface{}) bool {
+		slice = append(slice, key)
+		return true
+	})
+	return slice
+}
+
+// ForEach 遍历集合，consumer返回false时停止
+func (set *Set) ForEach(consumer func(member string) bool) {
+	set.dict.ForEach(func(key string, val interface{}) bool {
+		return consumer(key)
+	})
+}
+
+// Scan 从cursor开始遍历至少count个成员，返回下一次遍历的cursor，0表示遍历结束
+func (set *Set) Scan(cursor uint64, count int) ([]string, uint64) {
+	return set.dict.Scan(cursor, count)
+}
+
+// Intersect 求交集，返回新的集合
+func (set *Set) Intersect(another *Set) *Set {
+	result := Make()
+	// 遍历较小的集合
+	small, big := set, another
+	if small.Len() > big.Len() {
+		small, big = big, small
+	}
+	small.ForEach(func(member string) bool {
+		if big.Has(member) {
+			result.Add(member)
+		}
+		return true
+	})
+	return result
+}
+
+// Union 求并集，返回新的集合
+func (set *Set) Union(another *Set) *Set {
+	result := Make()
+	set.ForEach(func(member string) bool {
+		result.Add(member)
+		return true
+	})
+	another.ForEach(func(member string) bool {
+		result.Add(member)
+		return true
+	})
+	return result
+}
+
+// Diff 求差集，返回在set中但不在another中的成员
+func (set *Set) Diff(another *Set) *Set {
+	result := Make()
+	set.ForEach(func(member string) bool {
+		if !another.Has(member) {
+			result.Add(member)
+		}
+		return true
+	})
+	return result
+}
+
+// RandomMembers 随机返回limit个成员，可能包含重复的成员
+func (set *Set) RandomMembers(limit int) []string {
+	return set.dict.RandomKeys(limit)
+}
+
+// RandomDistinctMembers 随机返回limit个不重复的成员
+func (set *Set) RandomDistinctMembers(limit int) []string {
+	return set.dict.RandomDistinctKeys(limit)
+}
@@ -0,0 +1,125 @@
+package sortedset
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+/*
+ * 1 means 1 is included
+ * (1 means 1 is excluded
+ * -inf and +inf are regarded as float infinity
+ */
+
+// ScoreBorder represents the min or max of a score range
+type ScoreBorder struct {
+	Value   float64
+	Exclude bool
+}
+
+// errInvalidScoreBorder is returned if the border is not a float
+var errInvalidScoreBorder = errors.New("ERR min or max is not a float")
+
+// ParseScoreBorder parses score border like `1.5`, `(1.5`, `-inf` or `+inf`
+func ParseScoreBorder(s string) (*ScoreBorder, error) {
+	exclude := false
+	if len(s) > 0 && s[0] == '(' {
+		exclude = true
+		s = s[1:]
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) {
+		return nil, errInvalidScoreBorder
+	}
+	return &ScoreBorder{
+		Value:   value,
+		Exclude: exclude,
+	}, nil
+}
+
+// greater returns whether score is on the right side of border when border is the min of range
+func (border *ScoreBorder) greater(score float64) bool {
+	if border.Exclude {
+		return score > border.Value
+	}
+	return score >= border.Value
+}
+
+// less returns whether score is on the left side of border when border is the max of range
+func (border *ScoreBorder) less(score float64) bool {
+	if border.Exclude {
+		return score < border.Value
+	}
+	return score <= border.Value
+}
+
+/*
+ * [abc means abc is included
+ * (abc means abc is excluded
+ * - means negative infinity
+ * + means positive infinity
+ */
+
+const (
+	lexNegativeInf int8 = '-'
+	lexPositiveInf int8 = '+'
+)
+
+// LexBorder represents the min or max of a lexicographical range
+type LexBorder struct {
+	Inf     int8
+	Value   string
+	Exclude bool
+}
+
+// errInvalidLexBorder is returned if the border is not started with `[`, `(`, or is not `-` or `+`
+var errInvalidLexBorder = errors.New("ERR min or max not valid string range item")
+
+// ParseLexBorder parses lex border like `[a`, `(a`, `-` or `+`
+func ParseLexBorder(s string) (*LexBorder, error) {
+	if s == "-" {
+		return &LexBorder{Inf: lexNegativeInf}, nil
+	}
+	if s == "+" {
+		return &LexBorder{Inf: lexPositiveInf}, nil
+	}
+	if len(s) == 0 {
+		return nil, errInvalidLexBorder
+	}
+	switch s[0] {
+	case '[':
+		return &LexBorder{Value: s[1:]}, nil
+	case '(':
+		return &LexBorder{Value: s[1:], Exclude: true}, nil
+	}
+	return nil, errInvalidLexBorder
+}
+
+// greater returns whether member is on the right side of border when border is the min of range
+func (border *LexBorder) greater(member string) bool {
+	if border.Inf == lexNegativeInf {
+		return true
+	}
+	if border.Inf == lexPositiveInf {
+		return false
+	}
+	if border.Exclude {
+		return member > border.Value
+	}
+	return member >= border.Value
+}
+
+// less returns whether member is on the left side of border when border is the max of range
+func (border *LexBorder) less(member string) bool {
+	if border.Inf == lexNegativeInf {
+		return false
+	}
+	if border.Inf == lexPositiveInf {
+		return true
+	}
+	if border.Exclude {
+		return member < border.Value
+	}
+	return member <= border.Value
+}
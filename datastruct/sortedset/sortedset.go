@@ -1,6 +1,7 @@
 package sortedset
 
 import (
+	"goRedis/datastruct/dict"
 	"math/rand"
 	"time"
 )
@@ -34,6 +35,7 @@ type SortedSet struct {
 	tail   *node
 	length int64
 	level  int
+	dict   *dict.SimpleDict // member -> *Element, the skip list is ordered by score so members are looked up here
 }
 
 // Make creates a new sorted set
@@ -43,6 +45,7 @@ func Make() *SortedSet {
 
 	sortedSet := &SortedSet{
 		level: 1,
+		dict:  dict.MakeSimple(),
 	}
 
 	// init header node
@@ -169,21 +172,43 @@ func (sortedSet *SortedSet) deleteNode(node *node, update []*node) {
 	sortedSet.length--
 }
 
+// lookup returns the element of member in dict
+func (sortedSet *SortedSet) lookup(member string) (*Element, bool) {
+	val, ok := sortedSet.dict.Get(member)
+	if !ok {
+		return nil, false
+	}
+	return val.(*Element), true
+}
+
 // Remove deletes a member from the sorted set
 func (sortedSet *SortedSet) Remove(member string) bool {
+	element, ok := sortedSet.lookup(member)
+	if !ok {
+		return false
+	}
+	sortedSet.removeNode(member, element.Score)
+	sortedSet.dict.Remove(member)
+	return true
+}
+
+// removeNode removes the node with the given member and score from skip list
+func (sortedSet *SortedSet) removeNode(member string, score float64) bool {
 	update := make([]*node, maxLevel)
 	node := sortedSet.header
 
 	for i := sortedSet.level - 1; i >= 0; i-- {
 		for node.level[i].forward != nil &&
-			(node.level[i].forward.Member < member) {
+			(node.level[i].forward.Score < score ||
+				(node.level[i].forward.Score == score &&
+					node.level[i].forward.Member < member)) {
 			node = node.level[i].forward
 		}
 		update[i] = node
 	}
 
 	node = node.level[0].forward
-	if node != nil && node.Member == member {
+	if node != nil && node.Score == score && node.Member == member {
 		sortedSet.deleteNode(node, update)
 		return true
 	}
@@ -193,77 +218,65 @@ func (sortedSet *SortedSet) Remove(member string) bool {
 
 // Exists checks if a member exists in the sorted set
 func (sortedSet *SortedSet) Exists(member string) bool {
-	node := sortedSet.getByMember(member)
-	return node != nil
+	_, ok := sortedSet.dict.Get(member)
+	return ok
 }
 
-// Add adds or updates a member in the sorted set
+// Add adds or updates a member in the sorted set, returns true if the member is new
 func (sortedSet *SortedSet) Add(member string, score float64) bool {
-	// find if member exists
-	existed := false
-	update := make([]*node, maxLevel)
-	rank := make([]int64, maxLevel)
-
-	node := sortedSet.header
-	for i := sortedSet.level - 1; i >= 0; i-- {
-		if i == sortedSet.level-1 {
-			rank[i] = 0
-		} else {
-			rank[i] = rank[i+1]
+	element, existed := sortedSet.lookup(member)
+	if existed {
+		if element.Score == score {
+			return false
 		}
-
-		for node.level[i].forward != nil &&
-			(node.level[i].forward.Score < score ||
-				(node.level[i].forward.Score == score &&
-					node.level[i].forward.Member < member)) {
-			rank[i] += node.level[i].span
-			node = node.level[i].forward
-		}
-		update[i] = node
+		/* If the node is already in the skip list, remove it and re-insert it. */
+		sortedSet.removeNode(member, element.Score)
 	}
-
-	/* If the node is already in the skip list, remove it and re-insert it. */
-	node = node.level[0].forward
-	if node != nil && node.Member == member {
-		sortedSet.deleteNode(node, update)
-		existed = true
-	}
-
+	sortedSet.dict.Put(member, &Element{
+		Member: member,
+		Score:  score,
+	})
 	sortedSet.insert(member, score)
-	return existed
+	return !existed
 }
 
-// GetRank returns the rank of a member
+// GetRank returns the rank of a member, the rank starts from 0
 func (sortedSet *SortedSet) GetRank(member string, reverse bool) (int64, bool) {
+	element, ok := sortedSet.lookup(member)
+	if !ok {
+		return 0, false
+	}
 	var rank int64 = 0
 	node := sortedSet.header
 
 	for i := sortedSet.level - 1; i >= 0; i-- {
 		for node.level[i].forward != nil &&
-			(node.level[i].forward.Member < member) {
+			(node.level[i].forward.Score < element.Score ||
+				(node.level[i].forward.Score == element.Score &&
+					node.level[i].forward.Member <= member)) {
 			rank += node.level[i].span
 			node = node.level[i].forward
 		}
-	}
-
-	node = node.level[0].forward
-	if node != nil && node.Member == member {
-		if reverse {
-			return sortedSet.length - rank - 1, true
+		if node.Member == member && node != sortedSet.header {
+			break
 		}
-		return rank, true
 	}
 
-	return 0, false
+	// rank counted from header is 1-based
+	rank--
+	if reverse {
+		return sortedSet.length - rank - 1, true
+	}
+	return rank, true
 }
 
 // GetScore returns the score of a member
 func (sortedSet *SortedSet) GetScore(member string) (float64, bool) {
-	node := sortedSet.getByMember(member)
-	if node != nil {
-		return node.Score, true
+	element, ok := sortedSet.lookup(member)
+	if !ok {
+		return 0, false
 	}
-	return 0, false
+	return element.Score, true
 }
 
 // GetByRank returns a member at the given rank
@@ -281,8 +294,8 @@ func (sortedSet *SortedSet) GetByRank(rank int64, reverse bool) (*Element, bool)
 	var i int64 = 0
 	n := sortedSet.header
 
-	// scan forward from header
-	for i = 0; i < rank; {
+	// scan forward from header, the first node is at rank 0
+	for i = 0; i <= rank; {
 		if n.level[0].forward == nil {
 			// should not happen
 			return nil, false
@@ -299,21 +312,21 @@ func (sortedSet *SortedSet) GetByRank(rank int64, reverse bool) (*Element, bool)
 }
 
 // GetByScoreRange returns members with score in the given range
-func (sortedSet *SortedSet) GetByScoreRange(min, max float64, offset, limit int64, reverse bool) []*Element {
+func (sortedSet *SortedSet) GetByScoreRange(min, max *ScoreBorder, offset, limit int64, reverse bool) []*Element {
 	if reverse {
 		return sortedSet.getByScoreRangeReverse(min, max, offset, limit)
 	}
 	return sortedSet.getByScoreRange(min, max, offset, limit)
 }
 
-func (sortedSet *SortedSet) getByScoreRange(min, max float64, offset, limit int64) []*Element {
+func (sortedSet *SortedSet) getByScoreRange(min, max *ScoreBorder, offset, limit int64) []*Element {
 	// find start node
 	//var i int64 = 0 // used for offset
 	n := sortedSet.header
 
 	// skip to the first node with score >= min
 	for i := sortedSet.level - 1; i >= 0; i-- {
-		for n.level[i].forward != nil && n.level[i].forward.Score < min {
+		for n.level[i].forward != nil && !min.greater(n.level[i].forward.Score) {
 			n = n.level[i].forward
 		}
 	}
@@ -329,7 +342,7 @@ func (sortedSet *SortedSet) getByScoreRange(min, max float64, offset, limit int6
 
 	var result []*Element
 	// get all nodes with score <= max
-	for n != nil && n.Score <= max && (limit < 0 || limit > 0) {
+	for n != nil && max.less(n.Score) && (limit < 0 || limit > 0) {
 		result = append(result, &Element{
 			Member: n.Member,
 			Score:  n.Score,
@@ -345,14 +358,14 @@ func (sortedSet *SortedSet) getByScoreRange(min, max float64, offset, limit int6
 	return result
 }
 
-func (sortedSet *SortedSet) getByScoreRangeReverse(min, max float64, offset, limit int64) []*Element {
+func (sortedSet *SortedSet) getByScoreRangeReverse(min, max *ScoreBorder, offset, limit int64) []*Element {
 	var result []*Element
 
 	// get the last node
 	n := sortedSet.tail
 
 	// skip nodes with score > max
-	for n != nil && n.Score > max {
+	for n != nil && !max.less(n.Score) {
 		n = n.backward
 	}
 
@@ -363,7 +376,7 @@ func (sortedSet *SortedSet) getByScoreRangeReverse(min, max float64, offset, lim
 	}
 
 	// get all nodes with score >= min
-	for n != nil && n.Score >= min && (limit < 0 || limit > 0) {
+	for n != nil && min.greater(n.Score) && (limit < 0 || limit > 0) {
 		result = append(result, &Element{
 			Member: n.Member,
 			Score:  n.Score,
@@ -379,22 +392,22 @@ func (sortedSet *SortedSet) getByScoreRangeReverse(min, max float64, offset, lim
 	return result
 }
 
-// GetByLexRange returns members with member in the given range
-func (sortedSet *SortedSet) GetByLexRange(min, max string, offset, limit int64, reverse bool) []*Element {
+// GetByLexRange returns members within the given lexicographical range, it assumes all members have the same score
+func (sortedSet *SortedSet) GetByLexRange(min, max *LexBorder, offset, limit int64, reverse bool) []*Element {
 	if reverse {
 		return sortedSet.getByLexRangeReverse(min, max, offset, limit)
 	}
 	return sortedSet.getByLexRange(min, max, offset, limit)
 }
 
-func (sortedSet *SortedSet) getByLexRange(min, max string, offset, limit int64) []*Element {
+func (sortedSet *SortedSet) getByLexRange(min, max *LexBorder, offset, limit int64) []*Element {
 	// find start node
 	//var i int64 = 0 // used for offset
 	n := sortedSet.header
 
 	// skip to the first node with member >= min
 	for i := sortedSet.level - 1; i >= 0; i-- {
-		for n.level[i].forward != nil && n.level[i].forward.Member < min {
+		for n.level[i].forward != nil && !min.greater(n.level[i].forward.Member) {
 			n = n.level[i].forward
 		}
 	}
@@ -410,7 +423,7 @@ func (sortedSet *SortedSet) getByLexRange(min, max string, offset, limit int64)
 
 	var result []*Element
 	// get all nodes with member <= max
-	for n != nil && n.Member <= max && (limit < 0 || limit > 0) {
+	for n != nil && max.less(n.Member) && (limit < 0 || limit > 0) {
 		result = append(result, &Element{
 			Member: n.Member,
 			Score:  n.Score,
@@ -426,14 +439,14 @@ func (sortedSet *SortedSet) getByLexRange(min, max string, offset, limit int64)
 	return result
 }
 
-func (sortedSet *SortedSet) getByLexRangeReverse(min, max string, offset, limit int64) []*Element {
+func (sortedSet *SortedSet) getByLexRangeReverse(min, max *LexBorder, offset, limit int64) []*Element {
 	var result []*Element
 
 	// get the last node
 	n := sortedSet.tail
 
 	// skip nodes with member > max
-	for n != nil && n.Member > max {
+	for n != nil && !max.less(n.Member) {
 		n = n.backward
 	}
 
@@ -444,7 +457,7 @@ func (sortedSet *SortedSet) getByLexRangeReverse(min, max string, offset, limit
 	}
 
 	// get all nodes with member >= min
-	for n != nil && n.Member >= min && (limit < 0 || limit > 0) {
+	for n != nil && min.greater(n.Member) && (limit < 0 || limit > 0) {
 		result = append(result, &Element{
 			Member: n.Member,
 			Score:  n.Score,
@@ -461,12 +474,12 @@ func (sortedSet *SortedSet) getByLexRangeReverse(min, max string, offset, limit
 }
 
 // Count returns the number of elements with score between min and max
-func (sortedSet *SortedSet) Count(min, max float64) int64 {
+func (sortedSet *SortedSet) Count(min, max *ScoreBorder) int64 {
 	return int64(len(sortedSet.GetByScoreRange(min, max, 0, -1, false)))
 }
 
 // RangeCount returns the number of elements with member between min and max
-func (sortedSet *SortedSet) RangeCount(min, max string) int64 {
+func (sortedSet *SortedSet) RangeCount(min, max *LexBorder) int64 {
 	return int64(len(sortedSet.GetByLexRange(min, max, 0, -1, false)))
 }
 
@@ -475,23 +488,6 @@ func (sortedSet *SortedSet) Len() int64 {
 	return sortedSet.length
 }
 
-func (sortedSet *SortedSet) getByMember(member string) *node {
-	n := sortedSet.header
-
-	for i := sortedSet.level - 1; i >= 0; i-- {
-		for n.level[i].forward != nil && n.level[i].forward.Member < member {
-			n = n.level[i].forward
-		}
-	}
-
-	n = n.level[0].forward
-	if n != nil && n.Member == member {
-		return n
-	}
-
-	return nil
-}
-
 // ForEach traverses the sorted set and executes the given function on each element
 func (sortedSet *SortedSet) ForEach(fn func(element *Element) bool) {
 	n := sortedSet.header.level[0].forward
@@ -507,6 +503,21 @@ func (sortedSet *SortedSet) ForEach(fn func(element *Element) bool) {
 	}
 }
 
+// Scan returns at least count elements in the order of member hash from cursor, and the cursor of next call.
+// Cursor 0 means the iteration is finished, members present during the whole iteration are returned at least once.
+func (sortedSet *SortedSet) Scan(cursor uint64, count int) ([]*Element, uint64) {
+	members, next := sortedSet.dict.Scan(cursor, count)
+	elements := make([]*Element, len(members))
+	for i, member := range members {
+		element, _ := sortedSet.lookup(member)
+		elements[i] = &Element{
+			Member: member,
+			Score:  element.Score,
+		}
+	}
+	return elements, next
+}
+
 // Range traverses the sorted set in given range
 func (sortedSet *SortedSet) Range(start, stop int64, reverse bool, fn func(element *Element) bool) {
 	if reverse {
@@ -529,13 +540,14 @@ func (sortedSet *SortedSet) Range(start, stop int64, reverse bool, fn func(eleme
 			start, stop = stop, start
 		}
 
+		// i is the rank counted from the tail
 		n := sortedSet.tail
-		i := sortedSet.length - 1
+		i := int64(0)
 
 		// skip elements before start
-		for n != nil && i > start {
+		for n != nil && i < start {
 			n = n.backward
-			i--
+			i++
 		}
 
 		// traverse elements in range
@@ -547,7 +559,7 @@ func (sortedSet *SortedSet) Range(start, stop int64, reverse bool, fn func(eleme
 				break
 			}
 			n = n.backward
-			i--
+			i++
 		}
 	} else {
 		// handle negative indexes
@@ -591,3 +603,32 @@ func (sortedSet *SortedSet) Range(start, stop int64, reverse bool, fn func(eleme
 		}
 	}
 }
+
+// sizes on 64-bit platforms used by EstimateSize
+const (
+	// nodeSize is the Element, the backward pointer and the header of level slice
+	nodeSize = 56
+	// levelSize is the pointer in level slice and the Level it points to
+	levelSize = 24
+	// dictEntrySize is the Element referenced by dict and the map entry with load factor of go maps
+	dictEntrySize = 24 + 32
+	sortedSetSize = 56
+)
+
+// EstimateSize returns the approximate bytes used by the sorted set, including skip list nodes and their levels.
+// If samples is positive, only the first samples nodes are measured and the average is applied to all nodes.
+func (sortedSet *SortedSet) EstimateSize(samples int) int64 {
+	size := int64(sortedSetSize + nodeSize + maxLevel*levelSize) // with the header node
+	var sampled, sampledSize int64
+	for n := sortedSet.header.level[0].forward; n != nil; n = n.level[0].forward {
+		if samples > 0 && sampled >= int64(samples) {
+			break
+		}
+		sampledSize += nodeSize + int64(len(n.level))*levelSize + int64(len(n.Member)) + dictEntrySize
+		sampled++
+	}
+	if sampled > 0 {
+		size += sampledSize * sortedSet.length / sampled
+	}
+	return size
+}
@@ -0,0 +1,132 @@
+package sortedset
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	zset := Make()
+	if !zset.Add("a", 1) {
+		t.Error("adding a new member should return true")
+	}
+	if zset.Add("a", 1) {
+		t.Error("adding an existing member should return false")
+	}
+	if zset.Add("a", 2) {
+		t.Error("updating the score should return false")
+	}
+	if score, ok := zset.GetScore("a"); !ok || score != 2 {
+		t.Errorf("expect score 2, actual %v", score)
+	}
+	if zset.Len() != 1 {
+		t.Errorf("expect len 1, actual %d", zset.Len())
+	}
+}
+
+func TestRank(t *testing.T) {
+	zset := Make()
+	size := 100
+	for i := size - 1; i >= 0; i-- {
+		zset.Add(strconv.Itoa(i), float64(i))
+	}
+	for i := 0; i < size; i++ {
+		member := strconv.Itoa(i)
+		rank, ok := zset.GetRank(member, false)
+		if !ok || rank != int64(i) {
+			t.Errorf("expect rank of %s to be %d, actual %d", member, i, rank)
+		}
+		rank, _ = zset.GetRank(member, true)
+		if rank != int64(size-i-1) {
+			t.Errorf("expect reverse rank of %s to be %d, actual %d", member, size-i-1, rank)
+		}
+		element, ok := zset.GetByRank(int64(i), false)
+		if !ok || element.Member != member {
+			t.Errorf("expect member at rank %d to be %s, actual %v", i, member, element)
+		}
+		element, _ = zset.GetByRank(int64(i), true)
+		if element.Member != strconv.Itoa(size-i-1) {
+			t.Errorf("expect member at reverse rank %d to be %d, actual %s", i, size-i-1, element.Member)
+		}
+	}
+	if _, ok := zset.GetRank("none", false); ok {
+		t.Error("rank of missing member should not be found")
+	}
+	if _, ok := zset.GetByRank(int64(size), false); ok {
+		t.Error("rank out of range should not be found")
+	}
+}
+
+func TestDuplicateScoreOrder(t *testing.T) {
+	zset := Make()
+	members := []string{"d", "b", "e", "a", "c"}
+	for _, member := range members {
+		zset.Add(member, 1)
+	}
+	zset.Add("z", 0)
+	// members with the same score are ordered lexicographically
+	expected := []string{"z", "a", "b", "c", "d", "e"}
+	var actual []string
+	zset.ForEach(func(element *Element) bool {
+		actual = append(actual, element.Member)
+		return true
+	})
+	if len(actual) != len(expected) {
+		t.Fatalf("expect %v, actual %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expect %v, actual %v", expected, actual)
+		}
+		rank, _ := zset.GetRank(expected[i], false)
+		if rank != int64(i) {
+			t.Errorf("expect rank of %s to be %d, actual %d", expected[i], i, rank)
+		}
+	}
+
+	// moving a member keeps others in order
+	zset.Add("c", 2)
+	if rank, _ := zset.GetRank("c", false); rank != 5 {
+		t.Errorf("expect rank of c to be 5, actual %d", rank)
+	}
+	if rank, _ := zset.GetRank("d", false); rank != 3 {
+		t.Errorf("expect rank of d to be 3, actual %d", rank)
+	}
+	if !zset.Remove("b") || zset.Remove("b") {
+		t.Error("remove should return true only once")
+	}
+	if rank, _ := zset.GetRank("d", false); rank != 2 {
+		t.Errorf("expect rank of d to be 2, actual %d", rank)
+	}
+	if zset.Exists("b") || zset.Len() != 5 {
+		t.Error("removed member should not exist")
+	}
+}
+
+func TestScan(t *testing.T) {
+	zset := Make()
+	size := 1000
+	for i := 0; i < size; i++ {
+		zset.Add(strconv.Itoa(i), float64(i))
+	}
+	seen := make(map[string]float64)
+	var cursor uint64
+	for {
+		elements, next := zset.Scan(cursor, 10)
+		for _, element := range elements {
+			seen[element.Member] = element.Score
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != size {
+		t.Fatalf("expect %d members, actual %d", size, len(seen))
+	}
+	for i := 0; i < size; i++ {
+		if score, ok := seen[strconv.Itoa(i)]; !ok || score != float64(i) {
+			t.Errorf("expect member %d with score %d, actual %v", i, i, score)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package stream
+
+import "sort"
+
+// PendingEntry is an entry delivered to a consumer but not acknowledged yet
+type PendingEntry struct {
+	ID            ID
+	Consumer      string
+	DeliveryTime  int64 // unix timestamp in milliseconds
+	DeliveryCount uint64
+}
+
+// Consumer is a member of consumer group
+type Consumer struct {
+	Name       string
+	SeenTime   int64 // last time the consumer attempted an interaction, unix milliseconds
+	ActiveTime int64 // last time the consumer read or claimed an entry, unix milliseconds, -1 means never
+	pending    map[ID]*PendingEntry
+}
+
+// PendingCount returns the number of pending entries owned by the consumer
+func (c *Consumer) PendingCount() int {
+	return len(c.pending)
+}
+
+// Group is a consumer group of stream
+type Group struct {
+	Name      string
+	LastID    ID // the last delivered ID
+	consumers map[string]*Consumer
+	pel       map[ID]*PendingEntry
+	pelIDs    []ID // ordered IDs of pel
+}
+
+// CreateGroup creates a consumer group, returns nil if the group already exists
+func (s *Stream) CreateGroup(name string, lastID ID) *Group {
+	if _, ok := s.groups[name]; ok {
+		return nil
+	}
+	group := &Group{
+		Name:      name,
+		LastID:    lastID,
+		consumers: make(map[string]*Consumer),
+		pel:       make(map[ID]*PendingEntry),
+	}
+	s.groups[name] = group
+	return group
+}
+
+// GetGroup returns the consumer group with the given name
+func (s *Stream) GetGroup(name string) *Group {
+	return s.groups[name]
+}
+
+// DestroyGroup removes the consumer group, returns whether the group existed
+func (s *Stream) DestroyGroup(name string) bool {
+	_, ok := s.groups[name]
+	delete(s.groups, name)
+	return ok
+}
+
+// Groups returns all consumer groups ordered by name
+func (s *Stream) Groups() []*Group {
+	groups := make([]*Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// EntriesRead returns the number of entries the group has read, false if it cannot be known because of deletions
+func (s *Stream) EntriesRead(group *Group) (uint64, bool) {
+	if s.lastID.Compare(group.LastID) <= 0 {
+		return s.entriesAdded, true
+	}
+	if group.LastID.Less(s.maxDeletedID) {
+		// some entries not read yet were deleted, can not tell how many were read
+		return 0, false
+	}
+	unread := len(s.entries) - s.CountUntil(group.LastID)
+	return s.entriesAdded - uint64(unread), true
+}
+
+// GetConsumer returns the consumer with the given name
+func (g *Group) GetConsumer(name string) *Consumer {
+	return g.consumers[name]
+}
+
+// CreateConsumer creates a consumer if it does not exist, the second return value is true if created
+func (g *Group) CreateConsumer(name string, now int64) (*Consumer, bool) {
+	consumer, ok := g.consumers[name]
+	if ok {
+		return consumer, false
+	}
+	consumer = &Consumer{
+		Name:       name,
+		SeenTime:   now,
+		ActiveTime: -1,
+		pending:    make(map[ID]*PendingEntry),
+	}
+	g.consumers[name] = consumer
+	return consumer, true
+}
+
+// DeleteConsumer removes the consumer and its pending entries, returns the number of pending entries it had
+func (g *Group) DeleteConsumer(name string) (int, bool) {
+	consumer, ok := g.consumers[name]
+	if !ok {
+		return 0, false
+	}
+	count := len(consumer.pending)
+	for id := range consumer.pending {
+		g.removePending(id)
+	}
+	delete(g.consumers, name)
+	return count, true
+}
+
+// Consumers returns all consumers ordered by name
+func (g *Group) Consumers() []*Consumer {
+	consumers := make([]*Consumer, 0, len(g.consumers))
+	for _, consumer := range g.consumers {
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers
+}
+
+func (g *Group) searchPending(id ID) int {
+	return sort.Search(len(g.pelIDs), func(i int) bool {
+		return !g.pelIDs[i].Less(id)
+	})
+}
+
+// GetPending returns the pending entry with the given ID
+func (g *Group) GetPending(id ID) *PendingEntry {
+	return g.pel[id]
+}
+
+// PendingCount returns the number of pending entries of the group
+func (g *Group) PendingCount() int {
+	return len(g.pelIDs)
+}
+
+// SetPending delivers the entry to consumer, it creates the pending entry or changes its owner
+func (g *Group) SetPending(id ID, consumer *Consumer, deliveryTime int64, deliveryCount uint64) *PendingEntry {
+	pending, ok := g.pel[id]
+	if !ok {
+		pending = &PendingEntry{ID: id}
+		g.pel[id] = pending
+		i := g.searchPending(id)
+		g.pelIDs = append(g.pelIDs, ID{})
+		copy(g.pelIDs[i+1:], g.pelIDs[i:])
+		g.pelIDs[i] = id
+	} else if owner, ok := g.consumers[pending.Consumer]; ok {
+		delete(owner.pending, id)
+	}
+	pending.Consumer = consumer.Name
+	pending.DeliveryTime = deliveryTime
+	pending.DeliveryCount = deliveryCount
+	consumer.pending[id] = pending
+	return pending
+}
+
+// Ack acknowledges the pending entry, returns false if it is not pending
+func (g *Group) Ack(id ID) bool {
+	if _, ok := g.pel[id]; !ok {
+		return false
+	}
+	g.removePending(id)
+	return true
+}
+
+func (g *Group) removePending(id ID) {
+	pending, ok := g.pel[id]
+	if !ok {
+		return
+	}
+	delete(g.pel, id)
+	if owner, ok := g.consumers[pending.Consumer]; ok {
+		delete(owner.pending, id)
+	}
+	i := g.searchPending(id)
+	if i < len(g.pelIDs) && g.pelIDs[i] == id {
+		g.pelIDs = append(g.pelIDs[:i], g.pelIDs[i+1:]...)
+	}
+}
+
+// PendingRange returns at most count pending entries whose ID within [start, end] in order,
+// consumer filters entries by owner if it is not empty
+func (g *Group) PendingRange(start, end ID, count int, consumer string) []*PendingEntry {
+	result := make([]*PendingEntry, 0)
+	for i := g.searchPending(start); i < len(g.pelIDs) && len(result) < count; i++ {
+		id := g.pelIDs[i]
+		if end.Less(id) {
+			break
+		}
+		pending := g.pel[id]
+		if consumer != "" && pending.Consumer != consumer {
+			continue
+		}
+		result = append(result, pending)
+	}
+	return result
+}
+
+// ConsumerPendingAfter returns at most count pending entries of consumer whose ID is greater than the given one
+func (g *Group) ConsumerPendingAfter(consumer *Consumer, id ID, count int) []*PendingEntry {
+	result := make([]*PendingEntry, 0)
+	start, ok := id.Incr()
+	if !ok {
+		return result
+	}
+	for i := g.searchPending(start); i < len(g.pelIDs); i++ {
+		if count > 0 && len(result) >= count {
+			break
+		}
+		pending := g.pel[g.pelIDs[i]]
+		if pending.Consumer == consumer.Name {
+			result = append(result, pending)
+		}
+	}
+	return result
+}
+
+// Clone returns a deep copy of the stream, consumer groups and their pending entries included.
+// Entries are shared since they are never modified after added.
+func (s *Stream) Clone() *Stream {
+	clone := &Stream{
+		entries:      append([]*Entry(nil), s.entries...),
+		lastID:       s.lastID,
+		maxDeletedID: s.maxDeletedID,
+		entriesAdded: s.entriesAdded,
+		groups:       make(map[string]*Group, len(s.groups)),
+	}
+	for name, group := range s.groups {
+		groupClone := &Group{
+			Name:      group.Name,
+			LastID:    group.LastID,
+			consumers: make(map[string]*Consumer, len(group.consumers)),
+			pel:       make(map[ID]*PendingEntry, len(group.pel)),
+			pelIDs:    append([]ID(nil), group.pelIDs...),
+		}
+		for consumerName, consumer := range group.consumers {
+			groupClone.consumers[consumerName] = &Consumer{
+				Name:       consumer.Name,
+				SeenTime:   consumer.SeenTime,
+				ActiveTime: consumer.ActiveTime,
+				pending:    make(map[ID]*PendingEntry, len(consumer.pending)),
+			}
+		}
+		for id, pending := range group.pel {
+			pendingClone := *pending
+			groupClone.pel[id] = &pendingClone
+			if owner, ok := groupClone.consumers[pending.Consumer]; ok {
+				owner.pending[id] = &pendingClone
+			}
+		}
+		clone.groups[name] = groupClone
+	}
+	return clone
+}
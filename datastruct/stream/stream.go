@@ -0,0 +1,344 @@
+package stream
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ID identifies an entry of stream, it is composed of a millisecond timestamp and a sequence number
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// MinID and MaxID are the smallest and the greatest possible ID
+var (
+	MinID = ID{Ms: 0, Seq: 0}
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+// ErrInvalidID is returned when parsing a malformed ID
+var ErrInvalidID = errors.New("ERR Invalid stream ID specified as stream command argument")
+
+// String formats ID as `ms-seq`
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or 1 if id is less than, equal to or greater than another
+func (id ID) Compare(another ID) int {
+	if id.Ms != another.Ms {
+		if id.Ms < another.Ms {
+			return -1
+		}
+		return 1
+	}
+	if id.Seq != another.Seq {
+		if id.Seq < another.Seq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// Less returns whether id is less than another
+func (id ID) Less(another ID) bool {
+	return id.Compare(another) < 0
+}
+
+// IsZero returns whether id is 0-0
+func (id ID) IsZero() bool {
+	return id.Ms == 0 && id.Seq == 0
+}
+
+// Incr returns the next ID, the second return value is false on overflow
+func (id ID) Incr() (ID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return ID{Ms: id.Ms + 1, Seq: 0}, true
+	}
+	return id, false
+}
+
+// Decr returns the previous ID, the second return value is false on underflow
+func (id ID) Decr() (ID, bool) {
+	if id.Seq > 0 {
+		return ID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// ParseID parses `ms-seq` or `ms`, missingSeq is used as sequence number when it is omitted
+func ParseID(s string, missingSeq uint64) (ID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	if !hasSeq {
+		return ID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	return ID{Ms: ms, Seq: seq}, nil
+}
+
+// Entry is a record of stream
+type Entry struct {
+	ID     ID
+	Fields [][]byte // field-value pairs
+}
+
+// Stream is an append-only log of entries ordered by ID
+type Stream struct {
+	entries      []*Entry // ordered by ID
+	lastID       ID       // the greatest ID ever added, deleted entries included
+	maxDeletedID ID
+	entriesAdded uint64
+	groups       map[string]*Group
+}
+
+// Make creates an empty stream
+func Make() *Stream {
+	return &Stream{
+		groups: make(map[string]*Group),
+	}
+}
+
+// Len returns the number of entries
+func (s *Stream) Len() int {
+	return len(s.entries)
+}
+
+// LastID returns the greatest ID ever added
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+// SetLastID forces the last ID, it is used by XSETID-like operations and must not be less than the top entry
+func (s *Stream) SetLastID(id ID) {
+	s.lastID = id
+}
+
+// MaxDeletedID returns the greatest ID of deleted entries
+func (s *Stream) MaxDeletedID() ID {
+	return s.maxDeletedID
+}
+
+// EntriesAdded returns the number of entries ever added
+func (s *Stream) EntriesAdded() uint64 {
+	return s.entriesAdded
+}
+
+// SetMeta restores the metadata of a deserialized stream, entries must have been added
+func (s *Stream) SetMeta(lastID ID, maxDeletedID ID, entriesAdded uint64) {
+	s.lastID = lastID
+	s.maxDeletedID = maxDeletedID
+	s.entriesAdded = entriesAdded
+}
+
+// First returns the first entry, nil if stream is empty
+func (s *Stream) First() *Entry {
+	if len(s.entries) == 0 {
+		return nil
+	}
+	return s.entries[0]
+}
+
+// Last returns the last entry, nil if stream is empty
+func (s *Stream) Last() *Entry {
+	if len(s.entries) == 0 {
+		return nil
+	}
+	return s.entries[len(s.entries)-1]
+}
+
+// NextID generates an ID greater than the last one, based on the given millisecond timestamp
+func (s *Stream) NextID(ms uint64) (ID, bool) {
+	if ms > s.lastID.Ms {
+		return ID{Ms: ms, Seq: 0}, true
+	}
+	return s.lastID.Incr()
+}
+
+// NextSeqID generates an ID with the given millisecond part, returns false if it cannot be greater than the last one
+func (s *Stream) NextSeqID(ms uint64) (ID, bool) {
+	if ms > s.lastID.Ms {
+		if ms == 0 {
+			return ID{Ms: 0, Seq: 1}, true
+		}
+		return ID{Ms: ms, Seq: 0}, true
+	}
+	if ms < s.lastID.Ms || s.lastID.Seq == math.MaxUint64 {
+		return ID{}, false
+	}
+	if ms == 0 && s.lastID.IsZero() {
+		return ID{Ms: 0, Seq: 1}, true
+	}
+	return ID{Ms: ms, Seq: s.lastID.Seq + 1}, true
+}
+
+// Add appends an entry, the ID must be greater than the last ID
+func (s *Stream) Add(id ID, fields [][]byte) bool {
+	if !s.lastID.Less(id) {
+		return false
+	}
+	s.entries = append(s.entries, &Entry{
+		ID:     id,
+		Fields: fields,
+	})
+	s.lastID = id
+	s.entriesAdded++
+	return true
+}
+
+// search returns the index of the first entry whose ID >= id
+func (s *Stream) search(id ID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return !s.entries[i].ID.Less(id)
+	})
+}
+
+// Get returns the entry with the given ID
+func (s *Stream) Get(id ID) *Entry {
+	i := s.search(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		return s.entries[i]
+	}
+	return nil
+}
+
+// Range returns at most count entries whose ID within [start, end], count <= 0 means no limit
+func (s *Stream) Range(start, end ID, count int, reverse bool) []*Entry {
+	if end.Less(start) {
+		return nil
+	}
+	begin := s.search(start)
+	stop := s.search(end) // exclusive
+	if stop < len(s.entries) && s.entries[stop].ID == end {
+		stop++
+	}
+	if begin >= stop {
+		return nil
+	}
+	size := stop - begin
+	if count > 0 && count < size {
+		size = count
+	}
+	result := make([]*Entry, 0, size)
+	if reverse {
+		for i := stop - 1; i >= begin && len(result) < size; i-- {
+			result = append(result, s.entries[i])
+		}
+	} else {
+		for i := begin; i < stop && len(result) < size; i++ {
+			result = append(result, s.entries[i])
+		}
+	}
+	return result
+}
+
+// After returns at most count entries whose ID is greater than the given one
+func (s *Stream) After(id ID, count int) []*Entry {
+	start, ok := id.Incr()
+	if !ok {
+		return nil
+	}
+	return s.Range(start, MaxID, count, false)
+}
+
+// CountUntil returns the number of entries whose ID <= id
+func (s *Stream) CountUntil(id ID) int {
+	i := s.search(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		i++
+	}
+	return i
+}
+
+// Delete removes entries with the given IDs, returns the number of removed entries
+func (s *Stream) Delete(ids ...ID) int {
+	deleted := 0
+	for _, id := range ids {
+		i := s.search(id)
+		if i >= len(s.entries) || s.entries[i].ID != id {
+			continue
+		}
+		s.entries[i] = nil // help gc
+		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+		if s.maxDeletedID.Less(id) {
+			s.maxDeletedID = id
+		}
+		deleted++
+	}
+	return deleted
+}
+
+// compactThreshold is the capacity under which the backing array of entries is never compacted
+const compactThreshold = 64
+
+// removeHead removes the first n entries
+func (s *Stream) removeHead(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if n > len(s.entries) {
+		n = len(s.entries)
+	}
+	lastRemoved := s.entries[n-1].ID
+	if s.maxDeletedID.Less(lastRemoved) {
+		s.maxDeletedID = lastRemoved
+	}
+	for i := 0; i < n; i++ {
+		s.entries[i] = nil // help gc
+	}
+	s.entries = s.entries[n:]
+	// reslicing leaves the evicted head in the backing array, appending reallocates it sooner or later,
+	// compact here only when the stream shrinks a lot so that trimming stays amortized O(n)
+	if cap(s.entries) > compactThreshold && len(s.entries) < cap(s.entries)/4 {
+		remain := make([]*Entry, len(s.entries))
+		copy(remain, s.entries)
+		s.entries = remain
+	}
+	return n
+}
+
+// TrimMaxLen evicts the oldest entries until the stream has at most maxLen entries,
+// limit > 0 bounds the number of evicted entries. It returns the number of evicted entries.
+func (s *Stream) TrimMaxLen(maxLen int, limit int) int {
+	n := len(s.entries) - maxLen
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return s.removeHead(n)
+}
+
+// TrimMinID evicts entries whose ID is less than minID,
+// limit > 0 bounds the number of evicted entries. It returns the number of evicted entries.
+func (s *Stream) TrimMinID(minID ID, limit int) int {
+	n := s.search(minID)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return s.removeHead(n)
+}
+
+// ForEach visits entries in order until consumer returns false
+func (s *Stream) ForEach(consumer func(entry *Entry) bool) {
+	for _, entry := range s.entries {
+		if !consumer(entry) {
+			break
+		}
+	}
+}
@@ -18,4 +18,8 @@ type Database interface {
 type DataEntity struct {
 	Data       interface{}
 	ExpireTime int64 // Unix timestamp in milliseconds, 0 means no expiration
+	// LastAccess and Frequency are updated by every read, so they are accessed atomically
+	LastAccess int64  // Unix timestamp in milliseconds of the last access, used by LRU
+	Frequency  uint32 // logarithmic access counter (0-255) which decays over time, used by LFU
+	MemorySize int64  // estimated bytes of the key and value, accounted in the memory usage of db
 }
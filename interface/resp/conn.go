@@ -5,4 +5,5 @@ type Connection interface {
 	Write([]byte) error
 	GetDBIndex() int //客户端连接的DB
 	SelectDB(int)    //选择DB
+	IsClosed() bool  //连接是否已经关闭
 }
@@ -0,0 +1,230 @@
+// Package geohash encodes coordinates into 52 bit interleaved geohash, compatible with redis geo commands
+package geohash
+
+import "math"
+
+const (
+	// MaxStep is the precision of geohash stored as zset score, each step takes one bit of latitude and longitude
+	MaxStep = 26
+
+	// the same limits as EPSG:900913 / EPSG:3785 / OSGEO:41001
+	MinLatitude  = -85.05112878
+	MaxLatitude  = 85.05112878
+	MinLongitude = -180.0
+	MaxLongitude = 180.0
+
+	// EarthRadius is the earth radius used by redis in meters
+	EarthRadius = 6372797.560856
+	mercatorMax = 20037726.37
+
+	base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// Cell is an area of geohash with the given step
+type Cell struct {
+	Bits uint64
+	Step uint
+}
+
+// Area is the range of latitude and longitude covered by a cell
+type Area struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// interleave puts bits of x in even positions and bits of y in odd positions
+func interleave(x, y uint32) uint64 {
+	var result uint64
+	for i := uint(0); i < 32; i++ {
+		result |= uint64(x>>i&1) << (2 * i)
+		result |= uint64(y>>i&1) << (2*i + 1)
+	}
+	return result
+}
+
+// deinterleave reverses interleave
+func deinterleave(bits uint64) (x, y uint32) {
+	for i := uint(0); i < 32; i++ {
+		x |= uint32(bits>>(2*i)&1) << i
+		y |= uint32(bits>>(2*i+1)&1) << i
+	}
+	return x, y
+}
+
+// encodeInRange encodes coordinate within the given range
+func encodeInRange(lat, lng float64, minLat, maxLat, minLng, maxLng float64, step uint) Cell {
+	latOffset := (lat - minLat) / (maxLat - minLat)
+	lngOffset := (lng - minLng) / (maxLng - minLng)
+	latOffset *= float64(uint64(1) << step)
+	lngOffset *= float64(uint64(1) << step)
+	return Cell{
+		Bits: interleave(uint32(latOffset), uint32(lngOffset)),
+		Step: step,
+	}
+}
+
+// EncodeWithStep returns the cell containing the coordinate
+func EncodeWithStep(lat, lng float64, step uint) Cell {
+	return encodeInRange(lat, lng, MinLatitude, MaxLatitude, MinLongitude, MaxLongitude, step)
+}
+
+// Encode returns the 52 bit geohash of coordinate
+func Encode(lat, lng float64) uint64 {
+	return EncodeWithStep(lat, lng, MaxStep).Bits
+}
+
+// Area returns the range covered by cell
+func (cell Cell) Area() Area {
+	latIdx, lngIdx := deinterleave(cell.Bits)
+	latScale := MaxLatitude - MinLatitude
+	lngScale := MaxLongitude - MinLongitude
+	size := float64(uint64(1) << cell.Step)
+	return Area{
+		MinLat: MinLatitude + float64(latIdx)/size*latScale,
+		MaxLat: MinLatitude + float64(latIdx+1)/size*latScale,
+		MinLng: MinLongitude + float64(lngIdx)/size*lngScale,
+		MaxLng: MinLongitude + float64(lngIdx+1)/size*lngScale,
+	}
+}
+
+// ScoreRange returns the zset score range [min, max) of members in cell
+func (cell Cell) ScoreRange() (uint64, uint64) {
+	shift := 2 * (MaxStep - cell.Step)
+	return cell.Bits << shift, (cell.Bits + 1) << shift
+}
+
+// Neighbors returns the cell itself and its 8 neighbors, cells beyond the poles are omitted
+func (cell Cell) Neighbors() []Cell {
+	latIdx, lngIdx := deinterleave(cell.Bits)
+	size := int64(1) << cell.Step
+	cells := make([]Cell, 0, 9)
+	seen := make(map[uint64]struct{}, 9)
+	for dLat := int64(-1); dLat <= 1; dLat++ {
+		lat := int64(latIdx) + dLat
+		if lat < 0 || lat >= size {
+			continue
+		}
+		for dLng := int64(-1); dLng <= 1; dLng++ {
+			lng := (int64(lngIdx) + dLng + size) % size // longitude wraps around
+			bits := interleave(uint32(lat), uint32(lng))
+			if _, ok := seen[bits]; ok {
+				continue
+			}
+			seen[bits] = struct{}{}
+			cells = append(cells, Cell{Bits: bits, Step: cell.Step})
+		}
+	}
+	return cells
+}
+
+// Decode returns the center of the area of 52 bit geohash
+func Decode(hash uint64) (lat, lng float64) {
+	area := Cell{Bits: hash, Step: MaxStep}.Area()
+	lat = (area.MinLat + area.MaxLat) / 2
+	lng = (area.MinLng + area.MaxLng) / 2
+	lat = math.Max(MinLatitude, math.Min(MaxLatitude, lat))
+	lng = math.Max(MinLongitude, math.Min(MaxLongitude, lng))
+	return lat, lng
+}
+
+// ToString returns the standard 11 characters geohash string, which uses [-90, 90] as latitude range
+func ToString(lat, lng float64) string {
+	bits := encodeInRange(lat, lng, -90, 90, MinLongitude, MaxLongitude, MaxStep).Bits
+	buf := make([]byte, 11)
+	for i := 0; i < 11; i++ {
+		var idx uint64
+		if i < 10 {
+			idx = (bits >> (52 - uint((i+1)*5))) & 0x1f
+		}
+		// the last character is 0 since there are only 52 bits
+		buf[i] = base32[idx]
+	}
+	return string(buf)
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Distance returns the distance in meters between two coordinates by haversine formula
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	lat1r, lng1r := degToRad(lat1), degToRad(lng1)
+	lat2r, lng2r := degToRad(lat2), degToRad(lng2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lng2r - lng1r) / 2)
+	return 2 * EarthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+// LatDistance returns the distance in meters between two latitudes
+func LatDistance(lat1, lat2 float64) float64 {
+	return EarthRadius * math.Abs(degToRad(lat2)-degToRad(lat1))
+}
+
+// EstimateStep returns the step whose cell is large enough to cover the radius around the latitude
+func EstimateStep(radius float64, lat float64) uint {
+	if radius == 0 {
+		return MaxStep
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2 // make sure range is included in most of the base cases
+	// wider range towards the poles
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	if step > MaxStep {
+		step = MaxStep
+	}
+	return uint(step)
+}
+
+// BoundingBox returns the area which contains the rectangle of width and height in meters centered on the coordinate
+func BoundingBox(lat, lng float64, width, height float64) Area {
+	latDelta := radToDeg(height / 2 / EarthRadius)
+	lngDeltaTop := radToDeg(width / 2 / EarthRadius / math.Cos(degToRad(lat+latDelta)))
+	lngDeltaBottom := radToDeg(width / 2 / EarthRadius / math.Cos(degToRad(lat-latDelta)))
+	lngDelta := lngDeltaTop
+	if lat < 0 {
+		// the bottom edge is the widest in the southern hemisphere
+		lngDelta = lngDeltaBottom
+	}
+	return Area{
+		MinLat: lat - latDelta,
+		MaxLat: lat + latDelta,
+		MinLng: lng - lngDelta,
+		MaxLng: lng + lngDelta,
+	}
+}
+
+// CoveringCells returns cells which cover the rectangle of width and height in meters centered on the coordinate
+func CoveringCells(lat, lng float64, width, height float64) []Cell {
+	bounds := BoundingBox(lat, lng, width, height)
+	radius := math.Sqrt(width*width+height*height) / 2
+	step := EstimateStep(radius, lat)
+	cell := EncodeWithStep(lat, lng, step)
+	// the estimated step is too large if the search area is near an edge of the cell,
+	// so that the neighbor cells cannot cover everything
+	if step > 1 {
+		area := cell.Area()
+		cellHeight := area.MaxLat - area.MinLat
+		cellWidth := area.MaxLng - area.MinLng
+		if area.MaxLat+cellHeight < bounds.MaxLat || area.MinLat-cellHeight > bounds.MinLat ||
+			area.MaxLng+cellWidth < bounds.MaxLng || area.MinLng-cellWidth > bounds.MinLng {
+			cell = EncodeWithStep(lat, lng, step-1)
+		}
+	}
+	return cell.Neighbors()
+}
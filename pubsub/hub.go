@@ -0,0 +1,188 @@
+// Package pubsub implements redis publish/subscribe
+package pubsub
+
+import (
+	"goRedis/interface/resp"
+	"goRedis/lib/wildcard"
+	"sync"
+)
+
+// Hub 保存所有频道和模式的订阅关系
+type Hub struct {
+	mu sync.RWMutex
+	// channel -> 订阅该频道的客户端
+	channels map[string]map[resp.Connection]struct{}
+	// pattern -> 订阅该模式的客户端
+	patterns map[string]*patternSubscribers
+	// 客户端 -> 它订阅的频道和模式
+	clients map[resp.Connection]*subscription
+	// 客户端 -> *sync.Mutex，确认和消息都在hub锁外写入，写锁保证订阅确认先于消息到达。
+	// 加锁顺序是先写锁后hub锁，持有hub锁时不能等待写锁
+	writers sync.Map
+}
+
+type patternSubscribers struct {
+	pattern     *wildcard.Pattern
+	subscribers map[resp.Connection]struct{}
+}
+
+type subscription struct {
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// count 返回客户端订阅的频道和模式总数
+func (s *subscription) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+// MakeHub 创建Hub
+func MakeHub() *Hub {
+	return &Hub{
+		channels: make(map[string]map[resp.Connection]struct{}),
+		patterns: make(map[string]*patternSubscribers),
+		clients:  make(map[resp.Connection]*subscription),
+	}
+}
+
+// writer 返回客户端的写锁，不存在时创建
+func (hub *Hub) writer(c resp.Connection) *sync.Mutex {
+	if w, ok := hub.writers.Load(c); ok {
+		return w.(*sync.Mutex)
+	}
+	w, _ := hub.writers.LoadOrStore(c, &sync.Mutex{})
+	return w.(*sync.Mutex)
+}
+
+// getSubscriptionLocked 返回客户端的订阅，不存在时创建
+func (hub *Hub) getSubscriptionLocked(c resp.Connection) *subscription {
+	sub, ok := hub.clients[c]
+	if !ok {
+		sub = &subscription{
+			channels: make(map[string]struct{}),
+			patterns: make(map[string]struct{}),
+		}
+		hub.clients[c] = sub
+	}
+	return sub
+}
+
+// releaseLocked 客户端不再订阅任何频道时删除它的记录
+func (hub *Hub) releaseLocked(c resp.Connection, sub *subscription) {
+	if sub.count() == 0 {
+		delete(hub.clients, c)
+	}
+}
+
+func (hub *Hub) subscribeLocked(c resp.Connection, channel string) {
+	subscribers, ok := hub.channels[channel]
+	if !ok {
+		subscribers = make(map[resp.Connection]struct{})
+		hub.channels[channel] = subscribers
+	}
+	subscribers[c] = struct{}{}
+	hub.getSubscriptionLocked(c).channels[channel] = struct{}{}
+}
+
+func (hub *Hub) unsubscribeLocked(c resp.Connection, channel string) {
+	if subscribers, ok := hub.channels[channel]; ok {
+		delete(subscribers, c)
+		if len(subscribers) == 0 {
+			delete(hub.channels, channel)
+		}
+	}
+	if sub, ok := hub.clients[c]; ok {
+		delete(sub.channels, channel)
+	}
+}
+
+func (hub *Hub) psubscribeLocked(c resp.Connection, pattern string) {
+	subscribers, ok := hub.patterns[pattern]
+	if !ok {
+		subscribers = &patternSubscribers{
+			pattern:     wildcard.CompilePattern(pattern),
+			subscribers: make(map[resp.Connection]struct{}),
+		}
+		hub.patterns[pattern] = subscribers
+	}
+	subscribers.subscribers[c] = struct{}{}
+	hub.getSubscriptionLocked(c).patterns[pattern] = struct{}{}
+}
+
+func (hub *Hub) punsubscribeLocked(c resp.Connection, pattern string) {
+	if subscribers, ok := hub.patterns[pattern]; ok {
+		delete(subscribers.subscribers, c)
+		if len(subscribers.subscribers) == 0 {
+			delete(hub.patterns, pattern)
+		}
+	}
+	if sub, ok := hub.clients[c]; ok {
+		delete(sub.patterns, pattern)
+	}
+}
+
+// HasSubscribers 返回是否有客户端订阅了任意频道或模式，没有订阅者时可以跳过消息的构造
+func (hub *Hub) HasSubscribers() bool {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return len(hub.clients) > 0
+}
+
+// delivery 是待发送给一个客户端的消息
+type delivery struct {
+	conn resp.Connection
+	msg  []byte
+}
+
+// Publish 发送消息给订阅了频道或匹配的模式的客户端，返回收到消息的客户端数量。
+// 在锁内复制订阅者，在锁外写入，慢客户端不会阻塞订阅和其他发布
+func (hub *Hub) Publish(channel string, message []byte) int {
+	var deliveries []delivery
+	hub.mu.RLock()
+	if subscribers, ok := hub.channels[channel]; ok {
+		msg := makeMessageReply(channel, message).ToBytes()
+		for c := range subscribers {
+			deliveries = append(deliveries, delivery{conn: c, msg: msg})
+		}
+	}
+	for pattern, subscribers := range hub.patterns {
+		if !subscribers.pattern.IsMatch(channel) {
+			continue
+		}
+		msg := makePMessageReply(pattern, channel, message).ToBytes()
+		for c := range subscribers.subscribers {
+			deliveries = append(deliveries, delivery{conn: c, msg: msg})
+		}
+	}
+	hub.mu.RUnlock()
+
+	for _, d := range deliveries {
+		w, ok := hub.writers.Load(d.conn)
+		if !ok {
+			// the client has been closed
+			continue
+		}
+		w.(*sync.Mutex).Lock()
+		_ = d.conn.Write(d.msg)
+		w.(*sync.Mutex).Unlock()
+	}
+	return len(deliveries)
+}
+
+// UnsubscribeAll 取消客户端的所有订阅，在客户端断开连接时调用
+func (hub *Hub) UnsubscribeAll(c resp.Connection) {
+	hub.writers.Delete(c)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	sub, ok := hub.clients[c]
+	if !ok {
+		return
+	}
+	for channel := range sub.channels {
+		hub.unsubscribeLocked(c, channel)
+	}
+	for pattern := range sub.patterns {
+		hub.punsubscribeLocked(c, pattern)
+	}
+	delete(hub.clients, c)
+}
@@ -0,0 +1,189 @@
+package pubsub
+
+import (
+	"goRedis/interface/resp"
+	"goRedis/lib/wildcard"
+	"goRedis/resp/reply"
+	"sort"
+	"strings"
+)
+
+const (
+	subscribeKind    = "subscribe"
+	unsubscribeKind  = "unsubscribe"
+	psubscribeKind   = "psubscribe"
+	punsubscribeKind = "punsubscribe"
+	messageKind      = "message"
+	pmessageKind     = "pmessage"
+)
+
+// makeAckReply 订阅或取消订阅的回复，count为客户端当前订阅的频道和模式总数
+func makeAckReply(kind string, channel []byte, count int) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(kind)),
+		reply.MakeBulkReply(channel),
+		reply.MakeIntReply(int64(count)),
+	})
+}
+
+func makeMessageReply(channel string, message []byte) resp.Reply {
+	return reply.MakeMultiBulkReply([][]byte{
+		[]byte(messageKind),
+		[]byte(channel),
+		message,
+	})
+}
+
+func makePMessageReply(pattern string, channel string, message []byte) resp.Reply {
+	return reply.MakeMultiBulkReply([][]byte{
+		[]byte(pmessageKind),
+		[]byte(pattern),
+		[]byte(channel),
+		message,
+	})
+}
+
+// writeAcks 在hub锁外写入确认，调用者持有客户端的写锁
+func writeAcks(c resp.Connection, acks []resp.Reply) {
+	for _, ack := range acks {
+		_ = c.Write(ack.ToBytes())
+	}
+}
+
+// Subscribe 订阅频道，每个频道回复一条确认
+func Subscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	// publishers wait for the writer, so that acks always arrive before messages of the channels
+	w := hub.writer(c)
+	w.Lock()
+	defer w.Unlock()
+	acks := make([]resp.Reply, 0, len(args))
+	hub.mu.Lock()
+	for _, arg := range args {
+		hub.subscribeLocked(c, string(arg))
+		acks = append(acks, makeAckReply(subscribeKind, arg, hub.clients[c].count()))
+	}
+	hub.mu.Unlock()
+	writeAcks(c, acks)
+	return &reply.NoReply{}
+}
+
+// UnSubscribe 取消订阅频道，没有指定频道时取消所有频道
+func UnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	w := hub.writer(c)
+	w.Lock()
+	defer w.Unlock()
+	hub.mu.Lock()
+	sub := hub.getSubscriptionLocked(c)
+	channels := args
+	if len(channels) == 0 {
+		for channel := range sub.channels {
+			channels = append(channels, []byte(channel))
+		}
+	}
+	acks := make([]resp.Reply, 0, len(channels))
+	for _, arg := range channels {
+		hub.unsubscribeLocked(c, string(arg))
+		acks = append(acks, makeAckReply(unsubscribeKind, arg, sub.count()))
+	}
+	count := sub.count()
+	hub.releaseLocked(c, sub)
+	hub.mu.Unlock()
+	if len(acks) == 0 {
+		return makeAckReply(unsubscribeKind, nil, count)
+	}
+	writeAcks(c, acks)
+	return &reply.NoReply{}
+}
+
+// PSubscribe 订阅模式，每个模式回复一条确认
+func PSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	w := hub.writer(c)
+	w.Lock()
+	defer w.Unlock()
+	acks := make([]resp.Reply, 0, len(args))
+	hub.mu.Lock()
+	for _, arg := range args {
+		hub.psubscribeLocked(c, string(arg))
+		acks = append(acks, makeAckReply(psubscribeKind, arg, hub.clients[c].count()))
+	}
+	hub.mu.Unlock()
+	writeAcks(c, acks)
+	return &reply.NoReply{}
+}
+
+// PUnSubscribe 取消订阅模式，没有指定模式时取消所有模式
+func PUnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	w := hub.writer(c)
+	w.Lock()
+	defer w.Unlock()
+	hub.mu.Lock()
+	sub := hub.getSubscriptionLocked(c)
+	patterns := args
+	if len(patterns) == 0 {
+		for pattern := range sub.patterns {
+			patterns = append(patterns, []byte(pattern))
+		}
+	}
+	acks := make([]resp.Reply, 0, len(patterns))
+	for _, arg := range patterns {
+		hub.punsubscribeLocked(c, string(arg))
+		acks = append(acks, makeAckReply(punsubscribeKind, arg, sub.count()))
+	}
+	count := sub.count()
+	hub.releaseLocked(c, sub)
+	hub.mu.Unlock()
+	if len(acks) == 0 {
+		return makeAckReply(punsubscribeKind, nil, count)
+	}
+	writeAcks(c, acks)
+	return &reply.NoReply{}
+}
+
+// Publish 发布消息，返回收到消息的客户端数量
+func Publish(hub *Hub, args [][]byte) resp.Reply {
+	if len(args) != 2 {
+		return reply.MakeArgNumErrReply("publish")
+	}
+	received := hub.Publish(string(args[0]), args[1])
+	return reply.MakeIntReply(int64(received))
+}
+
+// PubSub 查看订阅状态: PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT
+func PubSub(hub *Hub, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return reply.MakeArgNumErrReply("pubsub")
+	}
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	subCmd := strings.ToUpper(string(args[0]))
+	switch {
+	case subCmd == "CHANNELS" && len(args) <= 2:
+		var pattern *wildcard.Pattern
+		if len(args) == 2 {
+			pattern = wildcard.CompilePattern(string(args[1]))
+		}
+		channels := make([]string, 0, len(hub.channels))
+		for channel := range hub.channels {
+			if pattern == nil || pattern.IsMatch(channel) {
+				channels = append(channels, channel)
+			}
+		}
+		sort.Strings(channels)
+		result := make([][]byte, len(channels))
+		for i, channel := range channels {
+			result[i] = []byte(channel)
+		}
+		return reply.MakeMultiBulkReply(result)
+	case subCmd == "NUMSUB":
+		replies := make([]resp.Reply, 0, 2*(len(args)-1))
+		for _, arg := range args[1:] {
+			replies = append(replies,
+				reply.MakeBulkReply(arg),
+				reply.MakeIntReply(int64(len(hub.channels[string(arg)]))))
+		}
+		return reply.MakeMultiRawReply(replies)
+	case subCmd == "NUMPAT" && len(args) == 1:
+		return reply.MakeIntReply(int64(len(hub.patterns)))
+	}
+	return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + string(args[0]) + "'")
+}
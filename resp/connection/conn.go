@@ -2,6 +2,7 @@ package connection
 
 import (
 	"bytes"
+	"goRedis/lib/sync/atomic"
 	"goRedis/lib/sync/wait"
 	"net"
 	"sync"
@@ -17,6 +18,8 @@ type Connection struct {
 	mu sync.Mutex
 	// selected db
 	selectedDB int
+	// closed is set once Close is called
+	closed atomic.Boolean
 }
 
 func NewConn(conn net.Conn) *Connection {
@@ -32,6 +35,7 @@ func (c *Connection) RemoteAddr() net.Addr {
 
 // Close disconnect with the client
 func (c *Connection) Close() error {
+	c.closed.Set(true)
 	c.waitingReply.WaitWithTimeout(10 * time.Second)
 	_ = c.conn.Close()
 	return nil
@@ -53,6 +57,11 @@ func (c *Connection) Write(b []byte) error {
 	return err
 }
 
+// IsClosed returns whether the connection has been closed
+func (c *Connection) IsClosed() bool {
+	return c.closed.Get()
+}
+
 // GetDBIndex returns selected db
 func (c *Connection) GetDBIndex() int {
 	return c.selectedDB
@@ -25,6 +25,9 @@ var (
 	unknownErrReplyBytes = []byte("-ERR unknown\r\n")
 )
 
+// queueSize is the max number of commands of a client waiting to be executed
+const queueSize = 1024
+
 // RespHandler implements tcp.Handler and serves as a redis handler
 type RespHandler struct {
 	activeConn sync.Map // *client -> placeholder
@@ -62,6 +65,12 @@ func (h *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 	client := connection.NewConn(conn)
 	h.activeConn.Store(client, 1)
 
+	// commands are executed by another goroutine, so that a blocked command (e.g. blpop)
+	// does not stop us from noticing the client has gone
+	queue := make(chan *parser.Payload, queueSize)
+	defer close(queue)
+	go h.serve(client, queue)
+
 	ch := parser.ParseStream(conn)
 	for payload := range ch {
 		if payload.Err != nil {
@@ -73,13 +82,36 @@ func (h *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 				logger.Info("connection closed: " + client.RemoteAddr().String())
 				return
 			}
+		}
+		select {
+		case queue <- payload:
+		default:
+			// the client keeps pipelining while its commands are blocked and stops reading replies,
+			// drop it instead of blocking here, otherwise a disconnect would go unnoticed
+			h.closeClient(client)
+			logger.Info("connection closed, too many pending commands: " + client.RemoteAddr().String())
+			for range ch {
+				// let the parser notice the closed connection and exit
+			}
+			return
+		}
+	}
+}
+
+// serve executes commands of a client one by one and sends replies back
+func (h *RespHandler) serve(client *connection.Connection, queue <-chan *parser.Payload) {
+	for payload := range queue {
+		if client.IsClosed() {
+			// drop the remaining commands of a closed client
+			continue
+		}
+		if payload.Err != nil {
 			// protocol err
 			errReply := reply.MakeErrReply(payload.Err.Error())
 			err := client.Write(errReply.ToBytes())
 			if err != nil {
-				h.closeClient(client)
-				logger.Info("connection closed: " + client.RemoteAddr().String())
-				return
+				// the reading goroutine will notice the closed connection and clean up
+				_ = client.Close()
 			}
 			continue
 		}
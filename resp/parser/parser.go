@@ -32,6 +32,7 @@ type readState struct {
 	msgType           byte
 	args              [][]byte
 	bulkLen           int64 //字节组的长度
+	readingBody       bool  //下一行是字节组的内容，即使以$开头也不是长度
 }
 
 func (s *readState) finished() bool { //记录解析是不是没有完成
@@ -199,6 +200,7 @@ func parseBulkHeader(msg []byte, state *readState) error {
 	} else if state.bulkLen > 0 {
 		state.msgType = msg[0]
 		state.readingMultiLine = true
+		state.readingBody = true
 		state.expectedArgsCount = 1
 		state.args = make([][]byte, 0, 1)
 		return nil
@@ -236,17 +238,20 @@ func parseSingleLineReply(msg []byte) (resp.Reply, error) {
 func readBody(msg []byte, state *readState) error {
 	line := msg[0 : len(msg)-2]
 	var err error
-	if line[0] == '$' {
+	if !state.readingBody && len(line) > 0 && line[0] == '$' {
 		state.bulkLen, err = strconv.ParseInt(string(line[1:]), 10, 64)
-		if err != nil {
+		if err != nil || state.bulkLen < -1 {
 			return errors.New("protocol error: " + string(msg))
 		}
-		if state.bulkLen <= 0 {
+		if state.bulkLen == -1 { // null bulk
 			state.args = append(state.args, []byte{})
 			state.bulkLen = 0
+		} else {
+			state.readingBody = true // 长度为0时内容是一个空行
 		}
 	} else {
 		state.args = append(state.args, line)
+		state.readingBody = false
 	}
 	return nil
 }
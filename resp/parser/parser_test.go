@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"bytes"
+	"goRedis/resp/reply"
+	"testing"
+)
+
+func TestParseStream(t *testing.T) {
+	input := "*3\r\n$4\r\nXADD\r\n$3\r\n$12\r\n$0\r\n\r\n" +
+		"*2\r\n$3\r\nGET\r\n$1\r\n$\r\n" +
+		"$-1\r\n" +
+		"$5\r\n$-1ab\r\n"
+	expected := [][]string{
+		{"XADD", "$12", ""},
+		{"GET", "$"},
+	}
+	ch := ParseStream(bytes.NewReader([]byte(input)))
+	for i, args := range expected {
+		payload := <-ch
+		if payload.Err != nil {
+			t.Fatalf("command %d: unexpected error %v", i, payload.Err)
+		}
+		r, ok := payload.Data.(*reply.MultiBulkReply)
+		if !ok {
+			t.Fatalf("command %d: expect multi bulk reply, actual %T", i, payload.Data)
+		}
+		if len(r.Args) != len(args) {
+			t.Fatalf("command %d: expect %d args, actual %d", i, len(args), len(r.Args))
+		}
+		for j, arg := range args {
+			if string(r.Args[j]) != arg {
+				t.Errorf("command %d arg %d: expect %q, actual %q", i, j, arg, r.Args[j])
+			}
+		}
+	}
+	if payload := <-ch; payload.Err != nil {
+		t.Fatalf("unexpected error %v", payload.Err)
+	} else if _, ok := payload.Data.(*reply.NullBulkReply); !ok {
+		t.Errorf("expect null bulk reply, actual %T", payload.Data)
+	}
+	if payload := <-ch; payload.Err != nil {
+		t.Fatalf("unexpected error %v", payload.Err)
+	} else if r, ok := payload.Data.(*reply.BulkReply); !ok || string(r.Arg) != "$-1ab" {
+		t.Errorf("expect bulk reply $-1ab, actual %#v", payload.Data)
+	}
+}
@@ -49,6 +49,14 @@ func (r *EmptyMultiBulkReply) ToBytes() []byte {
 	return emptyMultiBulkBytes
 }
 
+var nullMultiBulkBytes = []byte("*-1\r\n") // nil数组，注：不是空数组
+
+type NullMultiBulkReply struct{}
+
+func (r *NullMultiBulkReply) ToBytes() []byte {
+	return nullMultiBulkBytes
+}
+
 type NoReply struct{} //空回复
 
 var noBytes = []byte("")
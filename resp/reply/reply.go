@@ -9,7 +9,7 @@ import (
 // 自定义回复
 
 var (
-	nullBulkReplyBytes = []byte("$-1")
+	nullBulkReplyBytes = []byte("$-1\r\n")
 	CRLF               = "\r\n"
 )
 
@@ -58,6 +58,27 @@ func (r *MultiBulkReply) ToBytes() []byte {
 	return buf.Bytes()
 }
 
+// MultiRawReply 由多个reply组成的数组，元素可以是不同类型的reply
+type MultiRawReply struct {
+	Replies []resp.Reply
+}
+
+func MakeMultiRawReply(replies []resp.Reply) *MultiRawReply {
+	return &MultiRawReply{
+		Replies: replies,
+	}
+}
+
+func (r *MultiRawReply) ToBytes() []byte {
+	argLen := len(r.Replies)
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(argLen) + CRLF)
+	for _, arg := range r.Replies {
+		buf.Write(arg.ToBytes())
+	}
+	return buf.Bytes()
+}
+
 // StatusReply 相关逻辑
 type StatusReply struct {
 	Status string